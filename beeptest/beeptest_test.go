@@ -0,0 +1,81 @@
+package beeptest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+)
+
+func TestFakesConform(t *testing.T) {
+	t.Run("Ramp", func(t *testing.T) {
+		beeptest.TestStreamSeeker(t, func() beep.StreamSeeker { return beeptest.Ramp(10000) })
+	})
+	t.Run("Impulse", func(t *testing.T) {
+		beeptest.TestStreamSeeker(t, func() beep.StreamSeeker { return beeptest.Impulse(1000, 17) })
+	})
+	t.Run("Empty", func(t *testing.T) {
+		beeptest.TestStreamSeeker(t, func() beep.StreamSeeker { return beeptest.Samples(nil) })
+	})
+}
+
+func TestValidatorDetectsViolations(t *testing.T) {
+	tests := []struct {
+		name string
+		s    beep.Streamer
+	}{
+		{"ZeroWithoutDrain", beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+			return 0, true
+		})},
+		{"SamplesWithDrain", beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+			return len(samples), false
+		})},
+		{"TooMany", beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+			return len(samples) + 1, true
+		})},
+		{"TouchOutside", beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+			for i := range samples {
+				samples[i] = [2]float64{}
+			}
+			return len(samples) / 2, true
+		})},
+		{"StreamAfterShortRead", beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+			for i := range samples {
+				samples[i] = [2]float64{}
+			}
+			if len(samples) > 1 {
+				return 1, true
+			}
+			return len(samples), true
+		})},
+	}
+	for _, test := range tests {
+		v := beeptest.Validate(test.s)
+		buf := make([][2]float64, 16)
+		for i := 0; i < 3; i++ {
+			v.Stream(buf)
+		}
+		if v.Valid() {
+			t.Errorf("%s: violation not detected", test.name)
+		}
+	}
+}
+
+func TestErroring(t *testing.T) {
+	fail := errors.New("fail")
+	s := beeptest.Erroring(100, fail, beeptest.Ramp(1000))
+	v := beeptest.Validate(s)
+
+	got := beeptest.Collect(v, 33)
+	beeptest.AssertSamples(t, got, beeptest.Collect(beeptest.Ramp(1000), 0)[:100], 0)
+	if v.Err() != fail {
+		t.Errorf("Err returned %v, want %v", v.Err(), fail)
+	}
+	if n, ok := v.Stream(make([][2]float64, 10)); n != 0 || ok {
+		t.Errorf("Stream returned (%d, %v) after failing", n, ok)
+	}
+	for _, violation := range v.Violations() {
+		t.Error(violation)
+	}
+}
@@ -0,0 +1,107 @@
+package beeptest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/brotholo/beep"
+)
+
+// Collect drains s and returns all of the samples it streamed. The samples are requested in chunks
+// of bufferSize samples. If bufferSize is not positive, 512 is used.
+func Collect(s beep.Streamer, bufferSize int) [][2]float64 {
+	if bufferSize <= 0 {
+		bufferSize = 512
+	}
+	var (
+		result [][2]float64
+		buf    = make([][2]float64, bufferSize)
+	)
+	for {
+		n, ok := s.Stream(buf)
+		if !ok {
+			return result
+		}
+		result = append(result, buf[:n]...)
+	}
+}
+
+// Diff compares got against want and returns an error describing the first difference. Two samples
+// are considered equal if no channel differs by more than tolerance. A nil error is returned if the
+// data match.
+func Diff(got, want [][2]float64, tolerance float64) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("beeptest: length mismatch: got %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		for c := range want[i] {
+			if d := math.Abs(got[i][c] - want[i][c]); !(d <= tolerance) {
+				return fmt.Errorf("beeptest: sample %d channel %d: got %v, want %v (tolerance %v)", i, c, got[i][c], want[i][c], tolerance)
+			}
+		}
+	}
+	return nil
+}
+
+// AssertSamples reports a test failure through t if got and want differ by more than tolerance.
+func AssertSamples(t testing.TB, got, want [][2]float64, tolerance float64) {
+	t.Helper()
+	if err := Diff(got, want, tolerance); err != nil {
+		t.Error(err)
+	}
+}
+
+// AssertStreamer drains s through a Validator and reports a test failure through t if s violates
+// the beep.Streamer contract or if the streamed data differ from want by more than tolerance.
+func AssertStreamer(t testing.TB, s beep.Streamer, want [][2]float64, tolerance float64) {
+	t.Helper()
+	v := Validate(s)
+	got := Collect(v, 0)
+	for _, violation := range v.Violations() {
+		t.Errorf("beeptest: contract violation: %v", violation)
+	}
+	AssertSamples(t, got, want, tolerance)
+}
+
+// ReadGolden reads samples stored by WriteGolden from the file at path.
+func ReadGolden(path string) ([][2]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		r      = bufio.NewReader(f)
+		data   [][2]float64
+		sample [2]float64
+	)
+	for {
+		err := binary.Read(r, binary.LittleEndian, &sample)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("beeptest: reading golden file %s: %v", path, err)
+		}
+		data = append(data, sample)
+	}
+}
+
+// WriteGolden stores samples in the file at path as little endian float64 pairs. The file can be
+// read back by ReadGolden.
+func WriteGolden(path string, data [][2]float64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(f, binary.LittleEndian, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,118 @@
+package beeptest
+
+import (
+	"testing"
+
+	"github.com/brotholo/beep"
+)
+
+// bufferSizes are the sizes of the samples slices used by the conformance suite. They include
+// the degenerate size 1 as well as sizes which are not powers of two.
+var bufferSizes = []int{1, 7, 479, 512, 4096}
+
+// TestStreamSeeker runs a conformance suite against a beep.StreamSeeker implementation. The
+// newStreamer function must return a fresh StreamSeeker positioned at the beginning each time it
+// is called, and all of the returned StreamSeekers must stream the same data.
+//
+//   func TestDecoder(t *testing.T) {
+//       beeptest.TestStreamSeeker(t, func() beep.StreamSeeker {
+//           s, _, err := wav.Decode(bytes.NewReader(data))
+//           if err != nil {
+//               t.Fatal(err)
+//           }
+//           return s
+//       })
+//   }
+//
+// The suite checks that the StreamSeeker follows the beep.Streamer contract with various buffer
+// sizes, that Len and Position are consistent with the streamed data and that seeking works.
+func TestStreamSeeker(t *testing.T, newStreamer func() beep.StreamSeeker) {
+	t.Helper()
+
+	reference := newStreamer()
+	length := reference.Len()
+	want := Collect(reference, 0)
+
+	t.Run("Len", func(t *testing.T) {
+		if len(want) != length {
+			t.Errorf("Len returned %d, but %d samples were streamed", length, len(want))
+		}
+	})
+
+	t.Run("Contract", func(t *testing.T) {
+		for _, size := range bufferSizes {
+			s := newStreamer()
+			v := Validate(s)
+			buf := make([][2]float64, size)
+			// an empty buffer must not drain the Streamer
+			v.Stream(buf[:0])
+			var got [][2]float64
+			for {
+				n, ok := v.Stream(buf)
+				got = append(got, buf[:n]...)
+				if pos := s.Position(); pos != len(got) {
+					t.Errorf("buffer size %d: Position returned %d after streaming %d samples", size, pos, len(got))
+					break
+				}
+				if !ok {
+					break
+				}
+			}
+			// a drained Streamer must stay drained
+			v.Stream(buf)
+			for _, violation := range v.Violations() {
+				t.Errorf("buffer size %d: %v", size, violation)
+			}
+			if err := Diff(got, want, 0); err != nil {
+				t.Errorf("buffer size %d: %v", size, err)
+			}
+		}
+	})
+
+	t.Run("Seek", func(t *testing.T) {
+		s := newStreamer()
+		for _, p := range []int{length / 2, 0, length, length / 3} {
+			if err := s.Seek(p); err != nil {
+				t.Errorf("Seek(%d) failed: %v", p, err)
+				continue
+			}
+			if pos := s.Position(); pos != p {
+				t.Errorf("Position returned %d after Seek(%d)", pos, p)
+			}
+			got := Collect(Validate(s), 0)
+			if err := Diff(got, want[p:], 0); err != nil {
+				t.Errorf("after Seek(%d): %v", p, err)
+			}
+		}
+	})
+
+	t.Run("SeekOutOfRange", func(t *testing.T) {
+		s := newStreamer()
+		if err := s.Seek(length / 2); err != nil {
+			t.Fatalf("Seek(%d) failed: %v", length/2, err)
+		}
+		for _, p := range []int{-1, length + 1} {
+			if err := s.Seek(p); err == nil {
+				t.Errorf("Seek(%d) succeeded for Len %d", p, length)
+			}
+			if pos := s.Position(); pos != length/2 {
+				t.Errorf("failed Seek(%d) changed Position to %d", p, pos)
+			}
+		}
+		if err := s.Err(); err != nil {
+			t.Errorf("failed Seek was reported through Err: %v", err)
+		}
+	})
+
+	t.Run("SeekAfterDrain", func(t *testing.T) {
+		s := newStreamer()
+		Collect(s, 0)
+		if err := s.Seek(0); err != nil {
+			t.Fatalf("Seek(0) failed after draining: %v", err)
+		}
+		got := Collect(Validate(s), 0)
+		if err := Diff(got, want, 0); err != nil {
+			t.Errorf("after draining and Seek(0): %v", err)
+		}
+	})
+}
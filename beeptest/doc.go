@@ -0,0 +1,6 @@
+// Package beeptest provides utilities for testing beep.Streamer implementations.
+//
+// It contains a Validator which checks that a Streamer follows the contract documented on the
+// beep.Streamer interface, deterministic fake Streamers, helpers for comparing streamed audio
+// against golden data and a conformance suite runnable against any beep.StreamSeeker.
+package beeptest
@@ -0,0 +1,120 @@
+package beeptest
+
+import (
+	"fmt"
+
+	"github.com/brotholo/beep"
+)
+
+// Samples returns a StreamSeeker which streams the provided samples.
+func Samples(data [][2]float64) beep.StreamSeeker {
+	return &dataStreamer{data: data}
+}
+
+// Ramp returns a StreamSeeker which streams num samples. The i-th sample is {i/num, -i/num}, so the
+// position of any sample can be recovered from its value.
+func Ramp(num int) beep.StreamSeeker {
+	data := make([][2]float64, num)
+	for i := range data {
+		x := float64(i) / float64(num)
+		data[i] = [2]float64{x, -x}
+	}
+	return Samples(data)
+}
+
+// Impulse returns a StreamSeeker which streams num samples of silence, except for the sample at
+// position at, which is 1 in both channels. If at is outside [0, num), Impulse streams only silence.
+func Impulse(num, at int) beep.StreamSeeker {
+	data := make([][2]float64, num)
+	if 0 <= at && at < num {
+		data[at] = [2]float64{1, 1}
+	}
+	return Samples(data)
+}
+
+// Constant returns a StreamSeeker which streams num samples of the value v.
+func Constant(num int, v [2]float64) beep.StreamSeeker {
+	data := make([][2]float64, num)
+	for i := range data {
+		data[i] = v
+	}
+	return Samples(data)
+}
+
+type dataStreamer struct {
+	data [][2]float64
+	pos  int
+}
+
+func (ds *dataStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	if ds.pos >= len(ds.data) {
+		return 0, false
+	}
+	n = copy(samples, ds.data[ds.pos:])
+	ds.pos += n
+	return n, true
+}
+
+func (ds *dataStreamer) Err() error {
+	return nil
+}
+
+func (ds *dataStreamer) Len() int {
+	return len(ds.data)
+}
+
+func (ds *dataStreamer) Position() int {
+	return ds.pos
+}
+
+func (ds *dataStreamer) Seek(p int) error {
+	if p < 0 || ds.Len() < p {
+		return fmt.Errorf("beeptest: seek position %v out of range [%v, %v]", p, 0, ds.Len())
+	}
+	ds.pos = p
+	return nil
+}
+
+// Erroring returns a Streamer which streams at most num samples from s and then fails with err.
+//
+// The error is set by the first Stream call after the num samples were streamed (or after s got
+// drained). That call and all the following ones return 0, false, as required by the beep.Streamer
+// contract.
+func Erroring(num int, err error, s beep.Streamer) beep.Streamer {
+	return &erroring{
+		s:       s,
+		remains: num,
+		fail:    err,
+	}
+}
+
+type erroring struct {
+	s       beep.Streamer
+	remains int
+	fail    error
+	err     error
+}
+
+func (e *erroring) Stream(samples [][2]float64) (n int, ok bool) {
+	if e.err != nil {
+		return 0, false
+	}
+	if e.remains <= 0 {
+		e.err = e.fail
+		return 0, false
+	}
+	if len(samples) > e.remains {
+		samples = samples[:e.remains]
+	}
+	n, ok = e.s.Stream(samples)
+	if !ok {
+		e.err = e.fail
+		return 0, false
+	}
+	e.remains -= n
+	return n, true
+}
+
+func (e *erroring) Err() error {
+	return e.err
+}
@@ -0,0 +1,168 @@
+package beeptest
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/brotholo/beep"
+)
+
+// Violation describes a single breach of the beep.Streamer contract observed by a Validator.
+type Violation struct {
+	// Call is the index of the Stream call (starting at 0) in which the violation occurred.
+	Call int
+
+	// Len is the length of the samples slice passed to the Stream call.
+	Len int
+
+	// N and Ok are the values returned by the Stream call.
+	N  int
+	Ok bool
+
+	// Reason is a human readable description of the violation.
+	Reason string
+}
+
+// String formats the Violation for use in log and test failure messages.
+func (v Violation) String() string {
+	return fmt.Sprintf("call %d: Stream(len=%d) = (%d, %v): %s", v.Call, v.Len, v.N, v.Ok, v.Reason)
+}
+
+// Validator wraps a Streamer and records every violation of the beep.Streamer contract it
+// observes. The Validator itself is a Streamer which streams the same data as the wrapped one.
+//
+//   v := beeptest.Validate(s)
+//   speaker.Play(v)
+//   // ...
+//   for _, violation := range v.Violations() {
+//       log.Println(violation)
+//   }
+//
+// The following rules are checked:
+//
+//   - n is between 0 and len(samples)
+//   - only the three return patterns listed on beep.Streamer occur
+//   - after a short read (0 < n < len(samples)) only (0, false) follows
+//   - after (0, false) only (0, false) follows
+//   - when Err returns a non-nil error, Stream returns (0, false)
+//   - Stream doesn't touch samples[n:]
+//   - Stream doesn't produce NaN or infinite values
+type Validator struct {
+	s          beep.Streamer
+	buf        [][2]float64
+	calls      int
+	short      bool
+	drained    bool
+	violations []Violation
+}
+
+// Validate returns a Validator wrapping s.
+func Validate(s beep.Streamer) *Validator {
+	return &Validator{s: s}
+}
+
+// canary is written to the parts of the buffer that the wrapped Streamer is not allowed to touch.
+var canary = [2]float64{math.Float64frombits(0x7ff8dead0000beef), math.Float64frombits(0x7ff8dead0000beef)}
+
+// Stream streams the wrapped Streamer and checks the returned values.
+func (v *Validator) Stream(samples [][2]float64) (n int, ok bool) {
+	if cap(v.buf) < len(samples) {
+		v.buf = make([][2]float64, len(samples))
+	}
+	buf := v.buf[:len(samples)]
+	for i := range buf {
+		buf[i] = canary
+	}
+
+	errBefore := v.s.Err()
+	n, ok = v.s.Stream(buf)
+
+	report := func(format string, args ...interface{}) {
+		v.violations = append(v.violations, Violation{
+			Call:   v.calls,
+			Len:    len(samples),
+			N:      n,
+			Ok:     ok,
+			Reason: fmt.Sprintf(format, args...),
+		})
+	}
+	defer func() { v.calls++ }()
+
+	if n < 0 || n > len(samples) {
+		report("n out of range [0, %d]", len(samples))
+		if n < 0 {
+			n = 0
+		}
+		if n > len(samples) {
+			n = len(samples)
+		}
+	}
+
+	switch {
+	case ok && n == len(samples):
+		// pattern 1
+	case ok && 0 < n && n < len(samples):
+		// pattern 2
+	case !ok && n == 0:
+		// pattern 3
+	case ok && n == 0:
+		report("returned no samples, but did not report drained")
+	case !ok && n > 0:
+		report("returned samples, but reported drained")
+	}
+
+	switch {
+	case v.drained && (n != 0 || ok):
+		report("streamed after being drained")
+	case v.short && (n != 0 || ok):
+		report("streamed after a short read")
+	case errBefore != nil && (n != 0 || ok):
+		report("streamed after Err returned %v", errBefore)
+	}
+
+	for i := range buf[:n] {
+		for c := range buf[i] {
+			if math.IsNaN(buf[i][c]) || math.IsInf(buf[i][c], 0) {
+				report("invalid value %v at sample %d channel %d", buf[i][c], i, c)
+				break
+			}
+		}
+	}
+	for i := n; i < len(buf); i++ {
+		if math.Float64bits(buf[i][0]) != math.Float64bits(canary[0]) ||
+			math.Float64bits(buf[i][1]) != math.Float64bits(canary[1]) {
+			report("touched sample %d outside samples[:n]", i)
+			break
+		}
+	}
+
+	if !ok {
+		v.drained = true
+	}
+	if ok && n < len(samples) {
+		v.short = true
+	}
+
+	copy(samples, buf[:n])
+	return n, ok
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (v *Validator) Err() error {
+	return v.s.Err()
+}
+
+// Calls returns the number of Stream calls observed so far.
+func (v *Validator) Calls() int {
+	return v.calls
+}
+
+// Violations returns all contract violations observed so far.
+func (v *Validator) Violations() []Violation {
+	return v.violations
+}
+
+// Valid returns true if no contract violations were observed so far.
+func (v *Validator) Valid() bool {
+	return len(v.violations) == 0
+}
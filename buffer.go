@@ -2,8 +2,9 @@ package beep
 
 import (
 	"fmt"
-	"math"
 	"time"
+
+	"github.com/brotholo/beep/internal/sampleconv"
 )
 
 // SampleRate is the number of samples per second.
@@ -31,6 +32,11 @@ type Format struct {
 	// Precision is the number of bytes used to encode a single sample. Only values up to 6 work
 	// well, higher values loose precision due to floating point numbers.
 	Precision int
+
+	// ChannelMask specifies the speaker positions of the channels when NumChannels is greater than
+	// 2. It's used to mix the channels down to stereo when decoding. The value of 0 means the
+	// conventional layout for NumChannels, see DefaultChannelMask.
+	ChannelMask ChannelMask
 }
 
 // Width returns the number of bytes per one frame (samples in all channels).
@@ -40,38 +46,75 @@ func (f Format) Width() int {
 	return f.NumChannels * f.Precision
 }
 
+// Downmix returns the Downmix used to mix audio in this format down to stereo when decoding.
+func (f Format) Downmix() Downmix {
+	return DownmixFromMask(f.channelMask())
+}
+
+func (f Format) channelMask() ChannelMask {
+	if f.ChannelMask != 0 && f.ChannelMask.NumChannels() == f.NumChannels {
+		return f.ChannelMask
+	}
+	if m := DefaultChannelMask(f.NumChannels); m != 0 {
+		return m
+	}
+	// no conventional layout, keep the first two channels
+	return LayoutStereo
+}
+
 // EncodeSigned encodes a single sample in f.Width() bytes to p in signed format.
 func (f Format) EncodeSigned(p []byte, sample [2]float64) (n int) {
-	return f.encode(true, p, sample)
+	return f.encode(signedInt, p, sample)
 }
 
 // EncodeUnsigned encodes a single sample in f.Width() bytes to p in unsigned format.
 func (f Format) EncodeUnsigned(p []byte, sample [2]float64) (n int) {
-	return f.encode(false, p, sample)
+	return f.encode(unsignedInt, p, sample)
+}
+
+// EncodeFloat encodes a single sample in f.Width() bytes to p in little-endian IEEE 754 floating
+// point format. The precision of f must be 4 (float32) or 8 (float64).
+func (f Format) EncodeFloat(p []byte, sample [2]float64) (n int) {
+	return f.encode(ieeeFloat, p, sample)
 }
 
 // DecodeSigned decodes a single sample encoded in f.Width() bytes from p in signed format.
 func (f Format) DecodeSigned(p []byte) (sample [2]float64, n int) {
-	return f.decode(true, p)
+	return f.decode(signedInt, p)
 }
 
 // DecodeUnsigned decodes a single sample encoded in f.Width() bytes from p in unsigned format.
 func (f Format) DecodeUnsigned(p []byte) (sample [2]float64, n int) {
-	return f.decode(false, p)
+	return f.decode(unsignedInt, p)
+}
+
+// DecodeFloat decodes a single sample encoded in f.Width() bytes from p in little-endian IEEE 754
+// floating point format. The precision of f must be 4 (float32) or 8 (float64).
+func (f Format) DecodeFloat(p []byte) (sample [2]float64, n int) {
+	return f.decode(ieeeFloat, p)
 }
 
-func (f Format) encode(signed bool, p []byte, sample [2]float64) (n int) {
+// encoding is the encoding of a single value of a sample.
+type encoding int
+
+const (
+	signedInt encoding = iota
+	unsignedInt
+	ieeeFloat
+)
+
+func (f Format) encode(e encoding, p []byte, sample [2]float64) (n int) {
 	switch {
 	case f.NumChannels == 1:
-		x := norm((sample[0] + sample[1]) / 2)
-		p = p[encodeFloat(signed, f.Precision, p, x):]
+		x := sampleconv.Norm((sample[0] + sample[1]) / 2)
+		p = p[encodeFloat(e, f.Precision, p, x):]
 	case f.NumChannels >= 2:
 		for c := range sample {
-			x := norm(sample[c])
-			p = p[encodeFloat(signed, f.Precision, p, x):]
+			x := sampleconv.Norm(sample[c])
+			p = p[encodeFloat(e, f.Precision, p, x):]
 		}
 		for c := len(sample); c < f.NumChannels; c++ {
-			p = p[encodeFloat(signed, f.Precision, p, 0):]
+			p = p[encodeFloat(e, f.Precision, p, 0):]
 		}
 	default:
 		panic(fmt.Errorf("format: encode: invalid number of channels: %d", f.NumChannels))
@@ -79,19 +122,26 @@ func (f Format) encode(signed bool, p []byte, sample [2]float64) (n int) {
 	return f.Width()
 }
 
-func (f Format) decode(signed bool, p []byte) (sample [2]float64, n int) {
+func (f Format) decode(e encoding, p []byte) (sample [2]float64, n int) {
 	switch {
 	case f.NumChannels == 1:
-		x, _ := decodeFloat(signed, f.Precision, p)
+		x, _ := decodeFloat(e, f.Precision, p)
 		return [2]float64{x, x}, f.Width()
-	case f.NumChannels >= 2:
+	case f.NumChannels == 2:
 		for c := range sample {
-			x, n := decodeFloat(signed, f.Precision, p)
+			x, n := decodeFloat(e, f.Precision, p)
 			sample[c] = x
 			p = p[n:]
 		}
-		for c := len(sample); c < f.NumChannels; c++ {
-			_, n := decodeFloat(signed, f.Precision, p)
+		return sample, f.Width()
+	case f.NumChannels > 2:
+		// mix all channels down to stereo according to the channel mask
+		mask := f.channelMask()
+		for c := 0; c < f.NumChannels; c++ {
+			x, n := decodeFloat(e, f.Precision, p)
+			g := mask.channelGains(c)
+			sample[0] += g[0] * x
+			sample[1] += g[1] * x
 			p = p[n:]
 		}
 		return sample, f.Width()
@@ -100,64 +150,30 @@ func (f Format) decode(signed bool, p []byte) (sample [2]float64, n int) {
 	}
 }
 
-func encodeFloat(signed bool, precision int, p []byte, x float64) (n int) {
+func encodeFloat(e encoding, precision int, p []byte, x float64) (n int) {
 	var xUint64 uint64
-	if signed {
-		xUint64 = floatToSigned(precision, x)
-	} else {
-		xUint64 = floatToUnsigned(precision, x)
-	}
-	for i := 0; i < precision; i++ {
-		p[i] = byte(xUint64)
-		xUint64 >>= 8
+	switch e {
+	case signedInt:
+		xUint64 = sampleconv.FloatToSigned(precision, x)
+	case unsignedInt:
+		xUint64 = sampleconv.FloatToUnsigned(precision, x)
+	case ieeeFloat:
+		xUint64 = sampleconv.FloatToIEEE(precision, x)
 	}
+	sampleconv.PutUint(p[:precision], xUint64, false)
 	return precision
 }
 
-func decodeFloat(signed bool, precision int, p []byte) (x float64, n int) {
-	var xUint64 uint64
-	for i := precision - 1; i >= 0; i-- {
-		xUint64 <<= 8
-		xUint64 += uint64(p[i])
-	}
-	if signed {
-		return signedToFloat(precision, xUint64), precision
-	}
-	return unsignedToFloat(precision, xUint64), precision
-}
-
-func floatToSigned(precision int, x float64) uint64 {
-	if x < 0 {
-		compl := uint64(-x * (math.Exp2(float64(precision)*8-1) - 1))
-		return uint64(1<<uint(precision*8)) - compl
-	}
-	return uint64(x * (math.Exp2(float64(precision)*8-1) - 1))
-}
-
-func floatToUnsigned(precision int, x float64) uint64 {
-	return uint64((x + 1) / 2 * (math.Exp2(float64(precision)*8) - 1))
-}
-
-func signedToFloat(precision int, xUint64 uint64) float64 {
-	if xUint64 >= 1<<uint(precision*8-1) {
-		compl := 1<<uint(precision*8) - xUint64
-		return -float64(int64(compl)) / (math.Exp2(float64(precision)*8-1) - 1)
-	}
-	return float64(int64(xUint64)) / (math.Exp2(float64(precision)*8-1) - 1)
-}
-
-func unsignedToFloat(precision int, xUint64 uint64) float64 {
-	return float64(xUint64)/(math.Exp2(float64(precision)*8)-1)*2 - 1
-}
-
-func norm(x float64) float64 {
-	if x < -1 {
-		return -1
-	}
-	if x > +1 {
-		return +1
+func decodeFloat(e encoding, precision int, p []byte) (x float64, n int) {
+	xUint64 := sampleconv.Uint(p[:precision], false)
+	switch e {
+	case signedInt:
+		return sampleconv.SignedToFloat(precision, xUint64), precision
+	case unsignedInt:
+		return sampleconv.UnsignedToFloat(precision, xUint64), precision
+	default:
+		return sampleconv.IEEEToFloat(precision, xUint64), precision
 	}
-	return x
 }
 
 // Buffer is a storage for audio data. You can think of it as a bytes.Buffer for audio samples.
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
 )
 
 func TestFormatEncodeDecode(t *testing.T) {
@@ -28,6 +29,14 @@ func TestFormatEncodeDecode(t *testing.T) {
 	for format := range formats {
 		for i := 0; i < 20; i++ {
 			deviation := 2.0 / (math.Pow(2, float64(format.Precision)*8) - 2)
+			if format.NumChannels > 2 {
+				// the quantization errors of all channels add up in the downmix
+				gains := 0.0
+				for _, row := range format.Downmix() {
+					gains += math.Max(math.Abs(row[0]), math.Abs(row[1]))
+				}
+				deviation *= gains
+			}
 			sample := [2]float64{rand.Float64()*2 - 1, rand.Float64()*2 - 1}
 
 			tmp := make([]byte, format.Width())
@@ -60,6 +69,33 @@ func TestFormatEncodeDecode(t *testing.T) {
 	}
 }
 
+func TestFormatEncodeDecodeFloat(t *testing.T) {
+	for _, precision := range []int{4, 8} {
+		deviation := 1e-7
+		if precision == 8 {
+			deviation = 0
+		}
+		for _, numChannels := range []int{1, 2} {
+			format := beep.Format{SampleRate: 44100, NumChannels: numChannels, Precision: precision}
+			for i := 0; i < 20; i++ {
+				sample := [2]float64{rand.Float64()*2 - 1, rand.Float64()*2 - 1}
+				want := sample
+				if numChannels == 1 {
+					want[0] = (sample[0] + sample[1]) / 2
+					want[1] = want[0]
+				}
+
+				tmp := make([]byte, format.Width())
+				format.EncodeFloat(tmp, sample)
+				decoded, _ := format.DecodeFloat(tmp)
+				if math.Abs(want[0]-decoded[0]) > deviation || math.Abs(want[1]-decoded[1]) > deviation {
+					t.Fatalf("float decoded sample is too different: %v -> %v (format: %+v)", sample, decoded, format)
+				}
+			}
+		}
+	}
+}
+
 func TestBufferAppendPop(t *testing.T) {
 	formats := make(chan beep.Format)
 	go func() {
@@ -85,3 +121,55 @@ func TestBufferAppendPop(t *testing.T) {
 		}
 	}
 }
+
+func TestBufferStreamerConformance(t *testing.T) {
+	b := beep.NewBuffer(beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 2})
+	b.Append(beeptest.Ramp(10000))
+	beeptest.TestStreamSeeker(t, func() beep.StreamSeeker {
+		return b.Streamer(0, b.Len())
+	})
+}
+
+func TestFormatDecodeDownmix(t *testing.T) {
+	format := beep.Format{SampleRate: 48000, NumChannels: 6, Precision: 2}
+	channels := []float64{0.1, 0.2, 0.3, 0.4, 0.5, -0.5}
+
+	tmp := make([]byte, format.Width())
+	for c, x := range channels {
+		mono := beep.Format{SampleRate: 48000, NumChannels: 1, Precision: 2}
+		mono.EncodeSigned(tmp[c*2:], [2]float64{x, x})
+	}
+
+	a := math.Sqrt2 / 2
+	want := [2]float64{0.1 + a*0.3 + a*0.5, 0.2 + a*0.3 - a*0.5}
+	got, n := format.DecodeSigned(tmp)
+	if n != format.Width() {
+		t.Fatalf("decoded %d bytes, want %d", n, format.Width())
+	}
+	if math.Abs(got[0]-want[0]) > 1e-3 || math.Abs(got[1]-want[1]) > 1e-3 {
+		t.Errorf("5.1 downmix: got %v, want %v", got, want)
+	}
+
+	format.ChannelMask = beep.FrontCenter | beep.BackLeft | beep.FrontLeft | beep.FrontRight | beep.SideLeft | beep.SideRight
+	got, _ = format.DecodeSigned(tmp)
+	want = [2]float64{0.1 + a*0.3 + a*0.4 + a*0.5, 0.2 + a*0.3 - a*0.5}
+	if math.Abs(got[0]-want[0]) > 1e-3 || math.Abs(got[1]-want[1]) > 1e-3 {
+		t.Errorf("channel mask downmix: got %v, want %v", got, want)
+	}
+}
+
+func TestDownmix(t *testing.T) {
+	itu := beep.DownmixITU51()
+	if got := itu.Apply([]float64{0, 0, 0, 1, 0, 0}); got != [2]float64{} {
+		t.Errorf("ITU 5.1 downmix kept the LFE channel: %v", got)
+	}
+	if got := itu.Normalize().Apply([]float64{1, 1, 1, 1, 1, 1}); got[0] > 1+1e-9 || got[1] > 1+1e-9 {
+		t.Errorf("normalized downmix clips: %v", got)
+	}
+	if got := beep.DownmixQuad().Apply([]float64{1, 0, 1, 0}); math.Abs(got[0]-1-math.Sqrt2/2) > 1e-9 || got[1] != 0 {
+		t.Errorf("quad downmix: got %v", got)
+	}
+	if got := beep.SelectChannels(6, 2, 4).Apply([]float64{1, 2, 3, 4, 5, 6}); got != [2]float64{3, 5} {
+		t.Errorf("channel selection: got %v, want [3 5]", got)
+	}
+}
@@ -82,6 +82,10 @@ func (l *loop) Err() error {
 func Seq(s ...Streamer) Streamer {
 	i := 0
 	return StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		if len(samples) == 0 {
+			// nothing can be streamed, but the Streamers are not drained yet
+			return 0, i < len(s)
+		}
 		for i < len(s) && len(samples) > 0 {
 			sn, sok := s[i].Stream(samples)
 			samples = samples[sn:]
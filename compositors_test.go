@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
 )
 
 // randomDataStreamer generates random samples of duration d and returns a StreamSeeker which streams
@@ -120,6 +121,29 @@ func TestSeq(t *testing.T) {
 	}
 }
 
+func TestSeqContract(t *testing.T) {
+	tests := [][]beep.Streamer{
+		{},
+		{beep.Silence(0)},
+		{beep.Silence(0), beeptest.Ramp(100), beep.Silence(0)},
+		{beeptest.Ramp(100), beep.Callback(nil), beeptest.Impulse(10, 3)},
+	}
+	for _, s := range tests {
+		v := beeptest.Validate(beep.Seq(s...))
+		var buf [33][2]float64
+		v.Stream(buf[:0])
+		for {
+			if _, ok := v.Stream(buf[:]); !ok {
+				break
+			}
+		}
+		v.Stream(buf[:])
+		for _, violation := range v.Violations() {
+			t.Errorf("Seq of %d Streamers: %v", len(s), violation)
+		}
+	}
+}
+
 func TestMix(t *testing.T) {
 	var (
 		n    = 7
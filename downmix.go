@@ -0,0 +1,206 @@
+package beep
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// ChannelMask specifies the speaker positions of the channels of multichannel audio. Each set bit
+// corresponds to one channel and the channels are ordered from the lowest bit to the highest bit.
+// The bit assignment is the same as in the dwChannelMask field of WAVE_FORMAT_EXTENSIBLE.
+type ChannelMask uint32
+
+// Speaker positions.
+const (
+	FrontLeft ChannelMask = 1 << iota
+	FrontRight
+	FrontCenter
+	LowFrequency
+	BackLeft
+	BackRight
+	FrontLeftOfCenter
+	FrontRightOfCenter
+	BackCenter
+	SideLeft
+	SideRight
+	TopCenter
+	TopFrontLeft
+	TopFrontCenter
+	TopFrontRight
+	TopBackLeft
+	TopBackCenter
+	TopBackRight
+)
+
+// Common channel layouts.
+const (
+	LayoutMono    = FrontCenter
+	LayoutStereo  = FrontLeft | FrontRight
+	Layout3_0     = FrontLeft | FrontRight | FrontCenter
+	LayoutQuad    = FrontLeft | FrontRight | BackLeft | BackRight
+	Layout5_0     = FrontLeft | FrontRight | FrontCenter | BackLeft | BackRight
+	Layout5_1     = FrontLeft | FrontRight | FrontCenter | LowFrequency | BackLeft | BackRight
+	Layout5_1Side = FrontLeft | FrontRight | FrontCenter | LowFrequency | SideLeft | SideRight
+	Layout6_1     = FrontLeft | FrontRight | FrontCenter | LowFrequency | BackCenter | SideLeft | SideRight
+	Layout7_1     = FrontLeft | FrontRight | FrontCenter | LowFrequency | BackLeft | BackRight | SideLeft | SideRight
+)
+
+// numSpeakerBits is the number of defined speaker positions.
+const numSpeakerBits = 18
+
+// DefaultChannelMask returns the conventional layout of audio with numChannels channels, as used by
+// WAVE and FLAC files without an explicit channel mask. It returns 0 if there's no conventional
+// layout for numChannels.
+func DefaultChannelMask(numChannels int) ChannelMask {
+	switch numChannels {
+	case 1:
+		return LayoutMono
+	case 2:
+		return LayoutStereo
+	case 3:
+		return Layout3_0
+	case 4:
+		return LayoutQuad
+	case 5:
+		return Layout5_0
+	case 6:
+		return Layout5_1
+	case 7:
+		return Layout6_1
+	case 8:
+		return Layout7_1
+	default:
+		return 0
+	}
+}
+
+// NumChannels returns the number of channels in the mask.
+func (m ChannelMask) NumChannels() int {
+	return bits.OnesCount32(uint32(m & (1<<numSpeakerBits - 1)))
+}
+
+// speakerGains are the gains with which a speaker at the given position contributes to the left and
+// right stereo channel. The values follow ITU-R BS.775, the center and surround channels are
+// attenuated by 3dB and the LFE channel is dropped.
+var speakerGains = [numSpeakerBits][2]float64{
+	{1, 0},                           // FrontLeft
+	{0, 1},                           // FrontRight
+	{math.Sqrt2 / 2, math.Sqrt2 / 2}, // FrontCenter
+	{0, 0},                           // LowFrequency
+	{math.Sqrt2 / 2, 0},              // BackLeft
+	{0, math.Sqrt2 / 2},              // BackRight
+	{math.Cos(math.Pi / 8), math.Sin(math.Pi / 8)}, // FrontLeftOfCenter
+	{math.Sin(math.Pi / 8), math.Cos(math.Pi / 8)}, // FrontRightOfCenter
+	{0.5, 0.5},                       // BackCenter
+	{math.Sqrt2 / 2, 0},              // SideLeft
+	{0, math.Sqrt2 / 2},              // SideRight
+	{0.5, 0.5},                       // TopCenter
+	{math.Sqrt2 / 2, 0},              // TopFrontLeft
+	{0.5, 0.5},                       // TopFrontCenter
+	{0, math.Sqrt2 / 2},              // TopFrontRight
+	{0.5, 0},                         // TopBackLeft
+	{math.Sqrt2 / 4, math.Sqrt2 / 4}, // TopBackCenter
+	{0, 0.5},                         // TopBackRight
+}
+
+// channelGains returns the stereo gains of the i-th channel of the mask. Channels beyond the ones
+// described by the mask are dropped.
+func (m ChannelMask) channelGains(i int) [2]float64 {
+	for b := 0; b < numSpeakerBits; b++ {
+		if m&(1<<uint(b)) == 0 {
+			continue
+		}
+		if i == 0 {
+			return speakerGains[b]
+		}
+		i--
+	}
+	return [2]float64{}
+}
+
+// Downmix is a matrix which mixes multichannel audio down to stereo. The i-th row contains the
+// gains with which the i-th input channel contributes to the left and the right output channel.
+//
+// For example, this Downmix swaps the channels of a stereo signal and mixes in a center channel:
+//
+//	beep.Downmix{{0, 1}, {1, 0}, {0.5, 0.5}}
+type Downmix [][2]float64
+
+// DownmixFromMask returns a Downmix for audio with channels at the positions specified by mask.
+// The coefficients follow ITU-R BS.775: front channels are kept, center and surround channels are
+// mixed in at -3dB and the LFE channel is dropped. Mono audio is sent to both outputs at unity
+// gain.
+//
+// The coefficients are not normalized, which keeps the front channels at unity gain, so stereo
+// audio stored in the front channels of multichannel audio decodes unchanged. In exchange, the mix
+// needs headroom: full scale audio in all channels exceeds full scale in the mix, for 5.1 by 1+√2
+// (7.7dB), and clips when played or encoded as integers. Use Normalize for a Downmix which never
+// clips, for example with the DecodeDownmix functions of the decoders.
+func DownmixFromMask(mask ChannelMask) Downmix {
+	if mask == LayoutMono {
+		return Downmix{{1, 1}}
+	}
+	d := make(Downmix, mask.NumChannels())
+	for i := range d {
+		d[i] = mask.channelGains(i)
+	}
+	return d
+}
+
+// DownmixQuad returns the Downmix for quadraphonic audio with channels ordered front left, front
+// right, back left, back right.
+func DownmixQuad() Downmix {
+	return DownmixFromMask(LayoutQuad)
+}
+
+// DownmixITU51 returns the ITU-R BS.775 Downmix for 5.1 audio with channels ordered front left,
+// front right, center, LFE, surround left, surround right (the WAVE and FLAC order).
+func DownmixITU51() Downmix {
+	return DownmixFromMask(Layout5_1)
+}
+
+// SelectChannels returns a Downmix for audio with numChannels channels which routes the channel
+// left to the left output and the channel right to the right output, dropping all the other
+// channels. It panics if left or right is out of range.
+func SelectChannels(numChannels, left, right int) Downmix {
+	if left < 0 || left >= numChannels || right < 0 || right >= numChannels {
+		panic(fmt.Errorf("downmix: channels %d and %d out of range [0, %d)", left, right, numChannels))
+	}
+	d := make(Downmix, numChannels)
+	d[left][0] = 1
+	d[right][1] = 1
+	return d
+}
+
+// Normalize returns a copy of d scaled so that the sum of the absolute gains going to each output
+// channel is at most 1. Mixing full scale input through a normalized Downmix never clips.
+func (d Downmix) Normalize() Downmix {
+	var sum [2]float64
+	for _, row := range d {
+		sum[0] += math.Abs(row[0])
+		sum[1] += math.Abs(row[1])
+	}
+	scale := math.Max(sum[0], sum[1])
+	if scale <= 1 {
+		scale = 1
+	}
+	n := make(Downmix, len(d))
+	for i, row := range d {
+		n[i] = [2]float64{row[0] / scale, row[1] / scale}
+	}
+	return n
+}
+
+// Apply mixes a single frame of channel values down to a stereo sample. Channels without a
+// corresponding row in d are dropped.
+func (d Downmix) Apply(channels []float64) (sample [2]float64) {
+	for i, x := range channels {
+		if i >= len(d) {
+			break
+		}
+		sample[0] += d[i][0] * x
+		sample[1] += d[i][1] * x
+	}
+	return sample
+}
@@ -0,0 +1,114 @@
+package effects
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/brotholo/beep"
+)
+
+const (
+	// agcWindow is the time constant of the level detector of AGC, which averages over syllables.
+	agcWindow = 100 * time.Millisecond
+
+	// agcGateWindow is the time constant of the level detector of the gate of AGC. It's shorter,
+	// so that the gate closes before the slow detector follows the end of the speech.
+	agcGateWindow = 10 * time.Millisecond
+)
+
+// AGC (automatic gain control) brings the level of the wrapped Streamer to the target level, for
+// example to even out the levels of different microphones and speakers before a voice activity
+// detection or speech recognition.
+//
+// AGC follows the RMS level of the audio. When it rises above the target, the gain falls with the
+// attack time, when it falls below the target, the gain rises with the release time, up to the
+// maximum gain. Audio below the gate level counts as background noise, the gain holds during it,
+// so AGC doesn't amplify the noise in the pauses of the speech.
+//
+// Create an AGC with NewAGC, which sets up its detectors for the sample rate. Its fields can be
+// changed afterwards. If AGC is playing through the speaker, lock the speaker before changing them.
+type AGC struct {
+	Streamer beep.Streamer
+
+	// Target is the RMS level of the output in dBFS, for example -20.
+	Target float64
+
+	// MaxGain is the highest gain in dB, for example 30. AGC cuts loud audio without limit.
+	MaxGain float64
+
+	// Attack and Release are the times the gain takes to fall and to rise.
+	Attack  time.Duration
+	Release time.Duration
+
+	// Gate is the RMS level of the input in dBFS below which the gain holds, for example -50.
+	Gate float64
+
+	sr         beep.SampleRate
+	detect     float64 // smoothing coefficients of the level detectors
+	gateDetect float64
+	power      float64 // smoothed power of the input
+	gatePower  float64
+	gain       float64 // current gain in dB
+}
+
+// NewAGC returns an AGC of s with Target -20 dBFS, MaxGain 30 dB, Attack 50ms, Release 1s and
+// Gate -50 dBFS. It starts at the gain of 0 dB.
+func NewAGC(s beep.Streamer, sr beep.SampleRate) *AGC {
+	return &AGC{
+		Streamer:   s,
+		Target:     -20,
+		MaxGain:    30,
+		Attack:     50 * time.Millisecond,
+		Release:    time.Second,
+		Gate:       -50,
+		sr:         sr,
+		detect:     smoothing(sr, agcWindow),
+		gateDetect: smoothing(sr, agcGateWindow),
+	}
+}
+
+// Stream streams the wrapped Streamer with the gain controlled.
+func (a *AGC) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = a.Streamer.Stream(samples)
+	a.Level(samples[:n])
+	return n, ok
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (a *AGC) Err() error {
+	return a.Streamer.Err()
+}
+
+// Level controls the gain of samples in place, continuing from the previous samples. Stream calls
+// it on the samples of the wrapped Streamer, it's useful on its own to level audio which doesn't
+// come from a Streamer, see wav.Leveler.
+func (a *AGC) Level(samples [][2]float64) {
+	if a.sr == 0 {
+		panic(fmt.Errorf("effects: AGC not created by NewAGC"))
+	}
+	attack, release := smoothing(a.sr, a.Attack), smoothing(a.sr, a.Release)
+	for i := range samples {
+		p := math.Max(samples[i][0]*samples[i][0], samples[i][1]*samples[i][1])
+		a.power = a.detect*a.power + (1-a.detect)*p
+		a.gatePower = a.gateDetect*a.gatePower + (1-a.gateDetect)*p
+
+		if 10*math.Log10(a.gatePower) >= a.Gate {
+			target := math.Min(a.Target-10*math.Log10(a.power), a.MaxGain)
+			coef := release
+			if target < a.gain {
+				coef = attack
+			}
+			a.gain = coef*a.gain + (1-coef)*target
+		}
+
+		g := fromDB(a.gain)
+		samples[i][0] *= g
+		samples[i][1] *= g
+	}
+}
+
+// Gain returns the gain currently applied in dB.
+func (a *AGC) Gain() float64 {
+	return a.gain
+}
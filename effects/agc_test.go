@@ -0,0 +1,78 @@
+package effects_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+)
+
+// rmsDB returns the RMS level of the left channel of samples in dBFS.
+func rmsDB(samples [][2]float64) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += s[0] * s[0]
+	}
+	return 10 * math.Log10(sum/float64(len(samples)))
+}
+
+func TestAGC(t *testing.T) {
+	const sr = beep.SampleRate(16000)
+	tests := []struct {
+		input, want float64 // dBFS RMS
+	}{
+		{-40, -20},
+		{-6, -20},
+		{-60, -30}, // limited by MaxGain
+	}
+	for _, tt := range tests {
+		// a sine's RMS is 3 dB below its peak
+		data := sine(sr, 440, sr.N(5e9))
+		for i := range data {
+			data[i][0] *= fromDB(tt.input + 3.01)
+			data[i][1] *= fromDB(tt.input + 3.01)
+		}
+		agc := effects.NewAGC(beeptest.Samples(data), sr)
+		agc.Gate = -70
+		got := beeptest.Collect(agc, 0)
+		if level := rmsDB(got[len(got)-sr.N(1e9):]); math.Abs(level-tt.want) > 0.5 {
+			t.Errorf("AGC brings %v dBFS to %.2f dBFS, want %v dBFS", tt.input, level, tt.want)
+		}
+	}
+}
+
+func TestAGCGate(t *testing.T) {
+	const sr = beep.SampleRate(16000)
+	// speech followed by quiet noise
+	data := sine(sr, 440, sr.N(6e9))
+	for i := range data {
+		level := -30.0
+		if i >= sr.N(3e9) {
+			level = -60
+		}
+		data[i][0] *= fromDB(level)
+		data[i][1] *= fromDB(level)
+	}
+	agc := effects.NewAGC(beeptest.Samples(data), sr)
+	speech := beeptest.Collect(beep.Take(sr.N(3e9), agc), 0)
+	gain := agc.Gain()
+	noise := beeptest.Collect(agc, 0)
+	if math.Abs(agc.Gain()-gain) > 0.5 {
+		t.Errorf("AGC changes the gain from %.2f dB to %.2f dB during the noise", gain, agc.Gain())
+	}
+	if level := rmsDB(noise[len(noise)-sr.N(1e9):]); level > rmsDB(speech[len(speech)-sr.N(1e9):])-25 {
+		t.Errorf("AGC pumps up the noise to %.2f dBFS", level)
+	}
+}
+
+func TestAGCLiteral(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("AGC without NewAGC didn't panic")
+		}
+	}()
+	agc := &effects.AGC{Streamer: beeptest.Constant(100, [2]float64{0.1, 0.1}), Target: -20}
+	beeptest.Collect(agc, 0)
+}
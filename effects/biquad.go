@@ -0,0 +1,341 @@
+package effects
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/brotholo/beep"
+)
+
+// FilterType is the type of a biquad filter section.
+type FilterType int
+
+const (
+	// LowPass passes the frequencies below Freq.
+	LowPass FilterType = iota
+
+	// HighPass passes the frequencies above Freq.
+	HighPass
+
+	// BandPass passes the frequencies around Freq, with a 0 dB peak.
+	BandPass
+
+	// Notch rejects the frequencies around Freq.
+	Notch
+
+	// AllPass passes all frequencies, shifting their phase around Freq.
+	AllPass
+
+	// Peaking boosts or cuts the frequencies around Freq by Gain.
+	Peaking
+
+	// LowShelf boosts or cuts the frequencies below Freq by Gain.
+	LowShelf
+
+	// HighShelf boosts or cuts the frequencies above Freq by Gain.
+	HighShelf
+
+	// FirstOrderLowPass is a gentle 6 dB per octave low-pass. Q is not used.
+	FirstOrderLowPass
+
+	// FirstOrderHighPass is a gentle 6 dB per octave high-pass. Q is not used.
+	FirstOrderHighPass
+)
+
+// FilterParams are the parameters of a biquad filter section. The coefficients are computed by the
+// formulas of Robert Bristow-Johnson's Audio EQ Cookbook.
+type FilterParams struct {
+	Type FilterType
+
+	// Freq is the cutoff, center or corner frequency in Hz.
+	Freq float64
+
+	// Q is the quality factor. It sets the bandwidth of BandPass, Notch, AllPass and Peaking, and
+	// the resonance of LowPass, HighPass and the shelves. 1/√2 gives the flattest response.
+	Q float64
+
+	// Gain is the boost or cut in dB of Peaking, LowShelf and HighShelf.
+	Gain float64
+}
+
+// filterSmoothing is the time constant of the glide to new filter parameters, which avoids clicks
+// when they change.
+const filterSmoothing = 20 * time.Millisecond
+
+// filterControlBlock is the number of samples between the updates of gliding coefficients.
+const filterControlBlock = 32
+
+// Filter filters the wrapped Streamer by a cascade of biquad sections.
+//
+// The parameters of the sections can be changed while streaming. The Filter glides to the new
+// parameters instead of jumping, so the changes don't click. If the Filter is playing through the
+// speaker, lock the speaker before changing them.
+type Filter struct {
+	Streamer beep.Streamer
+
+	sr       beep.SampleRate
+	sections []filterSection
+	glide    float64
+}
+
+// filterSection is a biquad section of a Filter.
+type filterSection struct {
+	target  FilterParams
+	current FilterParams
+	coefs   biquadCoefs
+	state   [2][2]float64 // state of the transposed direct form II of the channels
+}
+
+// NewBiquad returns a Filter of s with a single biquad section.
+func NewBiquad(s beep.Streamer, sr beep.SampleRate, p FilterParams) *Filter {
+	return newFilter(s, sr, []FilterParams{p})
+}
+
+// NewButterworth returns a Butterworth LowPass or HighPass Filter of s. The slope of the Filter
+// is 6 dB per octave per order and the response at freq is -3 dB.
+func NewButterworth(s beep.Streamer, sr beep.SampleRate, typ FilterType, freq float64, order int) *Filter {
+	return newFilter(s, sr, butterworth(typ, freq, order))
+}
+
+// NewLinkwitzRiley returns a Linkwitz-Riley LowPass or HighPass Filter of s, a squared
+// Butterworth Filter of half the order. The order must be even. The response at freq is -6 dB, so
+// a low-pass and a high-pass Linkwitz-Riley Filter with the same freq and order make a crossover
+// which sums flat.
+func NewLinkwitzRiley(s beep.Streamer, sr beep.SampleRate, typ FilterType, freq float64, order int) *Filter {
+	if order < 2 || order%2 != 0 {
+		panic(fmt.Errorf("effects: invalid Linkwitz-Riley order: %d", order))
+	}
+	half := butterworth(typ, freq, order/2)
+	return newFilter(s, sr, append(half, half...))
+}
+
+// butterworth returns the sections of a Butterworth filter.
+func butterworth(typ FilterType, freq float64, order int) []FilterParams {
+	if typ != LowPass && typ != HighPass {
+		panic(fmt.Errorf("effects: invalid Butterworth filter type: %d", typ))
+	}
+	if order < 1 {
+		panic(fmt.Errorf("effects: invalid Butterworth order: %d", order))
+	}
+
+	var sections []FilterParams
+	if order%2 != 0 {
+		first := FirstOrderLowPass
+		if typ == HighPass {
+			first = FirstOrderHighPass
+		}
+		sections = append(sections, FilterParams{Type: first, Freq: freq})
+	}
+	for k := 1; k <= order/2; k++ {
+		// the angles of the conjugate pole pairs
+		theta := float64(2*k-1) * math.Pi / float64(2*order)
+		if order%2 != 0 {
+			theta = float64(k) * math.Pi / float64(order)
+		}
+		sections = append(sections, FilterParams{Type: typ, Freq: freq, Q: 1 / (2 * math.Cos(theta))})
+	}
+	return sections
+}
+
+func newFilter(s beep.Streamer, sr beep.SampleRate, params []FilterParams) *Filter {
+	f := &Filter{
+		Streamer: s,
+		sr:       sr,
+		sections: make([]filterSection, len(params)),
+		glide:    1 - math.Exp(-filterControlBlock/float64(sr.N(filterSmoothing))),
+	}
+	for i, p := range params {
+		f.sections[i] = filterSection{
+			target:  p,
+			current: p,
+			coefs:   newBiquadCoefs(p, sr),
+		}
+	}
+	return f
+}
+
+// NumSections returns the number of biquad sections of the Filter.
+func (f *Filter) NumSections() int {
+	return len(f.sections)
+}
+
+// Section returns the parameters of the i-th section of the Filter.
+func (f *Filter) Section(i int) FilterParams {
+	return f.sections[i].target
+}
+
+// SetSection changes the parameters of the i-th section of the Filter. A change of the Type takes
+// effect immediately, the other parameters glide.
+func (f *Filter) SetSection(i int, p FilterParams) {
+	sec := &f.sections[i]
+	if p.Type != sec.current.Type {
+		sec.current = p
+		sec.coefs = newBiquadCoefs(p, f.sr)
+	}
+	sec.target = p
+}
+
+// SetFreq changes the frequency of all sections of the Filter, for example the cutoff of a
+// Butterworth Filter.
+func (f *Filter) SetFreq(freq float64) {
+	for i := range f.sections {
+		f.sections[i].target.Freq = freq
+	}
+}
+
+// Response returns the gain of the Filter in dB at the frequency freq in Hz, for the target
+// parameters of its sections.
+func (f *Filter) Response(freq float64) float64 {
+	var db float64
+	for _, sec := range f.sections {
+		db += newBiquadCoefs(sec.target, f.sr).response(freq, f.sr)
+	}
+	return db
+}
+
+// Stream streams the wrapped Streamer filtered.
+func (f *Filter) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = f.Streamer.Stream(samples)
+	for i := range f.sections {
+		f.sections[i].apply(samples[:n], f.sr, f.glide)
+	}
+	return n, ok
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (f *Filter) Err() error {
+	return f.Streamer.Err()
+}
+
+func (sec *filterSection) apply(samples [][2]float64, sr beep.SampleRate, glide float64) {
+	for len(samples) > 0 {
+		block := samples
+		if sec.current != sec.target {
+			if len(block) > filterControlBlock {
+				block = block[:filterControlBlock]
+			}
+			sec.current = glideParams(sec.current, sec.target, glide)
+			sec.coefs = newBiquadCoefs(sec.current, sr)
+		}
+		c := sec.coefs
+		for i, x := range block {
+			for ch := range x {
+				z := &sec.state[ch]
+				y := c.b0*x[ch] + z[0]
+				z[0] = c.b1*x[ch] - c.a1*y + z[1]
+				z[1] = c.b2*x[ch] - c.a2*y
+				block[i][ch] = y
+			}
+		}
+		samples = samples[len(block):]
+	}
+}
+
+// glideParams moves the parameters cur a step towards target.
+func glideParams(cur, target FilterParams, glide float64) FilterParams {
+	cur.Freq = glideFreq(cur.Freq, target.Freq, glide)
+	cur.Q = glideValue(cur.Q, target.Q, glide)
+	cur.Gain = glideValue(cur.Gain, target.Gain, glide)
+	return cur
+}
+
+// glideValue moves v a step towards target, and snaps to target once it's close.
+func glideValue(v, target, glide float64) float64 {
+	v += (target - v) * glide
+	if math.Abs(target-v) < 1e-6*math.Max(1, math.Abs(target)) {
+		return target
+	}
+	return v
+}
+
+// glideFreq moves the frequency f a step towards target. It glides on the logarithmic scale, as
+// it's heard.
+func glideFreq(f, target, glide float64) float64 {
+	if f <= 0 || target <= 0 {
+		return target
+	}
+	f = math.Exp(glideValue(math.Log(f), math.Log(target), glide))
+	if f != target && math.Abs(f-target) < 1e-6*target {
+		f = target // rounding of exp and log
+	}
+	return f
+}
+
+// biquadCoefs are the coefficients of a biquad section normalized by a0.
+type biquadCoefs struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+func newBiquadCoefs(p FilterParams, sr beep.SampleRate) biquadCoefs {
+	nyquist := float64(sr) / 2
+	freq := math.Max(1e-3, math.Min(p.Freq, nyquist*0.999))
+	q := p.Q
+	if q <= 0 {
+		q = 1 / math.Sqrt2
+	}
+
+	w0 := 2 * math.Pi * freq / float64(sr)
+	sin, cos := math.Sincos(w0)
+	alpha := sin / (2 * q)
+	a := math.Pow(10, p.Gain/40)
+
+	var b0, b1, b2, a0, a1, a2 float64
+	switch p.Type {
+	case LowPass:
+		b0, b1, b2 = (1-cos)/2, 1-cos, (1-cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case HighPass:
+		b0, b1, b2 = (1+cos)/2, -(1 + cos), (1+cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case BandPass:
+		b0, b1, b2 = alpha, 0, -alpha
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case Notch:
+		b0, b1, b2 = 1, -2*cos, 1
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case AllPass:
+		b0, b1, b2 = 1-alpha, -2*cos, 1+alpha
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case Peaking:
+		b0, b1, b2 = 1+alpha*a, -2*cos, 1-alpha*a
+		a0, a1, a2 = 1+alpha/a, -2*cos, 1-alpha/a
+	case LowShelf:
+		s := 2 * math.Sqrt(a) * alpha
+		b0 = a * ((a + 1) - (a-1)*cos + s)
+		b1 = 2 * a * ((a - 1) - (a+1)*cos)
+		b2 = a * ((a + 1) - (a-1)*cos - s)
+		a0 = (a + 1) + (a-1)*cos + s
+		a1 = -2 * ((a - 1) + (a+1)*cos)
+		a2 = (a + 1) + (a-1)*cos - s
+	case HighShelf:
+		s := 2 * math.Sqrt(a) * alpha
+		b0 = a * ((a + 1) + (a-1)*cos + s)
+		b1 = -2 * a * ((a - 1) + (a+1)*cos)
+		b2 = a * ((a + 1) + (a-1)*cos - s)
+		a0 = (a + 1) - (a-1)*cos + s
+		a1 = 2 * ((a - 1) - (a+1)*cos)
+		a2 = (a + 1) - (a-1)*cos - s
+	case FirstOrderLowPass, FirstOrderHighPass:
+		k := math.Tan(w0 / 2)
+		if p.Type == FirstOrderLowPass {
+			b0, b1 = k, k
+		} else {
+			b0, b1 = 1, -1
+		}
+		a0, a1 = k+1, k-1
+	default:
+		panic(fmt.Errorf("effects: invalid filter type: %d", p.Type))
+	}
+
+	return biquadCoefs{b0: b0 / a0, b1: b1 / a0, b2: b2 / a0, a1: a1 / a0, a2: a2 / a0}
+}
+
+// response returns the gain in dB of the section at the frequency freq.
+func (c biquadCoefs) response(freq float64, sr beep.SampleRate) float64 {
+	z := cmplx.Exp(complex(0, -2*math.Pi*freq/float64(sr))) // z^-1
+	num := complex(c.b0, 0) + complex(c.b1, 0)*z + complex(c.b2, 0)*z*z
+	den := 1 + complex(c.a1, 0)*z + complex(c.a2, 0)*z*z
+	return 20 * math.Log10(cmplx.Abs(num/den))
+}
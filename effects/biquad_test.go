@@ -0,0 +1,87 @@
+package effects_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+)
+
+// sine returns num samples of a sine wave of the frequency freq.
+func sine(sr beep.SampleRate, freq float64, num int) [][2]float64 {
+	data := make([][2]float64, num)
+	for i := range data {
+		v := math.Sin(2 * math.Pi * freq * float64(i) / float64(sr))
+		data[i] = [2]float64{v, v}
+	}
+	return data
+}
+
+// peakDB returns the peak of the samples in dB.
+func peakDB(samples [][2]float64) float64 {
+	var peak float64
+	for _, s := range samples {
+		peak = math.Max(peak, math.Max(math.Abs(s[0]), math.Abs(s[1])))
+	}
+	return 20 * math.Log10(peak)
+}
+
+func TestFilterResponse(t *testing.T) {
+	const sr = beep.SampleRate(48000)
+	tests := []struct {
+		name   string
+		filter *effects.Filter
+		freq   float64
+		want   float64
+	}{
+		{"butterworth 4 cutoff", effects.NewButterworth(nil, sr, effects.LowPass, 1000, 4), 1000, -3.01},
+		{"butterworth 3 cutoff", effects.NewButterworth(nil, sr, effects.HighPass, 1000, 3), 1000, -3.01},
+		{"butterworth 4 passband", effects.NewButterworth(nil, sr, effects.LowPass, 1000, 4), 100, 0},
+		{"linkwitz-riley 4 cutoff", effects.NewLinkwitzRiley(nil, sr, effects.HighPass, 1000, 4), 1000, -6.02},
+		{"peaking", effects.NewBiquad(nil, sr, effects.FilterParams{Type: effects.Peaking, Freq: 1000, Q: 1, Gain: 6}), 1000, 6},
+		{"low shelf", effects.NewBiquad(nil, sr, effects.FilterParams{Type: effects.LowShelf, Freq: 1000, Gain: -12}), 20, -12},
+		{"high shelf", effects.NewBiquad(nil, sr, effects.FilterParams{Type: effects.HighShelf, Freq: 1000, Gain: 12}), 20000, 12},
+		{"band-pass", effects.NewBiquad(nil, sr, effects.FilterParams{Type: effects.BandPass, Freq: 1000, Q: 2}), 1000, 0},
+		{"all-pass", effects.NewBiquad(nil, sr, effects.FilterParams{Type: effects.AllPass, Freq: 1000, Q: 2}), 3000, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Response(tt.freq); math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("%s: response at %v Hz is %.2f dB, want %.2f dB", tt.name, tt.freq, got, tt.want)
+		}
+	}
+
+	notch := effects.NewBiquad(nil, sr, effects.FilterParams{Type: effects.Notch, Freq: 1000, Q: 2})
+	if got := notch.Response(1000); got > -60 {
+		t.Errorf("notch: response at the center is %.2f dB, want a deep cut", got)
+	}
+}
+
+func TestFilterStream(t *testing.T) {
+	const sr = beep.SampleRate(48000)
+	f := effects.NewButterworth(beeptest.Samples(sine(sr, 4000, 4800)), sr, effects.LowPass, 1000, 4)
+	want := f.Response(4000)
+	got := beeptest.Collect(f, 100)
+	if db := peakDB(got[2400:]); math.Abs(db-want) > 0.2 {
+		t.Errorf("filtered sine peaks at %.2f dB, want %.2f dB", db, want)
+	}
+}
+
+func TestFilterGlide(t *testing.T) {
+	const sr = beep.SampleRate(48000)
+	f := effects.NewBiquad(beeptest.Samples(sine(sr, 200, 24000)), sr, effects.FilterParams{Type: effects.LowPass, Freq: 5000})
+	first := beeptest.Collect(beep.Take(4800, f), 0)
+	f.SetFreq(100)
+	second := beeptest.Collect(f, 0)
+
+	// the cutoff glides, so the level doesn't jump at the change
+	prev := first[len(first)-1][0] - first[len(first)-2][0]
+	step := second[0][0] - first[len(first)-1][0]
+	if math.Abs(step-prev) > 0.01 {
+		t.Errorf("the output jumps by %v at the change, the slope before is %v", step, prev)
+	}
+	if db, want := peakDB(second[14400:]), f.Response(200); math.Abs(db-want) > 0.2 {
+		t.Errorf("after the glide the sine peaks at %.2f dB, want %.2f dB", db, want)
+	}
+}
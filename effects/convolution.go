@@ -0,0 +1,226 @@
+package effects
+
+import (
+	"io"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/internal/fft"
+	"github.com/brotholo/beep/wav"
+	"github.com/pkg/errors"
+)
+
+// ConvolutionBlockSize is the size of the blocks in which ConvolutionReverb processes the audio.
+// It's also the latency of ConvolutionReverb.
+const ConvolutionBlockSize = 512
+
+// DecodeImpulseResponse decodes an impulse response for ConvolutionReverb from a WAVE file. A
+// mono impulse response applies to both channels, a stereo one applies each channel to the
+// corresponding channel of the audio. DecodeImpulseResponse doesn't close r.
+func DecodeImpulseResponse(r io.Reader) (*beep.Buffer, error) {
+	s, format, err := wav.Decode(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "effects")
+	}
+	ir := beep.NewBuffer(format)
+	ir.Append(s)
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "effects")
+	}
+	return ir, nil
+}
+
+// ConvolutionReverb convolves the wrapped Streamer with an impulse response, such as a recording
+// of a room, reproducing the reverberation of the room.
+//
+// The convolution is computed with the uniformly partitioned overlap-save method: the impulse
+// response is split into blocks of ConvolutionBlockSize samples, which are convolved with the
+// audio in the frequency domain. This keeps the work per sample low even for impulse responses
+// several seconds long, at the cost of delaying the audio by ConvolutionBlockSize samples.
+//
+// When the wrapped Streamer is drained, ConvolutionReverb streams the rest of the reverberation,
+// as long as the impulse response, and then drains too.
+type ConvolutionReverb struct {
+	Streamer beep.Streamer
+
+	// Wet and Dry are the gains of the convolved and of the original signal. They can be changed
+	// while streaming. If the ConvolutionReverb is playing through the speaker, lock the speaker
+	// before changing them.
+	Wet float64
+	Dry float64
+
+	conv  [2]*partitionedConvolution
+	parts [2][][]complex128 // spectra of the blocks of the impulse response
+	block [2][]float64
+	res   []float64
+
+	in  [][2]float64 // input block being filled
+	out [][2]float64 // output block being streamed
+	pos int
+
+	tail tail
+}
+
+// NewConvolutionReverb returns a ConvolutionReverb of s with the impulse response ir, resampled
+// to sr if needed. Wet and Dry are initially 1 and 0.
+func NewConvolutionReverb(s beep.Streamer, sr beep.SampleRate, ir *beep.Buffer) *ConvolutionReverb {
+	const b = ConvolutionBlockSize
+
+	var irs beep.Streamer = ir.Streamer(0, ir.Len())
+	if irSR := ir.Format().SampleRate; irSR != sr {
+		irs = beep.Resample(4, irSR, sr, irs)
+	}
+	var response [][2]float64
+	buf := make([][2]float64, b)
+	for {
+		n, ok := irs.Stream(buf)
+		response = append(response, buf[:n]...)
+		if !ok {
+			break
+		}
+	}
+
+	cr := &ConvolutionReverb{
+		Streamer: s,
+		Wet:      1,
+		res:      make([]float64, b),
+		in:       make([][2]float64, b),
+		out:      make([][2]float64, b),
+		tail:     tail{length: len(response) + b - 1},
+	}
+	h := make([]float64, len(response))
+	for c := range cr.conv {
+		for i, x := range response {
+			h[i] = x[c]
+		}
+		cr.conv[c] = newPartitionedConvolution(b, len(h))
+		cr.parts[c] = cr.conv[c].partition(h, nil)
+		cr.block[c] = make([]float64, b)
+	}
+	return cr
+}
+
+// Stream streams the wrapped Streamer convolved with the impulse response.
+func (cr *ConvolutionReverb) Stream(samples [][2]float64) (n int, ok bool) {
+	return cr.tail.stream(cr.Streamer, samples, cr.process)
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (cr *ConvolutionReverb) Err() error {
+	return cr.Streamer.Err()
+}
+
+func (cr *ConvolutionReverb) process(samples [][2]float64) {
+	for i, x := range samples {
+		samples[i] = cr.out[cr.pos]
+		cr.in[cr.pos] = x
+		cr.pos++
+		if cr.pos == len(cr.in) {
+			cr.convolve()
+			cr.pos = 0
+		}
+	}
+}
+
+// convolve convolves the full input block and replaces the output block with the result.
+func (cr *ConvolutionReverb) convolve() {
+	for c, conv := range cr.conv {
+		for i, x := range cr.in {
+			cr.block[c][i] = x[c]
+		}
+		conv.push(cr.block[c])
+		conv.convolve(cr.parts[c], cr.res)
+		for i, y := range cr.res {
+			cr.out[i][c] = y*cr.Wet + cr.in[i][c]*cr.Dry
+		}
+	}
+}
+
+// partitionedConvolution convolves a signal with impulse responses by the uniformly partitioned
+// overlap-save method. The impulse responses are split into blocks (partitions) of b samples,
+// whose spectra are multiplied with the spectra of the recent input blocks, kept in a frequency
+// domain delay line. A single delay line serves any number of impulse responses of the same input.
+type partitionedConvolution struct {
+	plan *fft.Plan
+	b    int
+	fdl  [][]complex128 // spectra of the recent input blocks, a ring buffer
+	fpos int
+	prev []float64 // previous input block
+	work []complex128
+	acc  []complex128
+}
+
+// newPartitionedConvolution returns a partitionedConvolution in blocks of b samples, a power of
+// two, for impulse responses up to length samples long.
+func newPartitionedConvolution(b, length int) *partitionedConvolution {
+	numParts := (length + b - 1) / b
+	if numParts < 1 {
+		numParts = 1
+	}
+	pc := &partitionedConvolution{
+		plan: fft.NewPlan(2 * b),
+		b:    b,
+		fdl:  make([][]complex128, numParts),
+		prev: make([]float64, b),
+		work: make([]complex128, 2*b),
+		acc:  make([]complex128, 2*b),
+	}
+	for p := range pc.fdl {
+		pc.fdl[p] = make([]complex128, 2*b)
+	}
+	return pc
+}
+
+// partition computes the spectra of the partitions of the impulse response h into parts, which
+// is allocated if nil, and returns it. h must not be longer than the length passed to
+// newPartitionedConvolution.
+func (pc *partitionedConvolution) partition(h []float64, parts [][]complex128) [][]complex128 {
+	if parts == nil {
+		parts = make([][]complex128, len(pc.fdl))
+		for p := range parts {
+			parts[p] = make([]complex128, 2*pc.b)
+		}
+	}
+	for p, part := range parts {
+		for i := range part {
+			part[i] = 0
+		}
+		for i := 0; i < pc.b && p*pc.b+i < len(h); i++ {
+			part[i] = complex(h[p*pc.b+i], 0)
+		}
+		pc.plan.Forward(part)
+	}
+	return parts
+}
+
+// push adds the next input block of b samples to the delay line.
+func (pc *partitionedConvolution) push(in []float64) {
+	b := pc.b
+	pc.fpos = (pc.fpos + len(pc.fdl) - 1) % len(pc.fdl)
+	// the spectrum of the previous and the current block, the first half of the output of the
+	// inverse transform is the circular convolution garbage
+	for i := 0; i < b; i++ {
+		pc.work[i] = complex(pc.prev[i], 0)
+		pc.work[b+i] = complex(in[i], 0)
+	}
+	copy(pc.prev, in)
+	pc.plan.Forward(pc.work)
+	copy(pc.fdl[pc.fpos], pc.work)
+}
+
+// convolve computes the block of the convolution of the input with the impulse response of
+// parts, which ends with the last pushed input block, into out.
+func (pc *partitionedConvolution) convolve(parts [][]complex128, out []float64) {
+	for i := range pc.acc {
+		pc.acc[i] = 0
+	}
+	for p, part := range parts {
+		x := pc.fdl[(pc.fpos+p)%len(pc.fdl)]
+		for i := range pc.acc {
+			pc.acc[i] += x[i] * part[i]
+		}
+	}
+	pc.plan.Inverse(pc.acc)
+	for i := range out {
+		out[i] = real(pc.acc[pc.b+i])
+	}
+}
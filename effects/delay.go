@@ -0,0 +1,241 @@
+package effects
+
+import (
+	"math"
+	"time"
+
+	"github.com/brotholo/beep"
+)
+
+// DelayMode is the routing of the echoes of a Delay.
+type DelayMode int
+
+const (
+	// MonoDelay echoes the sum of both channels to both channels.
+	MonoDelay DelayMode = iota
+
+	// StereoDelay echoes each channel separately, keeping the stereo image.
+	StereoDelay
+
+	// PingPongDelay echoes the sum of both channels alternately to the left and the right channel.
+	PingPongDelay
+)
+
+// Note is a note value as a fraction of a whole note, for example 1.0/4 for a quarter note.
+type Note float64
+
+// Common note values.
+const (
+	WholeNote     Note = 1
+	HalfNote      Note = 1.0 / 2
+	QuarterNote   Note = 1.0 / 4
+	EighthNote    Note = 1.0 / 8
+	SixteenthNote Note = 1.0 / 16
+)
+
+// Dotted returns the dotted note value, one and a half of n.
+func (n Note) Dotted() Note {
+	return n * 3 / 2
+}
+
+// Triplet returns the triplet note value, two thirds of n.
+func (n Note) Triplet() Note {
+	return n * 2 / 3
+}
+
+// Duration returns the duration of the note at the tempo bpm in quarter notes per minute.
+func (n Note) Duration(bpm float64) time.Duration {
+	return time.Duration(float64(n) * 4 * 60 / bpm * float64(time.Second))
+}
+
+// delayGlide is the time constant of the glide to a new delay time, which avoids clicks when the
+// delay time changes.
+const delayGlide = 50 * time.Millisecond
+
+// Delay repeats the wrapped Streamer in echoes. Each echo is the previous one attenuated by the
+// feedback and filtered by the filter in the feedback path, the way tape and analog delays darken
+// their repeats.
+//
+// The parameters can be changed while streaming. When the delay time changes, the Delay glides to
+// it instead of jumping, which bends the pitch of the echoes briefly, like a tape delay, but
+// doesn't click. If the Delay is playing through the speaker, lock the speaker before changing
+// the parameters.
+//
+// When the wrapped Streamer is drained, Delay streams the echoes until they decay to silence and
+// then drains too.
+type Delay struct {
+	Streamer beep.Streamer
+
+	// Mode is the routing of the echoes.
+	Mode DelayMode
+
+	// Time is the time between the echoes. It's limited to the maximum delay time of the Delay.
+	// Use Note.Duration to sync it to a tempo.
+	Time time.Duration
+
+	// Feedback is the gain of each echo relative to the previous one, from 0 (single echo) to
+	// below 1.
+	Feedback float64
+
+	// LowCut and HighCut are the cutoff frequencies in Hz of the highpass and the lowpass filter
+	// in the feedback path. 0 disables the filter.
+	LowCut  float64
+	HighCut float64
+
+	// ModDepth and ModRate modulate the delay time by a sine wave of the given depth and
+	// frequency in Hz, which makes the echoes wobble like those of a worn tape.
+	ModDepth time.Duration
+	ModRate  float64
+
+	// Wet and Dry are the gains of the echoes and of the original signal.
+	Wet float64
+	Dry float64
+
+	sr       beep.SampleRate
+	lines    [2]delayLine
+	current  float64 // delay time in samples, gliding to Time
+	glide    float64
+	mod      LFO
+	lowpass  [2]float64 // state of the feedback filters
+	highpass [2]float64
+	tail     tail
+}
+
+// NewDelay returns a Delay of s with the delay time delay, which can be changed later up to
+// maxDelay. Feedback is initially 0.5, Wet and Dry are 1, the filters and the modulation are
+// off.
+func NewDelay(s beep.Streamer, sr beep.SampleRate, mode DelayMode, delay, maxDelay time.Duration) *Delay {
+	if maxDelay < delay {
+		maxDelay = delay
+	}
+	size := sr.N(maxDelay) + 2 // room for the interpolation
+	d := &Delay{
+		Streamer: s,
+		Mode:     mode,
+		Time:     delay,
+		Feedback: 0.5,
+		Wet:      1,
+		Dry:      1,
+		sr:       sr,
+		lines:    [2]delayLine{newDelayLine(size), newDelayLine(size)},
+		glide:    1 - math.Exp(-1/float64(sr.N(delayGlide))),
+		mod:      LFO{sr: sr, Depth: 1},
+		tail:     tail{length: -1, window: size},
+	}
+	d.current = d.target(0)
+	return d
+}
+
+// Stream streams the wrapped Streamer with the echoes.
+func (d *Delay) Stream(samples [][2]float64) (n int, ok bool) {
+	return d.tail.stream(d.Streamer, samples, d.process)
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (d *Delay) Err() error {
+	return d.Streamer.Err()
+}
+
+func (d *Delay) process(samples [][2]float64) {
+	lowpass := onePoleCoef(d.HighCut, d.sr)
+	highpass := onePoleCoef(d.LowCut, d.sr)
+	modDepth := float64(d.sr.N(d.ModDepth))
+	d.mod.Rate = d.ModRate
+	target := d.target(modDepth)
+
+	for i, x := range samples {
+		d.current += (target - d.current) * d.glide
+		delay := d.current
+		if modDepth > 0 {
+			delay += modDepth * d.mod.Next()[0]
+		}
+
+		var y, fb [2]float64
+		for c := range y {
+			y[c] = d.lines[c].read(delay)
+			fb[c] = d.filter(c, y[c], lowpass, highpass) * d.Feedback
+		}
+
+		mono := (x[0] + x[1]) / 2
+		switch d.Mode {
+		case StereoDelay:
+			d.lines[0].write(x[0] + fb[0])
+			d.lines[1].write(x[1] + fb[1])
+		case PingPongDelay:
+			d.lines[0].write(mono + fb[1])
+			d.lines[1].write(fb[0])
+		default:
+			d.lines[0].write(mono + fb[0])
+			d.lines[1].write(0)
+			y[1] = y[0]
+		}
+
+		samples[i][0] = x[0]*d.Dry + y[0]*d.Wet
+		samples[i][1] = x[1]*d.Dry + y[1]*d.Wet
+	}
+}
+
+// target returns the delay time in samples the Delay glides to, leaving room for the modulation.
+func (d *Delay) target(modDepth float64) float64 {
+	t := float64(d.sr.N(d.Time))
+	return math.Max(1+modDepth, math.Min(t, d.lines[0].max()-modDepth))
+}
+
+// filter applies the filters of the feedback path to the sample x of the channel c.
+func (d *Delay) filter(c int, x, lowpass, highpass float64) float64 {
+	if lowpass > 0 {
+		d.lowpass[c] += (x - d.lowpass[c]) * lowpass
+		x = d.lowpass[c]
+	}
+	if highpass > 0 {
+		d.highpass[c] += (x - d.highpass[c]) * highpass
+		x -= d.highpass[c]
+	}
+	return x
+}
+
+// onePoleCoef returns the coefficient of a one-pole filter with the cutoff frequency freq, or 0
+// if freq is 0.
+func onePoleCoef(freq float64, sr beep.SampleRate) float64 {
+	if freq <= 0 {
+		return 0
+	}
+	return 1 - math.Exp(-2*math.Pi*freq/float64(sr))
+}
+
+// delayLine is a delay line which can be read at fractional delays.
+type delayLine struct {
+	buf []float64
+	pos int
+}
+
+func newDelayLine(size int) delayLine {
+	if size < 3 {
+		size = 3
+	}
+	return delayLine{buf: make([]float64, size)}
+}
+
+// max returns the longest delay in samples the delay line can be read at.
+func (l *delayLine) max() float64 {
+	return float64(len(l.buf) - 2)
+}
+
+// read returns the sample written delay samples ago, interpolating linearly between samples. The
+// delay is limited to 1 to max().
+func (l *delayLine) read(delay float64) float64 {
+	delay = math.Max(1, math.Min(delay, l.max()))
+	pos := float64(l.pos) - delay
+	if pos < 0 {
+		pos += float64(len(l.buf))
+	}
+	i := int(pos)
+	frac := pos - float64(i)
+	return l.buf[i]*(1-frac) + l.buf[(i+1)%len(l.buf)]*frac
+}
+
+// write appends a sample to the delay line.
+func (l *delayLine) write(x float64) {
+	l.buf[l.pos] = x
+	l.pos = (l.pos + 1) % len(l.buf)
+}
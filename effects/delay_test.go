@@ -0,0 +1,73 @@
+package effects_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+)
+
+func TestDelay(t *testing.T) {
+	const sr = beep.SampleRate(1000)
+	impulse := [][2]float64{{1, 1}}
+
+	tests := []struct {
+		mode   effects.DelayMode
+		echoes map[int][2]float64
+	}{
+		{effects.MonoDelay, map[int][2]float64{10: {1, 1}, 20: {0.5, 0.5}, 30: {0.25, 0.25}}},
+		{effects.StereoDelay, map[int][2]float64{10: {1, 1}, 20: {0.5, 0.5}, 30: {0.25, 0.25}}},
+		{effects.PingPongDelay, map[int][2]float64{10: {1, 0}, 20: {0, 0.5}, 30: {0.25, 0}}},
+	}
+	for _, tt := range tests {
+		d := effects.NewDelay(beeptest.Samples(impulse), sr, tt.mode, 10*time.Millisecond, time.Second)
+		d.Dry = 0
+		v := beeptest.Validate(d)
+		got := beeptest.Collect(v, 7)
+		if !v.Valid() {
+			t.Fatalf("mode %v: delay violates the Streamer contract: %v", tt.mode, v.Violations())
+		}
+		if len(got) < 31 || len(got) > sr.N(2*time.Second) {
+			t.Fatalf("mode %v: delay streamed %d samples", tt.mode, len(got))
+		}
+		for i := 0; i <= 30; i++ {
+			want := tt.echoes[i]
+			if math.Abs(got[i][0]-want[0]) > 1e-12 || math.Abs(got[i][1]-want[1]) > 1e-12 {
+				t.Errorf("mode %v: sample %d is %v, want %v", tt.mode, i, got[i], want)
+			}
+		}
+	}
+}
+
+func TestDelayGlide(t *testing.T) {
+	const sr = beep.SampleRate(44100)
+	d := effects.NewDelay(beep.Silence(sr.N(time.Second)), sr, effects.StereoDelay, 100*time.Millisecond, time.Second)
+	d.Time = 300 * time.Millisecond
+	d.ModDepth, d.ModRate = 2*time.Millisecond, 0.5
+	d.LowCut, d.HighCut = 100, 5000
+	v := beeptest.Validate(d)
+	beeptest.Collect(v, 0)
+	if !v.Valid() {
+		t.Fatalf("delay violates the Streamer contract: %v", v.Violations())
+	}
+}
+
+func TestNoteDuration(t *testing.T) {
+	tests := []struct {
+		note effects.Note
+		want time.Duration
+	}{
+		{effects.QuarterNote, 500 * time.Millisecond},
+		{effects.EighthNote.Dotted(), 375 * time.Millisecond},
+		{effects.QuarterNote.Triplet(), 333333333},
+		{effects.WholeNote, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := tt.note.Duration(120); got != tt.want {
+			t.Errorf("%v note at 120 BPM lasts %v, want %v", tt.note, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package effects
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/brotholo/beep"
+)
+
+// Shaper is a waveshaping curve, which maps an input sample to an output sample. Shapers which map
+// 0 to 0 keep silence silent.
+type Shaper func(x float64) float64
+
+// SoftClip saturates smoothly with the hyperbolic tangent, approaching ±1.
+func SoftClip(x float64) float64 {
+	return math.Tanh(x)
+}
+
+// HardClip clips at ±1.
+func HardClip(x float64) float64 {
+	return math.Max(-1, math.Min(x, 1))
+}
+
+// Tube returns an asymmetric saturation curve, which adds even harmonics like an overdriven tube
+// amplifier. The bias, typically 0.1 to 0.5, sets the asymmetry, 0 is the same as SoftClip.
+func Tube(bias float64) Shaper {
+	offset := math.Tanh(bias)
+	return func(x float64) float64 {
+		return math.Tanh(x+bias) - offset
+	}
+}
+
+// Foldback returns a curve which folds the signal exceeding ±threshold back into the range,
+// instead of clipping it, for a harsh metallic sound.
+func Foldback(threshold float64) Shaper {
+	return func(x float64) float64 {
+		if x > threshold || x < -threshold {
+			return math.Abs(math.Abs(math.Mod(x-threshold, threshold*4))-threshold*2) - threshold
+		}
+		return x
+	}
+}
+
+// Curve returns a custom curve from a table of output values for inputs evenly spaced from -1 to
+// +1, interpolated linearly. Inputs outside of the range map to the first and the last value.
+func Curve(table []float64) Shaper {
+	table = append([]float64(nil), table...)
+	return func(x float64) float64 {
+		switch len(table) {
+		case 0:
+			return x
+		case 1:
+			return table[0]
+		}
+		pos := (x + 1) / 2 * float64(len(table)-1)
+		if pos <= 0 {
+			return table[0]
+		}
+		if pos >= float64(len(table)-1) {
+			return table[len(table)-1]
+		}
+		i := int(pos)
+		frac := pos - float64(i)
+		return table[i]*(1-frac) + table[i+1]*frac
+	}
+}
+
+// oversamplingQuality is the quality of the resampling for the oversampling of Distortion.
+const oversamplingQuality = 4
+
+// Distortion distorts the wrapped Streamer by a waveshaping curve.
+//
+// Waveshaping creates harmonics, which alias back into the audible range when they exceed the
+// Nyquist frequency. Distortion can oversample the audio to reduce the aliasing: the audio is
+// resampled to a multiple of the sample rate, shaped, low-pass filtered and resampled back.
+//
+// The parameters can be changed while streaming. If the Distortion is playing through the
+// speaker, lock the speaker before changing them.
+type Distortion struct {
+	// Shaper is the waveshaping curve.
+	Shaper Shaper
+
+	// Drive is the gain in dB before the shaping, which sets the amount of distortion.
+	Drive float64
+
+	// Output is the gain in dB after the shaping.
+	Output float64
+
+	// Mix is the gain of the distorted signal, the original signal is mixed in with 1-Mix.
+	Mix float64
+
+	streamer beep.Streamer
+	out      beep.Streamer
+}
+
+// NewDistortion returns a Distortion of s by the shaper, with Drive and Output 0 dB and Mix 1. The
+// oversampling factor is 1 (none), 2, 4 or 8, other values panic.
+func NewDistortion(s beep.Streamer, sr beep.SampleRate, shaper Shaper, oversampling int) *Distortion {
+	d := &Distortion{Shaper: shaper, Mix: 1, streamer: s}
+	switch oversampling {
+	case 1:
+		d.out = &shapeStreamer{d: d, s: s}
+	case 2, 4, 8:
+		high := sr * beep.SampleRate(oversampling)
+		up := beep.Resample(oversamplingQuality, sr, high, s)
+		shaped := NewButterworth(&shapeStreamer{d: d, s: up}, high, LowPass, 0.45*float64(sr), 8)
+		d.out = beep.Resample(oversamplingQuality, high, sr, shaped)
+	default:
+		panic(fmt.Errorf("effects: invalid oversampling factor: %d", oversampling))
+	}
+	return d
+}
+
+// Stream streams the wrapped Streamer distorted.
+func (d *Distortion) Stream(samples [][2]float64) (n int, ok bool) {
+	return d.out.Stream(samples)
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (d *Distortion) Err() error {
+	return d.streamer.Err()
+}
+
+// shapeStreamer applies the shaping of a Distortion, at the oversampled rate.
+type shapeStreamer struct {
+	d *Distortion
+	s beep.Streamer
+}
+
+func (ss *shapeStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = ss.s.Stream(samples)
+	drive, output, mix := fromDB(ss.d.Drive), fromDB(ss.d.Output), ss.d.Mix
+	for i := range samples[:n] {
+		for c, x := range samples[i] {
+			samples[i][c] = x*(1-mix) + ss.d.Shaper(x*drive)*output*mix
+		}
+	}
+	return n, ok
+}
+
+func (ss *shapeStreamer) Err() error {
+	return ss.s.Err()
+}
+
+// Bitcrusher reduces the bit depth and the sample rate of the wrapped Streamer, for the lo-fi
+// sound of old samplers and game consoles. The sample rate is reduced by holding samples, without
+// filtering, so the aliasing is part of the sound.
+//
+// The parameters can be changed while streaming. If the Bitcrusher is playing through the
+// speaker, lock the speaker before changing them.
+type Bitcrusher struct {
+	Streamer beep.Streamer
+
+	// Bits is the bit depth, for example 8. It can be fractional for finer control. 0 disables
+	// the bit depth reduction.
+	Bits float64
+
+	// Rate is the reduced sample rate in Hz. 0 disables the sample rate reduction.
+	Rate float64
+
+	// Mix is the gain of the crushed signal, the original signal is mixed in with 1-Mix.
+	Mix float64
+
+	sr    beep.SampleRate
+	phase float64
+	held  [2]float64
+}
+
+// NewBitcrusher returns a Bitcrusher of s reducing it to bits and rate, with Mix 1.
+func NewBitcrusher(s beep.Streamer, sr beep.SampleRate, bits, rate float64) *Bitcrusher {
+	return &Bitcrusher{Streamer: s, Bits: bits, Rate: rate, Mix: 1, sr: sr, phase: 1}
+}
+
+// Stream streams the wrapped Streamer crushed.
+func (b *Bitcrusher) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = b.Streamer.Stream(samples)
+	step := 1.0
+	if b.Rate > 0 {
+		step = b.Rate / float64(b.sr)
+	}
+	levels := 0.0
+	if b.Bits > 0 {
+		levels = math.Pow(2, b.Bits-1)
+	}
+
+	for i, x := range samples[:n] {
+		if b.phase >= 1 {
+			// take a new sample
+			b.phase -= math.Floor(b.phase)
+			b.held = x
+			if levels > 0 {
+				for c := range b.held {
+					b.held[c] = math.Round(b.held[c]*levels) / levels
+				}
+			}
+		}
+		b.phase += step
+		for c := range x {
+			samples[i][c] = x[c]*(1-b.Mix) + b.held[c]*b.Mix
+		}
+	}
+	return n, ok
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (b *Bitcrusher) Err() error {
+	return b.Streamer.Err()
+}
@@ -0,0 +1,94 @@
+package effects_test
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+)
+
+func TestShapers(t *testing.T) {
+	tests := []struct {
+		name   string
+		shaper effects.Shaper
+		in     []float64
+		want   []float64
+	}{
+		{"hard clip", effects.HardClip, []float64{-2, -0.5, 0.5, 2}, []float64{-1, -0.5, 0.5, 1}},
+		{"soft clip", effects.SoftClip, []float64{0, 1}, []float64{0, math.Tanh(1)}},
+		{"tube", effects.Tube(0.5), []float64{0, 1}, []float64{0, math.Tanh(1.5) - math.Tanh(0.5)}},
+		{"foldback", effects.Foldback(0.5), []float64{0.25, 0.75, 1, -0.75}, []float64{0.25, 0.25, 0, -0.25}},
+		{"curve", effects.Curve([]float64{-1, 0, 0.5}), []float64{-2, -0.5, 0.5, 3}, []float64{-1, -0.5, 0.25, 0.5}},
+	}
+	for _, tt := range tests {
+		for i, x := range tt.in {
+			if got := tt.shaper(x); math.Abs(got-tt.want[i]) > 1e-12 {
+				t.Errorf("%s(%v) = %v, want %v", tt.name, x, got, tt.want[i])
+			}
+		}
+	}
+
+	// asymmetric
+	tube := effects.Tube(0.5)
+	if tube(1) == -tube(-1) {
+		t.Error("tube curve is symmetric")
+	}
+}
+
+func TestDistortion(t *testing.T) {
+	const sr = beep.SampleRate(44100)
+	data := sine(sr, 100, 4410)
+	d := effects.NewDistortion(beeptest.Samples(data), sr, effects.HardClip, 1)
+	d.Drive, d.Output = 20*math.Log10(2), -20*math.Log10(2)
+
+	want := make([][2]float64, len(data))
+	for i, s := range data {
+		v := math.Max(-1, math.Min(2*s[0], 1)) / 2
+		want[i] = [2]float64{v, v}
+	}
+	beeptest.AssertStreamer(t, d, want, 1e-12)
+}
+
+// magnitudeAt returns the magnitude of the frequency freq in the left channel of the samples.
+func magnitudeAt(sr beep.SampleRate, samples [][2]float64, freq float64) float64 {
+	var sum complex128
+	for i, s := range samples {
+		sum += complex(s[0], 0) * cmplx.Exp(complex(0, -2*math.Pi*freq*float64(i)/float64(sr)))
+	}
+	return cmplx.Abs(sum) / float64(len(samples))
+}
+
+func TestDistortionOversampling(t *testing.T) {
+	const sr = beep.SampleRate(44100)
+	data := sine(sr, 4900, 8820)
+
+	// the 9th harmonic at 44100 Hz aliases to 0 Hz, the 7th one to 9800 Hz
+	alias := func(oversampling int) float64 {
+		d := effects.NewDistortion(beeptest.Samples(data), sr, effects.HardClip, oversampling)
+		d.Drive = 20
+		v := beeptest.Validate(d)
+		got := beeptest.Collect(v, 0)
+		if !v.Valid() {
+			t.Fatalf("%dx: distortion violates the Streamer contract: %v", oversampling, v.Violations())
+		}
+		if len(got) < len(data)-16 || len(got) > len(data)+16 {
+			t.Fatalf("%dx: distortion streamed %d samples, want about %d", oversampling, len(got), len(data))
+		}
+		return magnitudeAt(sr, got[1000:len(data)-1000], 9800)
+	}
+
+	plain, over := alias(1), alias(8)
+	if over > plain/10 {
+		t.Errorf("8x oversampling reduces the aliasing from %v to %v only", plain, over)
+	}
+}
+
+func TestBitcrusher(t *testing.T) {
+	data := [][2]float64{{0.1, -0.1}, {0.2, -0.2}, {0.3, -0.3}, {0.4, -0.4}, {0.6, -0.6}, {0.7, -0.7}}
+	b := effects.NewBitcrusher(beeptest.Samples(data), 6, 2, 3)
+	want := [][2]float64{{0, 0}, {0, 0}, {0.5, -0.5}, {0.5, -0.5}, {0.5, -0.5}, {0.5, -0.5}}
+	beeptest.AssertStreamer(t, b, want, 1e-12)
+}
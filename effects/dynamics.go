@@ -0,0 +1,318 @@
+package effects
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/brotholo/beep"
+)
+
+// Dynamics are the parameters of the dynamics processors: Compressor, Expander, Gate and Limiter.
+// Not every processor uses every parameter, see their constructors.
+type Dynamics struct {
+	// Threshold is the level in dBFS where the processor starts acting, for example -20.
+	Threshold float64
+
+	// Ratio is the ratio of the change of the input level to the change of the output level in
+	// the acting range, for example 4 for a 4:1 compressor.
+	Ratio float64
+
+	// Knee is the width in dB of the soft knee around the threshold. 0 means a hard knee.
+	Knee float64
+
+	// Attack and Release are the times the gain takes to react when the level crosses the
+	// threshold and when it returns.
+	Attack  time.Duration
+	Release time.Duration
+
+	// MakeUp is the gain in dB applied after the processing.
+	MakeUp float64
+
+	// Range limits the gain reduction of the Expander and the Gate in dB. 0 means unlimited.
+	Range float64
+
+	// Unlinked makes the processor control the gain of each channel separately. By default, the
+	// louder channel controls the gain of both channels, which keeps the stereo image stable.
+	Unlinked bool
+
+	// Sidechain is an optional Streamer whose level controls the gain instead of the level of the
+	// processed Streamer. It's streamed in lockstep with the processed Streamer, when it's drained,
+	// it counts as silence.
+	Sidechain beep.Streamer
+}
+
+// minLevel is the level in dB which stands for silence.
+const minLevel = -120
+
+// gainComputer computes the target gain in dB of the channel ch for the input level in dB.
+type gainComputer interface {
+	target(ch int, level float64) float64
+}
+
+// dynamics is the common implementation of the dynamics processors. It detects the level of the
+// signal or the sidechain, computes the target gain and smooths it with the attack and release
+// times.
+type dynamics struct {
+	Streamer beep.Streamer
+
+	computer  gainComputer
+	sidechain beep.Streamer
+	scBuf     [][2]float64
+	linked    bool
+	makeUp    float64
+	attack    float64 // smoothing coefficients
+	release   float64
+	attackUp  bool       // attack applies when the gain rises, otherwise when it falls
+	gain      [2]float64 // current gain of the channels in dB
+}
+
+func newDynamics(s beep.Streamer, sr beep.SampleRate, d Dynamics, computer gainComputer, attackUp bool) dynamics {
+	return dynamics{
+		Streamer:  s,
+		computer:  computer,
+		sidechain: d.Sidechain,
+		linked:    !d.Unlinked,
+		makeUp:    d.MakeUp,
+		attack:    smoothing(sr, d.Attack),
+		release:   smoothing(sr, d.Release),
+		attackUp:  attackUp,
+	}
+}
+
+// smoothing returns the coefficient of a one-pole smoothing filter with the time constant d.
+func smoothing(sr beep.SampleRate, d time.Duration) float64 {
+	n := float64(d) * float64(sr) / float64(time.Second)
+	if n <= 0 {
+		return 0
+	}
+	return math.Exp(-1 / n)
+}
+
+// Stream streams the wrapped Streamer processed by the dynamics processor.
+func (dy *dynamics) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = dy.Streamer.Stream(samples)
+	detect := dy.detect(samples[:n])
+	for i := range samples[:n] {
+		if dy.linked {
+			level := math.Max(math.Abs(detect[i][0]), math.Abs(detect[i][1]))
+			dy.smooth(0, dy.computer.target(0, toDB(level)))
+			dy.gain[1] = dy.gain[0]
+		} else {
+			for c := range samples[i] {
+				dy.smooth(c, dy.computer.target(c, toDB(math.Abs(detect[i][c]))))
+			}
+		}
+		for c := range samples[i] {
+			samples[i][c] *= fromDB(dy.gain[c] + dy.makeUp)
+		}
+	}
+	return n, ok
+}
+
+// Err propagates the wrapped Streamer's errors. Errors of the sidechain are ignored.
+func (dy *dynamics) Err() error {
+	return dy.Streamer.Err()
+}
+
+// GainReduction returns the gain reduction currently applied by the processor in decibels,
+// without the make-up gain. It is 0 when the processor doesn't act and negative when it boosts
+// the signal. If the channels are unlinked, it's the reduction of the more reduced channel.
+func (dy *dynamics) GainReduction() float64 {
+	return -math.Min(dy.gain[0], dy.gain[1])
+}
+
+// detect returns the signal controlling the gain of samples, either samples themselves or the
+// sidechain.
+func (dy *dynamics) detect(samples [][2]float64) [][2]float64 {
+	if dy.sidechain == nil {
+		return samples
+	}
+	return streamSidechain(dy.sidechain, &dy.scBuf, len(samples))
+}
+
+// streamSidechain streams n samples of the sidechain to buf, padded with silence.
+func streamSidechain(sidechain beep.Streamer, buf *[][2]float64, n int) [][2]float64 {
+	if cap(*buf) < n {
+		*buf = make([][2]float64, n)
+	}
+	sc := (*buf)[:n]
+	var sn int
+	for sn < n {
+		m, ok := sidechain.Stream(sc[sn:])
+		sn += m
+		if !ok || m == 0 {
+			break
+		}
+	}
+	for i := range sc[sn:] {
+		sc[sn+i] = [2]float64{}
+	}
+	return sc
+}
+
+func (dy *dynamics) smooth(ch int, target float64) {
+	coef := dy.release
+	if (target > dy.gain[ch]) == dy.attackUp {
+		coef = dy.attack
+	}
+	dy.gain[ch] = coef*dy.gain[ch] + (1-coef)*target
+}
+
+func toDB(x float64) float64 {
+	if x <= 0 {
+		return minLevel
+	}
+	return math.Max(20*math.Log10(x), minLevel)
+}
+
+func fromDB(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// Compressor reduces the level of the wrapped Streamer above the threshold by the ratio, making
+// loud parts quieter. See Dynamics for the parameters.
+type Compressor struct {
+	dynamics
+}
+
+// NewCompressor returns a Compressor of s. It uses all parameters of d except for Range. It panics
+// if Ratio isn't positive.
+func NewCompressor(s beep.Streamer, sr beep.SampleRate, d Dynamics) *Compressor {
+	if d.Ratio <= 0 {
+		panic(fmt.Errorf("effects: compressor ratio must be positive: %v", d.Ratio))
+	}
+	return &Compressor{newDynamics(s, sr, d, compressorCurve(d), false)}
+}
+
+type compressorCurve Dynamics
+
+func (cc compressorCurve) target(_ int, level float64) float64 {
+	over := level - cc.Threshold
+	switch {
+	case 2*over < -cc.Knee:
+		return 0
+	case cc.Knee > 0 && 2*math.Abs(over) <= cc.Knee:
+		x := over + cc.Knee/2
+		return (1/cc.Ratio - 1) * x * x / (2 * cc.Knee)
+	default:
+		return (1/cc.Ratio - 1) * over
+	}
+}
+
+// Expander expands the dynamic range of the wrapped Streamer by the ratio. A downward Expander
+// makes the parts below the threshold quieter, which reduces noise in pauses. An upward Expander
+// makes the parts above the threshold louder. See Dynamics for the parameters.
+type Expander struct {
+	dynamics
+}
+
+// NewExpander returns a downward Expander of s, or an upward one if upward is true. It uses all
+// parameters of d, Range limits the reduction of a downward Expander and the boost of an upward
+// one. It panics if Ratio isn't positive.
+func NewExpander(s beep.Streamer, sr beep.SampleRate, d Dynamics, upward bool) *Expander {
+	if d.Ratio <= 0 {
+		panic(fmt.Errorf("effects: expander ratio must be positive: %v", d.Ratio))
+	}
+	return &Expander{newDynamics(s, sr, d, expanderCurve{d, upward}, true)}
+}
+
+type expanderCurve struct {
+	Dynamics
+	upward bool
+}
+
+func (ec expanderCurve) target(_ int, level float64) float64 {
+	over := level - ec.Threshold
+	var gain float64
+	if ec.upward {
+		switch {
+		case 2*over < -ec.Knee:
+			gain = 0
+		case ec.Knee > 0 && 2*math.Abs(over) <= ec.Knee:
+			x := over + ec.Knee/2
+			gain = (ec.Ratio - 1) * x * x / (2 * ec.Knee)
+		default:
+			gain = (ec.Ratio - 1) * over
+		}
+		if ec.Range > 0 {
+			gain = math.Min(gain, ec.Range)
+		}
+		return gain
+	}
+	switch {
+	case 2*over > ec.Knee:
+		gain = 0
+	case ec.Knee > 0 && 2*math.Abs(over) <= ec.Knee:
+		x := over - ec.Knee/2
+		gain = -(ec.Ratio - 1) * x * x / (2 * ec.Knee)
+	default:
+		gain = (ec.Ratio - 1) * over
+	}
+	if ec.Range > 0 {
+		gain = math.Max(gain, -ec.Range)
+	}
+	return math.Max(gain, minLevel)
+}
+
+// Gate silences the wrapped Streamer while its level is below the threshold, for example to
+// remove the noise between words. See Dynamics for the parameters.
+//
+// The gate opens when the level rises above the threshold and closes when it stays below the
+// threshold minus the hysteresis for the hold time, which avoids chattering of a signal around
+// the threshold. The level is the peak envelope of the signal, which rises instantly and falls
+// over about 50ms, so the gate doesn't close at the zero crossings of a waveform.
+type Gate struct {
+	dynamics
+}
+
+// NewGate returns a Gate of s. It uses Threshold, Attack, Release, MakeUp, Range, Unlinked and
+// Sidechain of d. Range is the attenuation of the closed gate, 0 means silence.
+func NewGate(s beep.Streamer, sr beep.SampleRate, d Dynamics, hysteresis float64, hold time.Duration) *Gate {
+	closed := float64(minLevel)
+	if d.Range > 0 {
+		closed = -d.Range
+	}
+	gc := &gateComputer{
+		open:   d.Threshold,
+		close:  d.Threshold - hysteresis,
+		hold:   sr.N(hold),
+		closed: closed,
+		decay:  smoothing(sr, gateRelease),
+	}
+	gc.gain = [2]float64{closed, closed}
+	g := &Gate{newDynamics(s, sr, d, gc, true)}
+	g.gain = gc.gain
+	return g
+}
+
+// gateRelease is the time constant of the fall of the peak envelope detected by Gate.
+const gateRelease = 50 * time.Millisecond
+
+type gateComputer struct {
+	open, close float64 // thresholds in dB
+	hold        int     // samples
+	closed      float64 // gain of the closed gate in dB
+	decay       float64 // smoothing coefficient of the fall of the envelope
+	envelope    [2]float64
+	gain        [2]float64
+	held        [2]int // samples since the level fell below the closing threshold
+}
+
+func (gc *gateComputer) target(ch int, level float64) float64 {
+	gc.envelope[ch] = math.Max(fromDB(level), gc.decay*gc.envelope[ch])
+	level = toDB(gc.envelope[ch])
+	switch {
+	case level >= gc.open:
+		gc.gain[ch] = 0
+		gc.held[ch] = 0
+	case level < gc.close && gc.gain[ch] == 0:
+		gc.held[ch]++
+		if gc.held[ch] > gc.hold {
+			gc.gain[ch] = gc.closed
+		}
+	default:
+		gc.held[ch] = 0
+	}
+	return gc.gain[ch]
+}
@@ -0,0 +1,221 @@
+package effects_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+)
+
+const dynamicsRate = beep.SampleRate(8000)
+
+func fromDB(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// settle streams s for a second and returns the last sample.
+func settle(t *testing.T, s beep.Streamer) [2]float64 {
+	t.Helper()
+	v := beeptest.Validate(s)
+	samples := beeptest.Collect(v, 100)
+	if !v.Valid() {
+		t.Fatalf("processor violates the Streamer contract: %v", v.Violations())
+	}
+	return samples[len(samples)-1]
+}
+
+func TestCompressor(t *testing.T) {
+	d := effects.Dynamics{
+		Threshold: -20,
+		Ratio:     4,
+		Attack:    time.Millisecond,
+		Release:   10 * time.Millisecond,
+		MakeUp:    3,
+	}
+	c := effects.NewCompressor(beeptest.Constant(8000, [2]float64{0.5, -0.5}), dynamicsRate, d)
+	last := settle(t, c)
+
+	// about 14dB over the threshold, compressed to a quarter
+	over := 20*math.Log10(0.5) + 20
+	if want := fromDB(-20 + over/4 + 3); math.Abs(last[0]-want) > 1e-6 || math.Abs(last[1]+want) > 1e-6 {
+		t.Errorf("compressed sample is %v, want ±%v", last, want)
+	}
+	if gr, want := c.GainReduction(), over*3/4; math.Abs(gr-want) > 1e-6 {
+		t.Errorf("gain reduction is %vdB, want %vdB", gr, want)
+	}
+}
+
+func TestCompressorKnee(t *testing.T) {
+	d := effects.Dynamics{Threshold: -6, Ratio: 2, Knee: 6}
+	// at the threshold, a soft knee reduces by a quarter of the knee times (1-1/ratio)
+	c := effects.NewCompressor(beeptest.Constant(100, [2]float64{fromDB(-6), fromDB(-6)}), dynamicsRate, d)
+	settle(t, c)
+	if gr, want := c.GainReduction(), 0.5*3*3/(2*6); math.Abs(gr-want) > 1e-9 {
+		t.Errorf("gain reduction at the threshold is %vdB, want %vdB", gr, want)
+	}
+}
+
+func TestExpander(t *testing.T) {
+	d := effects.Dynamics{Threshold: -30, Ratio: 2}
+
+	down := effects.NewExpander(beeptest.Constant(100, [2]float64{fromDB(-40), fromDB(-40)}), dynamicsRate, d, false)
+	settle(t, down)
+	if gr := down.GainReduction(); math.Abs(gr-10) > 1e-9 {
+		t.Errorf("downward expander gain reduction is %vdB, want 10dB", gr)
+	}
+
+	up := effects.NewExpander(beeptest.Constant(100, [2]float64{fromDB(-20), fromDB(-20)}), dynamicsRate, d, true)
+	settle(t, up)
+	if gr := up.GainReduction(); math.Abs(gr+10) > 1e-9 {
+		t.Errorf("upward expander gain reduction is %vdB, want -10dB", gr)
+	}
+
+	d.Range = 6
+	limited := effects.NewExpander(beeptest.Constant(100, [2]float64{fromDB(-40), fromDB(-40)}), dynamicsRate, d, false)
+	settle(t, limited)
+	if gr := limited.GainReduction(); math.Abs(gr-6) > 1e-9 {
+		t.Errorf("range limited expander gain reduction is %vdB, want 6dB", gr)
+	}
+}
+
+func TestGate(t *testing.T) {
+	loud := fromDB(-10)
+	between := fromDB(-23) // below the threshold, above the hysteresis
+	data := make([][2]float64, 0, 4000)
+	for i := 0; i < 400; i++ {
+		data = append(data, [2]float64{loud, loud})
+	}
+	for i := 0; i < 1600; i++ {
+		data = append(data, [2]float64{between, between})
+	}
+	for i := 0; i < 2000; i++ {
+		data = append(data, [2]float64{0.001, 0.001})
+	}
+
+	d := effects.Dynamics{Threshold: -20}
+	g := effects.NewGate(beeptest.Samples(data), dynamicsRate, d, 6, sampleDuration(50))
+	got := beeptest.Collect(g, 400)
+
+	if got[0][0] != loud {
+		t.Errorf("open gate changed %v to %v", loud, got[0][0])
+	}
+	if got[1900][0] != between {
+		t.Errorf("gate closed within the hysteresis")
+	}
+	// the envelope falls below the hysteresis after about 140 samples
+	if got[2150][0] != 0.001 {
+		t.Errorf("gate closed before the envelope fell and the hold time passed")
+	}
+	if got[3999][0] > 1e-8 {
+		t.Errorf("gate did not close, output is %v", got[3999][0])
+	}
+	if g.GainReduction() < 100 {
+		t.Errorf("closed gate reduces by %vdB only", g.GainReduction())
+	}
+}
+
+func TestGateSine(t *testing.T) {
+	// a waveform crosses zero, but its level stays above the threshold
+	data := make([][2]float64, 4000)
+	for i := range data {
+		v := fromDB(-10) * math.Sin(2*math.Pi*100*float64(i)/float64(dynamicsRate))
+		data[i] = [2]float64{v, v}
+	}
+	d := effects.Dynamics{Threshold: -20}
+	g := effects.NewGate(beeptest.Samples(data), dynamicsRate, d, 3, 0)
+	got := beeptest.Collect(g, 0)
+	// the gate opens within the first quarter of the period and stays open
+	beeptest.AssertSamples(t, got[10:], data[10:], 0)
+}
+
+func TestDynamicsAtThreshold(t *testing.T) {
+	level := 0.5
+	d := effects.Dynamics{Threshold: 20 * math.Log10(level), Ratio: 4}
+	sample := [2]float64{level, -level}
+
+	// with a hard knee, the level exactly at the threshold is left unchanged
+	processors := map[string]beep.Streamer{
+		"compressor":        effects.NewCompressor(beeptest.Constant(100, sample), dynamicsRate, d),
+		"downward expander": effects.NewExpander(beeptest.Constant(100, sample), dynamicsRate, d, false),
+		"upward expander":   effects.NewExpander(beeptest.Constant(100, sample), dynamicsRate, d, true),
+	}
+	for name, p := range processors {
+		if last := settle(t, p); last != sample {
+			t.Errorf("%s changed %v at the threshold to %v", name, sample, last)
+		}
+	}
+
+	// a sine passes the threshold in both directions
+	sine := make([][2]float64, 4000)
+	for i := range sine {
+		v := math.Sin(2 * math.Pi * 440 * float64(i) / float64(dynamicsRate))
+		sine[i] = [2]float64{v, v}
+	}
+	processors = map[string]beep.Streamer{
+		"compressor":        effects.NewCompressor(beeptest.Samples(sine), dynamicsRate, d),
+		"downward expander": effects.NewExpander(beeptest.Samples(sine), dynamicsRate, d, false),
+		"upward expander":   effects.NewExpander(beeptest.Samples(sine), dynamicsRate, d, true),
+	}
+	for name, p := range processors {
+		v := beeptest.Validate(p)
+		beeptest.Collect(v, 100)
+		if !v.Valid() {
+			t.Errorf("%s violates the Streamer contract on a sine: %v", name, v.Violations())
+		}
+	}
+}
+
+func TestDynamicsRatio(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("compressor with the ratio 0 didn't panic")
+		}
+	}()
+	effects.NewCompressor(beeptest.Constant(100, [2]float64{}), dynamicsRate, effects.Dynamics{})
+}
+
+func sampleDuration(n int) time.Duration {
+	return dynamicsRate.D(n)
+}
+
+func TestDynamicsLinking(t *testing.T) {
+	d := effects.Dynamics{Threshold: -20, Ratio: 10}
+	sample := [2]float64{0.5, 0.01}
+
+	linked := settle(t, effects.NewCompressor(beeptest.Constant(100, sample), dynamicsRate, d))
+	if linked[1] >= sample[1] {
+		t.Errorf("linked compressor did not reduce the quiet channel: %v", linked)
+	}
+
+	d.Unlinked = true
+	unlinked := settle(t, effects.NewCompressor(beeptest.Constant(100, sample), dynamicsRate, d))
+	if unlinked[1] != sample[1] || unlinked[0] >= sample[0] {
+		t.Errorf("unlinked compressor changed the wrong channels: %v", unlinked)
+	}
+}
+
+func TestDynamicsSidechain(t *testing.T) {
+	d := effects.Dynamics{
+		Threshold: -20,
+		Ratio:     10,
+		Sidechain: beeptest.Constant(50, [2]float64{1, 1}),
+	}
+	c := effects.NewCompressor(beeptest.Constant(100, [2]float64{0.01, 0.01}), dynamicsRate, d)
+	got := beeptest.Collect(c, 10)
+	if got[10][0] >= 0.01 {
+		t.Errorf("loud sidechain did not reduce the quiet signal: %v", got[10])
+	}
+	if got[99][0] != 0.01 {
+		t.Errorf("drained sidechain still reduces the signal: %v", got[99])
+	}
+
+	d.Threshold = -6
+	d.Sidechain = beeptest.Constant(100, [2]float64{1, 1})
+	l := effects.NewLookaheadLimiter(beeptest.Constant(100, [2]float64{0.1, 0.1}), dynamicsRate, d)
+	if got := beeptest.Collect(l, 10); got[50][0] > 0.06 {
+		t.Errorf("loud sidechain did not reduce the limited signal: %v", got[50])
+	}
+}
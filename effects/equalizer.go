@@ -2,38 +2,49 @@ package effects
 
 import (
 	"math"
+	"math/cmplx"
+	"sync"
 
 	"github.com/brotholo/beep"
 )
 
 type (
 
+	// Equalizer is a parametric equalizer, see NewEqualizer.
+	//
 	// This parametric equalizer is based on the GK Nilsen's post at:
 	// https://octovoid.com/2017/11/04/coding-a-parametric-equalizer-for-audio-applications/
-	equalizer struct {
+	Equalizer struct {
 		streamer beep.Streamer
+		fs       float64
+		glide    float64
+
+		mu       sync.Mutex
+		params   StereoEqualizerSections // target sections
+		current  StereoEqualizerSections // sections gliding towards params
 		sections []section
 	}
 
+	// section is a second order section with the coefficients of the channels.
 	section struct {
-		a, b         [2][]float64
-		xPast, yPast [][2]float64
+		a, b         [2][3]float64
+		xPast, yPast [2][2]float64
 	}
 
 	// EqualizerSections is the interfacd that is passed into NewEqualizer
 	EqualizerSections interface {
-		sections(fs float64) []section
+		stereo() StereoEqualizerSections
 	}
 
 	StereoEqualizerSection struct {
-		Left  MonoEqualizerSection
-		Right MonoEqualizerSection
+		Left  MonoEqualizerSection `json:"left"`
+		Right MonoEqualizerSection `json:"right"`
 	}
 
 	MonoEqualizerSection struct {
 		// F0 (center frequency) sets the mid-point of the section’s
 		// frequency range and is given in Hertz [Hz].
-		F0 float64
+		F0 float64 `json:"f0"`
 
 		// Bf (bandwidth) represents the width of the section across
 		// frequency and is measured in Hertz [Hz]. A low bandwidth
@@ -43,24 +54,24 @@ type (
 		// a high bandwidth yields a section of wide frequency range —
 		// affecting a broader range of frequencies surrounding the
 		// center frequency.
-		Bf float64
+		Bf float64 `json:"bf"`
 
 		// GB (bandwidth gain) is given in decibels [dB] and represents
 		// the level at which the bandwidth is measured. That is, to
 		// have a meaningful measure of bandwidth, we must define the
 		// level at which it is measured.
-		GB float64
+		GB float64 `json:"gb"`
 
 		// G0 (reference gain) is given in decibels [dB] and simply
 		// represents the level of the section’s offset.
-		G0 float64
+		G0 float64 `json:"g0"`
 
 		// G (boost/cut gain) is given in decibels [dB] and prescribes
 		// the effect imposed on the audio loudness for the section’s
 		// frequency range. A boost/cut level of 0 dB corresponds to
 		// unity (no operation), whereas negative numbers corresponds to
 		// cut (volume down) and positive numbers to boost (volume up).
-		G float64
+		G float64 `json:"g"`
 	}
 
 	// StereoEqualizerSections implements EqualizerSections and can be passed into NewEqualizer
@@ -68,69 +79,165 @@ type (
 
 	// MonoEqualizerSections implements EqualizerSections and can be passed into NewEqualizer
 	MonoEqualizerSections []MonoEqualizerSection
+
+	// FrequencyResponse is the response of an Equalizer at a frequency, see Equalizer.Response.
+	FrequencyResponse struct {
+		// Freq is the frequency in Hertz [Hz].
+		Freq float64
+
+		// Magnitude is the gain of the left and the right channel in decibels [dB].
+		Magnitude [2]float64
+
+		// Phase is the phase shift of the left and the right channel in radians.
+		Phase [2]float64
+	}
 )
 
-// NewEqualizer returns a beep.Streamer that modifies the stream based on the EqualizerSection slice that is passed in.
+// NewEqualizer returns an Equalizer that modifies the stream based on the EqualizerSection slice that is passed in.
 // The SampleRate (sr) must match that of the Streamer.
-func NewEqualizer(st beep.Streamer, sr beep.SampleRate, s EqualizerSections) beep.Streamer {
-	return &equalizer{
+func NewEqualizer(st beep.Streamer, sr beep.SampleRate, s EqualizerSections) *Equalizer {
+	params := s.stereo()
+	e := &Equalizer{
 		streamer: st,
-		sections: s.sections(float64(sr)),
+		fs:       float64(sr),
+		glide:    1 - math.Exp(-filterControlBlock/float64(sr.N(filterSmoothing))),
+		params:   params,
+		current:  params.stereo(),
+		sections: make([]section, len(params)),
+	}
+	for i, p := range params {
+		e.sections[i] = p.section(e.fs)
 	}
+	return e
 }
 
-func (m MonoEqualizerSections) sections(fs float64) []section {
-	out := make([]section, len(m))
+func (m MonoEqualizerSections) stereo() StereoEqualizerSections {
+	out := make(StereoEqualizerSections, len(m))
 	for i, s := range m {
-		out[i] = s.section(fs)
+		out[i] = StereoEqualizerSection{Left: s, Right: s}
 	}
 	return out
 }
 
-func (m StereoEqualizerSections) sections(fs float64) []section {
-	out := make([]section, len(m))
-	for i, s := range m {
-		out[i] = s.section(fs)
-	}
-	return out
+func (m StereoEqualizerSections) stereo() StereoEqualizerSections {
+	return append(StereoEqualizerSections(nil), m...)
 }
 
 // Stream streams the wrapped Streamer modified by Equalizer.
-func (e *equalizer) Stream(samples [][2]float64) (n int, ok bool) {
+func (e *Equalizer) Stream(samples [][2]float64) (n int, ok bool) {
 	n, ok = e.streamer.Stream(samples)
-	for _, s := range e.sections {
-		s.apply(samples)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.sections {
+		e.apply(i, samples[:n])
 	}
 	return n, ok
 }
 
+// apply filters samples in place by the i-th section. While the section glides to new
+// parameters, its coefficients are updated every filterControlBlock samples.
+func (e *Equalizer) apply(i int, samples [][2]float64) {
+	for len(samples) > 0 {
+		block := samples
+		if e.current[i] != e.params[i] {
+			if len(block) > filterControlBlock {
+				block = block[:filterControlBlock]
+			}
+			e.current[i] = e.current[i].glide(e.params[i], e.glide)
+			sec := e.current[i].section(e.fs)
+			e.sections[i].a, e.sections[i].b = sec.a, sec.b
+		}
+		e.sections[i].apply(block)
+		samples = samples[len(block):]
+	}
+}
+
 // Err propagates the wrapped Streamer's errors.
-func (e *equalizer) Err() error {
+func (e *Equalizer) Err() error {
 	return e.streamer.Err()
 }
 
+// Sections returns the current sections of the Equalizer. Mono sections are returned as stereo
+// sections with both channels equal.
+func (e *Equalizer) Sections() StereoEqualizerSections {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.params.stereo()
+}
+
+// SetSection replaces the i-th section of the Equalizer by a section applied to both channels.
+func (e *Equalizer) SetSection(i int, s MonoEqualizerSection) {
+	e.SetStereoSection(i, StereoEqualizerSection{Left: s, Right: s})
+}
+
+// SetStereoSection replaces the i-th section of the Equalizer. Like Filter, the Equalizer glides
+// to the new section over about 20ms and keeps the filter state, so the change doesn't click.
+//
+// SetSection and SetStereoSection are safe to call from any goroutine while the Equalizer is
+// streaming.
+func (e *Equalizer) SetStereoSection(i int, s StereoEqualizerSection) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.params[i] = s
+}
+
+// Response returns the magnitude and phase response of the Equalizer at the frequencies freqs in
+// Hertz [Hz], for example to plot it. It's the response of the sections set last, which the
+// Equalizer may still be gliding to.
+func (e *Equalizer) Response(freqs []float64) []FrequencyResponse {
+	params := e.Sections()
+	sections := make([]section, len(params))
+	for i, p := range params {
+		sections[i] = p.section(e.fs)
+	}
+
+	out := make([]FrequencyResponse, len(freqs))
+	for i, f := range freqs {
+		out[i].Freq = f
+		z := cmplx.Exp(complex(0, -2*math.Pi*f/e.fs)) // z^-1
+		for c := 0; c < 2; c++ {
+			h := complex(1, 0)
+			for _, s := range sections {
+				h *= s.response(c, z)
+			}
+			out[i].Magnitude[c] = 20 * math.Log10(cmplx.Abs(h))
+			out[i].Phase[c] = cmplx.Phase(h)
+		}
+	}
+	return out
+}
+
 func (m MonoEqualizerSection) section(fs float64) section {
+	if m.G == m.G0 {
+		// flat, the formula for the bandwidth below would divide zero by zero
+		g := math.Pow(10.0, m.G0/20.0)
+		return section{
+			a: [2][3]float64{{1, 0, 0}, {1, 0, 0}},
+			b: [2][3]float64{{g, 0, 0}, {g, 0, 0}},
+		}
+	}
+
 	beta := math.Tan(m.Bf/2.0*math.Pi/(fs/2.0)) *
 		math.Sqrt(math.Abs(math.Pow(math.Pow(10, m.GB/20.0), 2.0)-
 			math.Pow(math.Pow(10.0, m.G0/20.0), 2.0))) /
 		math.Sqrt(math.Abs(math.Pow(math.Pow(10.0, m.G/20.0), 2.0)-
 			math.Pow(math.Pow(10.0, m.GB/20.0), 2.0)))
 
-	b := []float64{
+	b := [3]float64{
 		(math.Pow(10.0, m.G0/20.0) + math.Pow(10.0, m.G/20.0)*beta) / (1 + beta),
 		(-2 * math.Pow(10.0, m.G0/20.0) * math.Cos(m.F0*math.Pi/(fs/2.0))) / (1 + beta),
 		(math.Pow(10.0, m.G0/20) - math.Pow(10.0, m.G/20.0)*beta) / (1 + beta),
 	}
 
-	a := []float64{
+	a := [3]float64{
 		1.0,
 		-2 * math.Cos(m.F0*math.Pi/(fs/2.0)) / (1 + beta),
 		(1 - beta) / (1 + beta),
 	}
 
 	return section{
-		a: [2][]float64{a, a},
-		b: [2][]float64{b, b},
+		a: [2][3]float64{a, a},
+		b: [2][3]float64{b, b},
 	}
 }
 
@@ -139,56 +246,54 @@ func (s StereoEqualizerSection) section(fs float64) section {
 	r := s.Right.section(fs)
 
 	return section{
-		a: [2][]float64{l.a[0], r.a[0]},
-		b: [2][]float64{l.b[0], r.b[0]},
+		a: [2][3]float64{l.a[0], r.a[0]},
+		b: [2][3]float64{l.b[0], r.b[0]},
 	}
 }
 
-func (s *section) apply(x [][2]float64) {
-	ord := len(s.a[0]) - 1
-	np := len(x) - 1
-
-	if np < ord {
-		x = append(x, make([][2]float64, ord-np)...)
-		np = ord
+// glide moves the section s a step towards target, see glideParams.
+func (s StereoEqualizerSection) glide(target StereoEqualizerSection, glide float64) StereoEqualizerSection {
+	return StereoEqualizerSection{
+		Left:  s.Left.glide(target.Left, glide),
+		Right: s.Right.glide(target.Right, glide),
 	}
+}
 
-	y := make([][2]float64, len(x))
-
-	if len(s.xPast) < len(x) {
-		s.xPast = append(s.xPast, make([][2]float64, len(x)-len(s.xPast))...)
+func (m MonoEqualizerSection) glide(target MonoEqualizerSection, glide float64) MonoEqualizerSection {
+	return MonoEqualizerSection{
+		F0: glideFreq(m.F0, target.F0, glide),
+		Bf: glideValue(m.Bf, target.Bf, glide),
+		GB: glideValue(m.GB, target.GB, glide),
+		G0: glideValue(m.G0, target.G0, glide),
+		G:  glideValue(m.G, target.G, glide),
 	}
+}
 
-	if len(s.yPast) < len(x) {
-		s.yPast = append(s.yPast, make([][2]float64, len(x)-len(s.yPast))...)
+// response returns the transfer function of the channel c of the section at z^-1.
+func (s *section) response(c int, z complex128) complex128 {
+	var num, den complex128
+	zk := complex(1, 0)
+	for k := range s.b[c] {
+		num += complex(s.b[c][k], 0) * zk
+		den += complex(s.a[c][k], 0) * zk
+		zk *= z
 	}
+	return num / den
+}
 
-	for i := 0; i < len(x); i++ {
-		for j := 0; j < ord+1; j++ {
-			if i-j < 0 {
-				y[i][0] = y[i][0] + s.b[0][j]*s.xPast[len(s.xPast)-j][0]
-				y[i][1] = y[i][1] + s.b[1][j]*s.xPast[len(s.xPast)-j][1]
-			} else {
-				y[i][0] = y[i][0] + s.b[0][j]*x[i-j][0]
-				y[i][1] = y[i][1] + s.b[1][j]*x[i-j][1]
-			}
-		}
-
-		for j := 0; j < ord; j++ {
-			if i-j-1 < 0 {
-				y[i][0] = y[i][0] - s.a[0][j+1]*s.yPast[len(s.yPast)-j-1][0]
-				y[i][1] = y[i][1] - s.a[1][j+1]*s.yPast[len(s.yPast)-j-1][1]
-			} else {
-				y[i][0] = y[i][0] - s.a[0][j+1]*y[i-j-1][0]
-				y[i][1] = y[i][1] - s.a[1][j+1]*y[i-j-1][1]
-			}
+// apply filters x in place. The past samples of the section carry the state between the calls.
+func (s *section) apply(x [][2]float64) {
+	for i := range x {
+		var y [2]float64
+		for c := range y {
+			y[c] = (s.b[c][0]*x[i][c] +
+				s.b[c][1]*s.xPast[0][c] + s.b[c][2]*s.xPast[1][c] -
+				s.a[c][1]*s.yPast[0][c] - s.a[c][2]*s.yPast[1][c]) / s.a[c][0]
 		}
 
-		y[i][0] = y[i][0] / s.a[0][0]
-		y[i][1] = y[i][1] / s.a[1][0]
+		// the most recent past sample first
+		s.xPast[1], s.yPast[1] = s.xPast[0], s.yPast[0]
+		s.xPast[0], s.yPast[0] = x[i], y
+		x[i] = y
 	}
-
-	s.xPast = x[:]
-	s.yPast = y[:]
-	copy(x, y)
 }
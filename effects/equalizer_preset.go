@@ -0,0 +1,81 @@
+package effects
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// ISOBands are the center frequencies in Hertz [Hz] of the octave bands of a standard 10-band
+// graphic equalizer, as defined by ISO 266.
+var ISOBands = [10]float64{31.5, 63, 125, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+// EqualizerPreset is a named set of sections of an Equalizer, which can be saved and loaded as
+// JSON. Either Mono or Stereo is set.
+type EqualizerPreset struct {
+	Name   string                  `json:"name,omitempty"`
+	Mono   MonoEqualizerSections   `json:"mono,omitempty"`
+	Stereo StereoEqualizerSections `json:"stereo,omitempty"`
+}
+
+// Sections returns the sections of the preset, which can be passed into NewEqualizer.
+func (p EqualizerPreset) Sections() EqualizerSections {
+	if p.Stereo != nil {
+		return p.Stereo
+	}
+	return p.Mono
+}
+
+// LoadEqualizerPreset reads a preset in JSON from r.
+func LoadEqualizerPreset(r io.Reader) (EqualizerPreset, error) {
+	var p EqualizerPreset
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return EqualizerPreset{}, errors.Wrap(err, "effects: load equalizer preset")
+	}
+	if p.Mono != nil && p.Stereo != nil {
+		return EqualizerPreset{}, errors.New("effects: load equalizer preset: both mono and stereo sections")
+	}
+	return p, nil
+}
+
+// SaveEqualizerPreset writes the preset p in JSON to w.
+func SaveEqualizerPreset(w io.Writer, p EqualizerPreset) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(p); err != nil {
+		return errors.Wrap(err, "effects: save equalizer preset")
+	}
+	return nil
+}
+
+// GraphicEQSection returns an octave wide section centered at f0 with the boost/cut gain g in
+// decibels [dB], a band of a graphic equalizer.
+func GraphicEQSection(f0, g float64) MonoEqualizerSection {
+	return MonoEqualizerSection{
+		F0: f0,
+		Bf: f0 * (math.Sqrt2 - 1/math.Sqrt2), // from f0/√2 to f0·√2
+		GB: g / 2,
+		G0: 0,
+		G:  g,
+	}
+}
+
+// ISOGraphicEQ returns the sections of a 10-band graphic equalizer with the gains of the ISOBands
+// in decibels [dB]. The highest band requires a sample rate above 32 kHz.
+func ISOGraphicEQ(gains [10]float64) MonoEqualizerSections {
+	sections := make(MonoEqualizerSections, len(ISOBands))
+	for i, f0 := range ISOBands {
+		sections[i] = GraphicEQSection(f0, gains[i])
+	}
+	return sections
+}
+
+// ISOGraphicEQPreset returns the flat preset of a 10-band graphic equalizer, see ISOGraphicEQ.
+func ISOGraphicEQPreset() EqualizerPreset {
+	return EqualizerPreset{
+		Name: "ISO 10-band graphic EQ",
+		Mono: ISOGraphicEQ([10]float64{}),
+	}
+}
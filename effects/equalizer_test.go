@@ -0,0 +1,137 @@
+package effects_test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+)
+
+func TestEqualizer(t *testing.T) {
+	const sr = beep.SampleRate(48000)
+	sections := effects.MonoEqualizerSections{{F0: 1000, Bf: 200, GB: 3, G0: 0, G: 6}}
+	data := sine(sr, 1000, 4800)
+
+	// the state of the sections carries over between the calls
+	whole := beeptest.Collect(effects.NewEqualizer(beeptest.Samples(data), sr, sections), 4800)
+	pieces := beeptest.Collect(effects.NewEqualizer(beeptest.Samples(data), sr, sections), 7)
+	beeptest.AssertSamples(t, pieces, whole, 1e-12)
+	if db := peakDB(whole[2400:]); math.Abs(db-6) > 0.2 {
+		t.Errorf("boosted sine peaks at %.2f dB, want 6 dB", db)
+	}
+
+	eq := effects.NewEqualizer(beep.Silence(-1), sr, sections)
+	buf := make([][2]float64, 512)
+	eq.Stream(buf)
+	if allocs := testing.AllocsPerRun(10, func() { eq.Stream(buf) }); allocs > 0 {
+		t.Errorf("Stream allocates %v times per call", allocs)
+	}
+}
+
+func TestEqualizerSetSection(t *testing.T) {
+	const sr = beep.SampleRate(48000)
+	data := sine(sr, 1000, 48000)
+	eq := effects.NewEqualizer(beeptest.Samples(data), sr, effects.MonoEqualizerSections{effects.GraphicEQSection(1000, 0)})
+
+	if db := peakDB(beeptest.Collect(beep.Take(4800, eq), 0)); math.Abs(db) > 0.01 {
+		t.Errorf("flat equalizer peaks at %.2f dB, want 0 dB", db)
+	}
+
+	// the change glides instead of jumping
+	eq.SetSection(0, effects.GraphicEQSection(1000, 12))
+	if db := peakDB(beeptest.Collect(beep.Take(48, eq), 0)); db > 3 {
+		t.Errorf("first period after the change peaks at %.2f dB, the change jumps", db)
+	}
+	if db := peakDB(beeptest.Collect(beep.Take(4800, eq), 0)[2400:]); math.Abs(db-12) > 0.2 {
+		t.Errorf("equalizer peaks at %.2f dB after the glide, want 12 dB", db)
+	}
+
+	// sections are changed while the equalizer streams
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		beeptest.Collect(beep.Take(9600, eq), 64)
+	}()
+	go func() {
+		defer wg.Done()
+		for g := -12.0; g <= 12; g++ {
+			eq.SetSection(0, effects.GraphicEQSection(1000, g))
+			eq.Response([]float64{1000})
+		}
+	}()
+	wg.Wait()
+
+	eq.SetStereoSection(0, effects.StereoEqualizerSection{
+		Left:  effects.GraphicEQSection(1000, 6),
+		Right: effects.GraphicEQSection(1000, -6),
+	})
+	got := beeptest.Collect(eq, 0)
+	var peak [2]float64
+	for _, s := range got[len(got)-2400:] {
+		peak[0] = math.Max(peak[0], s[0])
+		peak[1] = math.Max(peak[1], s[1])
+	}
+	if l, r := 20*math.Log10(peak[0]), 20*math.Log10(peak[1]); math.Abs(l-6) > 0.2 || math.Abs(r+6) > 0.2 {
+		t.Errorf("channels peak at %.2f dB and %.2f dB, want 6 dB and -6 dB", l, r)
+	}
+	if s := eq.Sections(); s[0].Left.G != 6 || s[0].Right.G != -6 {
+		t.Errorf("Sections returned %+v", s)
+	}
+}
+
+func TestEqualizerResponse(t *testing.T) {
+	const sr = beep.SampleRate(48000)
+	gains := [10]float64{0, 0, 0, 0, 0, 6, 0, 0, 0, -6}
+	eq := effects.NewEqualizer(nil, sr, effects.ISOGraphicEQ(gains))
+
+	resp := eq.Response([]float64{1000, 16000, 20})
+	if got := resp[0].Magnitude[0]; math.Abs(got-6) > 0.5 {
+		t.Errorf("response at 1 kHz is %.2f dB, want about 6 dB", got)
+	}
+	if got := resp[1].Magnitude[1]; math.Abs(got+6) > 0.5 {
+		t.Errorf("response at 16 kHz is %.2f dB, want about -6 dB", got)
+	}
+	if got := resp[2].Magnitude[0]; math.Abs(got) > 0.5 {
+		t.Errorf("response at 20 Hz is %.2f dB, want about 0 dB", got)
+	}
+	if resp[0].Freq != 1000 || math.Abs(resp[0].Phase[0]) > 0.1 {
+		t.Errorf("response at the center of a band is %+v, want no phase shift", resp[0])
+	}
+}
+
+func TestEqualizerPreset(t *testing.T) {
+	preset := effects.ISOGraphicEQPreset()
+	preset.Mono[5].G = 3
+
+	var buf bytes.Buffer
+	if err := effects.SaveEqualizerPreset(&buf, preset); err != nil {
+		t.Fatal(err)
+	}
+	got, err := effects.LoadEqualizerPreset(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, preset) {
+		t.Errorf("loaded preset %+v, want %+v", got, preset)
+	}
+
+	stereo := `{"name": "wide", "stereo": [{"left": {"f0": 200, "bf": 50, "gb": 3, "g": 6}, "right": {"f0": 200, "bf": 50, "gb": -3, "g": -6}}]}`
+	got, err = effects.LoadEqualizerPreset(strings.NewReader(stereo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := got.Sections().(effects.StereoEqualizerSections); !ok || len(s) != 1 || s[0].Right.G != -6 {
+		t.Errorf("loaded sections %+v", got.Sections())
+	}
+
+	if _, err := effects.LoadEqualizerPreset(strings.NewReader(`{"mono": [], "stereo": []}`)); err == nil {
+		t.Error("loaded a preset with both mono and stereo sections")
+	}
+}
@@ -0,0 +1,302 @@
+package effects
+
+import (
+	"io"
+	"math"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/wav"
+	"github.com/pkg/errors"
+)
+
+// Direction is a direction from the listener in degrees, in the convention of the SOFA format:
+// Azimuth 0 is the front, 90 the left and -90 (or 270) the right, Elevation 90 is above and -90
+// below.
+type Direction struct {
+	Azimuth   float64
+	Elevation float64
+}
+
+// vector returns the unit vector of the direction.
+func (d Direction) vector() [3]float64 {
+	az, el := d.Azimuth*math.Pi/180, d.Elevation*math.Pi/180
+	return [3]float64{math.Cos(el) * math.Cos(az), math.Cos(el) * math.Sin(az), math.Sin(el)}
+}
+
+// HRIR is a pair of head-related impulse responses, which describe how a sound from the Direction
+// reaches the left and the right ear.
+type HRIR struct {
+	Direction
+	Left  []float64
+	Right []float64
+}
+
+// HRIRSet is a set of HRIRs measured at various directions, at the same sample rate.
+//
+// HRIRSet can be loaded from SOFA files, the standard format of HRTF measurements, see AddSOFA,
+// or from WAVE files, one stereo file per direction, see AddWAV.
+type HRIRSet struct {
+	SampleRate beep.SampleRate
+	HRIRs      []HRIR
+}
+
+// NewHRIRSet returns an empty HRIRSet of HRIRs at the sample rate sr.
+func NewHRIRSet(sr beep.SampleRate) *HRIRSet {
+	return &HRIRSet{SampleRate: sr}
+}
+
+// Add adds the HRIR to the set.
+func (set *HRIRSet) Add(h HRIR) {
+	set.HRIRs = append(set.HRIRs, h)
+}
+
+// AddWAV decodes a pair of impulse responses from a stereo WAVE file, left ear in the left
+// channel, and adds them to the set as measured from the direction dir. The WAVE file is resampled
+// to the sample rate of the set if needed. AddWAV doesn't close r.
+func (set *HRIRSet) AddWAV(r io.Reader, dir Direction) error {
+	s, format, err := wav.Decode(r)
+	if err != nil {
+		return errors.Wrap(err, "effects")
+	}
+	if format.NumChannels != 2 {
+		return errors.New("effects: HRIR WAVE file is not stereo")
+	}
+
+	h := set.resample(s, format.SampleRate, dir)
+	if err := s.Err(); err != nil {
+		return errors.Wrap(err, "effects")
+	}
+	set.Add(h)
+	return nil
+}
+
+// resample returns the HRIR of the direction dir of the stereo Streamer s at the sample rate sr,
+// resampled to the sample rate of the set if needed.
+func (set *HRIRSet) resample(s beep.Streamer, sr beep.SampleRate, dir Direction) HRIR {
+	if sr != set.SampleRate {
+		s = beep.Resample(4, sr, set.SampleRate, s)
+	}
+	h := HRIR{Direction: dir}
+	buf := make([][2]float64, 512)
+	for {
+		n, ok := s.Stream(buf)
+		for _, sample := range buf[:n] {
+			h.Left = append(h.Left, sample[0])
+			h.Right = append(h.Right, sample[1])
+		}
+		if !ok {
+			break
+		}
+	}
+	return h
+}
+
+// Position is a position of a sound relative to the listener.
+type Position struct {
+	Direction
+
+	// Distance is the distance of the sound. The sound is attenuated by the inverse distance law
+	// beyond the distance 1.
+	Distance float64
+}
+
+// SpatializerBlockSize is the size of the blocks in which Spatializer processes the audio and
+// crossfades to a new position. It's also the latency of Spatializer.
+const SpatializerBlockSize = 64
+
+// spatializerNeighbors is the number of the nearest measured directions Spatializer interpolates.
+const spatializerNeighbors = 3
+
+// Spatializer renders the wrapped Streamer as a sound at a Position around the listener for
+// headphones, by convolving its mono sum with the head-related impulse responses of the Position.
+// The impulse responses of directions between the measured ones are interpolated from the nearest
+// measured directions.
+//
+// The convolution is computed in the frequency domain in blocks of SpatializerBlockSize samples,
+// like in ConvolutionReverb, which delays the audio by SpatializerBlockSize samples.
+//
+// The Position can be changed while streaming, for example to follow a moving object, and
+// Spatializer crossfades to it smoothly. If the Spatializer is playing through the speaker, lock
+// the speaker before changing it. For moving sounds, wrap the source in Doppler to get the delay
+// and the pitch shift of the distance as well.
+//
+// When the wrapped Streamer is drained, Spatializer streams the rest of the impulse response and
+// then drains too.
+type Spatializer struct {
+	Streamer beep.Streamer
+	Position Position
+
+	set  *HRIRSet
+	vecs [][3]float64
+
+	conv     *partitionedConvolution
+	h        [2][]float64 // interpolated impulse responses
+	started  bool
+	rendered Position          // position of cur
+	cur      [2][][]complex128 // spectra of the blocks of the impulse responses
+	next     [2][][]complex128
+	res      [2][]float64
+
+	in  []float64    // mono input block being filled
+	out [][2]float64 // output block being streamed
+	pos int
+
+	tail tail
+}
+
+// NewSpatializer returns a Spatializer of s with the HRIRs of set, in front of the listener at
+// the distance 1. The sample rate of s must match the sample rate of set.
+func NewSpatializer(s beep.Streamer, set *HRIRSet) *Spatializer {
+	const b = SpatializerBlockSize
+
+	length := 1
+	for _, h := range set.HRIRs {
+		if len(h.Left) > length {
+			length = len(h.Left)
+		}
+		if len(h.Right) > length {
+			length = len(h.Right)
+		}
+	}
+	sp := &Spatializer{
+		Streamer: s,
+		Position: Position{Distance: 1},
+		set:      set,
+		vecs:     make([][3]float64, len(set.HRIRs)),
+		conv:     newPartitionedConvolution(b, length),
+		in:       make([]float64, b),
+		out:      make([][2]float64, b),
+		tail:     tail{length: length + b - 1},
+	}
+	for i, h := range set.HRIRs {
+		sp.vecs[i] = h.vector()
+	}
+	for c := range sp.h {
+		sp.h[c] = make([]float64, length)
+		sp.cur[c] = sp.conv.partition(nil, nil)
+		sp.next[c] = sp.conv.partition(nil, nil)
+		sp.res[c] = make([]float64, b)
+	}
+	return sp
+}
+
+// Stream streams the wrapped Streamer spatialized.
+func (sp *Spatializer) Stream(samples [][2]float64) (n int, ok bool) {
+	return sp.tail.stream(sp.Streamer, samples, sp.process)
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (sp *Spatializer) Err() error {
+	return sp.Streamer.Err()
+}
+
+func (sp *Spatializer) process(samples [][2]float64) {
+	for i, x := range samples {
+		samples[i] = sp.out[sp.pos]
+		sp.in[sp.pos] = (x[0] + x[1]) / 2
+		sp.pos++
+		if sp.pos == len(sp.in) {
+			sp.render()
+			sp.pos = 0
+		}
+	}
+}
+
+// render convolves the full input block and replaces the output block with the result,
+// crossfaded to the current Position if it changed.
+func (sp *Spatializer) render() {
+	if !sp.started {
+		// the initial position isn't crossfaded
+		sp.interpolate(sp.cur, sp.Position)
+		sp.rendered = sp.Position
+		sp.started = true
+	}
+	fading := sp.Position != sp.rendered
+	if fading {
+		sp.interpolate(sp.next, sp.Position)
+		sp.rendered = sp.Position
+	}
+
+	sp.conv.push(sp.in)
+	for c := range sp.out[0] {
+		sp.conv.convolve(sp.cur[c], sp.res[0])
+		if !fading {
+			for i, y := range sp.res[0] {
+				sp.out[i][c] = y
+			}
+			continue
+		}
+		sp.conv.convolve(sp.next[c], sp.res[1])
+		for i := range sp.out {
+			t := float64(i+1) / float64(len(sp.out))
+			sp.out[i][c] = sp.res[0][i]*(1-t) + sp.res[1][i]*t
+		}
+	}
+	if fading {
+		sp.cur, sp.next = sp.next, sp.cur
+	}
+}
+
+// neighbor is a measured direction at the angle from an interpolated one.
+type neighbor struct {
+	i     int
+	angle float64
+}
+
+// interpolate computes the spectra of the impulse responses of the position p into dst. The HRIRs
+// of the nearest measured directions are weighted by the inverse of their angular distance.
+func (sp *Spatializer) interpolate(dst [2][][]complex128, p Position) {
+	for c := range sp.h {
+		for i := range sp.h[c] {
+			sp.h[c][i] = 0
+		}
+	}
+
+	// the nearest directions, sorted by the angle
+	var nearest [spatializerNeighbors]neighbor
+	count := 0
+	v := p.vector()
+	for i, u := range sp.vecs {
+		dot := v[0]*u[0] + v[1]*u[1] + v[2]*u[2]
+		nb := neighbor{i, math.Acos(math.Max(-1, math.Min(dot, 1)))}
+		if count == len(nearest) {
+			if nb.angle >= nearest[count-1].angle {
+				continue
+			}
+			count--
+		}
+		j := count
+		for ; j > 0 && nearest[j-1].angle > nb.angle; j-- {
+			nearest[j] = nearest[j-1]
+		}
+		nearest[j] = nb
+		count++
+	}
+
+	var weights [spatializerNeighbors]float64
+	var sum float64
+	neighbors := nearest[:count]
+	for j, nb := range neighbors {
+		if nb.angle < 1e-9 {
+			// measured direction
+			neighbors, weights[0], sum = nearest[j:j+1], 1, 1
+			break
+		}
+		weights[j] = 1 / nb.angle
+		sum += weights[j]
+	}
+	gain := 1 / math.Max(p.Distance, 1)
+	for j, nb := range neighbors {
+		w := weights[j] / sum * gain
+		h := sp.set.HRIRs[nb.i]
+		for k, s := range h.Left {
+			sp.h[0][k] += s * w
+		}
+		for k, s := range h.Right {
+			sp.h[1][k] += s * w
+		}
+	}
+	for c := range dst {
+		sp.conv.partition(sp.h[c], dst[c])
+	}
+}
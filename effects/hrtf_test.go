@@ -0,0 +1,183 @@
+package effects_test
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+	"github.com/brotholo/beep/wav"
+)
+
+func testHRIRSet() *effects.HRIRSet {
+	set := effects.NewHRIRSet(44100)
+	set.Add(effects.HRIR{Direction: effects.Direction{Azimuth: 90}, Left: []float64{1}, Right: []float64{0, 0.5}})
+	set.Add(effects.HRIR{Direction: effects.Direction{Azimuth: -90}, Left: []float64{0, 0.5}, Right: []float64{1}})
+	return set
+}
+
+func TestSpatializer(t *testing.T) {
+	tests := []struct {
+		pos  effects.Position
+		want [][2]float64
+	}{
+		{effects.Position{Direction: effects.Direction{Azimuth: 90}, Distance: 1}, [][2]float64{{1, 0}, {0, 0.5}}},
+		{effects.Position{Direction: effects.Direction{Azimuth: 270}, Distance: 0.5}, [][2]float64{{0, 1}, {0.5, 0}}},
+		{effects.Position{Direction: effects.Direction{Azimuth: 90}, Distance: 2}, [][2]float64{{0.5, 0}, {0, 0.25}}},
+		// in front, halfway between the measured directions
+		{effects.Position{Distance: 1}, [][2]float64{{0.5, 0.5}, {0.25, 0.25}}},
+	}
+	for _, tt := range tests {
+		sp := effects.NewSpatializer(beeptest.Impulse(1, 0), testHRIRSet())
+		sp.Position = tt.pos
+		v := beeptest.Validate(sp)
+		got := beeptest.Collect(v, 0)
+		if !v.Valid() {
+			t.Fatalf("%+v: spatializer violates the Streamer contract: %v", tt.pos, v.Violations())
+		}
+		// delayed by the block size
+		want := append(make([][2]float64, effects.SpatializerBlockSize), tt.want...)
+		if err := beeptest.Diff(got, want, 1e-12); err != nil {
+			t.Errorf("%+v: %v", tt.pos, err)
+		}
+	}
+}
+
+func TestSpatializerMoving(t *testing.T) {
+	sp := effects.NewSpatializer(beeptest.Constant(1000, [2]float64{1, 1}), testHRIRSet())
+	sp.Position.Azimuth = 90
+	before := beeptest.Collect(beep.Take(500, sp), 0)
+	sp.Position.Azimuth = -90
+	after := beeptest.Collect(sp, 0)
+
+	// the switch from left to right is crossfaded, not a jump
+	last := before[len(before)-1]
+	for i, s := range after[:10] {
+		if math.Abs(s[0]-last[0]) > 0.2 || math.Abs(s[1]-last[1]) > 0.2 {
+			t.Fatalf("sample %d after the move is %v, jumped from %v", i, s, last)
+		}
+		last = s
+	}
+	if s := after[200]; math.Abs(s[0]-0.5) > 1e-12 || math.Abs(s[1]-1) > 1e-12 {
+		t.Errorf("after the move the sample is %v, want [0.5 1]", s)
+	}
+
+	// moving doesn't allocate on the audio path
+	sp = effects.NewSpatializer(beep.Silence(-1), testHRIRSet())
+	buf := make([][2]float64, 512)
+	sp.Stream(buf)
+	allocs := testing.AllocsPerRun(10, func() {
+		sp.Position.Azimuth += 10
+		sp.Stream(buf)
+	})
+	if allocs > 0 {
+		t.Errorf("Stream allocates %v times per call while moving", allocs)
+	}
+}
+
+func TestHRIRSetAddWAV(t *testing.T) {
+	format := beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 3}
+	data := [][2]float64{{0.5, 0}, {0.25, 0.5}, {0, 0.25}}
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "hrir.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wav.Encode(f, beeptest.Samples(data), format); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	decoded, _, err := wav.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := beeptest.Collect(decoded, 0)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	set := effects.NewHRIRSet(44100)
+	if err := set.AddWAV(f, effects.Direction{Azimuth: 30, Elevation: 10}); err != nil {
+		t.Fatal(err)
+	}
+	h := set.HRIRs[0]
+	if h.Azimuth != 30 || h.Elevation != 10 || len(h.Left) != len(data) || len(h.Right) != len(data) {
+		t.Fatalf("added HRIR %+v", h)
+	}
+	for i := range want {
+		if h.Left[i] != want[i][0] || h.Right[i] != want[i][1] {
+			t.Errorf("sample %d is %v, %v, want %v", i, h.Left[i], h.Right[i], want[i])
+		}
+	}
+}
+
+func TestHRIRSetAddSOFA(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "hrir.sofa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// three measurements of 4 taps at 48 kHz, cartesian source positions and the right ear delayed
+	// by a sample
+	set := effects.NewHRIRSet(48000)
+	if err := set.AddSOFA(f); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.HRIRs) != 3 {
+		t.Fatalf("added %d HRIRs, want 3", len(set.HRIRs))
+	}
+	dirs := []effects.Direction{{Azimuth: 0}, {Azimuth: 90}, {Azimuth: -90, Elevation: 45}}
+	for m, h := range set.HRIRs {
+		if math.Abs(h.Azimuth-dirs[m].Azimuth) > 1e-9 || math.Abs(h.Elevation-dirs[m].Elevation) > 1e-9 {
+			t.Errorf("HRIR %d is from %+v, want %+v", m, h.Direction, dirs[m])
+		}
+		left, right := make([]float64, 5), make([]float64, 5)
+		for n := 0; n < 4; n++ {
+			left[n] = float64(m+1) * float64(n+1) / 8
+			right[n+1] = -left[n]
+		}
+		if !reflect.DeepEqual(h.Left, left) || !reflect.DeepEqual(h.Right, right) {
+			t.Errorf("HRIR %d is %v, %v, want %v, %v", m, h.Left, h.Right, left, right)
+		}
+	}
+
+	resampled := effects.NewHRIRSet(24000)
+	if err := resampled.AddSOFA(f); err != nil {
+		t.Fatal(err)
+	}
+	if h := resampled.HRIRs[0]; len(h.Left) >= 5 || len(h.Left) != len(h.Right) {
+		t.Errorf("HRIR resampled to 24 kHz is %v, %v", h.Left, h.Right)
+	}
+
+	if err := set.AddSOFA(strings.NewReader("not a SOFA file")); err == nil {
+		t.Error("AddSOFA decoded an invalid file")
+	}
+}
+
+func TestHRIRSetAddSOFACorrupt(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "hrir.sofa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every corrupted byte and every truncation must be reported as an error or decoded, without
+	// panicking or allocating absurd amounts of memory
+	corrupt := make([]byte, len(data))
+	for i := range data {
+		for _, b := range []byte{data[i] ^ 0xff, data[i] + 1, 0, 0x7f, 0x80} {
+			copy(corrupt, data)
+			corrupt[i] = b
+			effects.NewHRIRSet(48000).AddSOFA(bytes.NewReader(corrupt))
+		}
+		effects.NewHRIRSet(48000).AddSOFA(bytes.NewReader(data[:i]))
+	}
+}
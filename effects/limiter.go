@@ -0,0 +1,248 @@
+package effects
+
+import (
+	"math"
+	"time"
+
+	"github.com/brotholo/beep"
+)
+
+// Limiter is a look-ahead brickwall limiter. It keeps the peaks of the wrapped Streamer within
+// the ceiling without the distortion of hard clipping.
+//
+// Limiter delays the audio by the look-ahead time, which lets it lower the gain smoothly before
+// a peak arrives. Once the peak has passed, the gain recovers with the release time. Audio which
+// never exceeds the ceiling passes through unchanged, only delayed.
+//
+// When the wrapped Streamer is drained, Limiter streams the remaining delayed audio and then
+// drains too.
+type Limiter struct {
+	Streamer beep.Streamer
+
+	sidechain beep.Streamer
+	scBuf     [][2]float64
+	ceiling   float64
+	drive     float64
+	release   float64 // per sample smoothing coefficient of the gain recovery
+	linked    bool
+
+	delay [][2]float64 // look-ahead delay line
+	dpos  int
+
+	ch   [2]limiterGain
+	gain [2]float64 // last applied gain of the channels
+
+	drained bool
+	tail    int // delayed samples left to stream after the wrapped Streamer is drained
+}
+
+// NewLimiter returns a Limiter which limits s to ceiling (a linear amplitude, 1 is full scale),
+// looking ahead by lookahead and recovering over release.
+func NewLimiter(s beep.Streamer, sr beep.SampleRate, ceiling float64, lookahead, release time.Duration) *Limiter {
+	return NewLookaheadLimiter(s, sr, Dynamics{
+		Threshold: toDB(ceiling),
+		Attack:    lookahead,
+		Release:   release,
+	})
+}
+
+// NewLookaheadLimiter returns a Limiter of s configured by d. Threshold is the ceiling in dBFS,
+// Attack is the look-ahead time and MakeUp amplifies the signal before limiting. Ratio, Knee and
+// Range are not used.
+func NewLookaheadLimiter(s beep.Streamer, sr beep.SampleRate, d Dynamics) *Limiter {
+	delay := sr.N(d.Attack)
+	if delay < 0 {
+		delay = 0
+	}
+
+	coef := 1.0
+	if n := sr.N(d.Release); n > 0 {
+		coef = 1 - math.Exp(-1/float64(n))
+	}
+
+	return &Limiter{
+		Streamer:  s,
+		sidechain: d.Sidechain,
+		ceiling:   fromDB(d.Threshold),
+		drive:     fromDB(d.MakeUp),
+		release:   coef,
+		linked:    !d.Unlinked,
+		delay:     make([][2]float64, delay),
+		ch:        [2]limiterGain{newLimiterGain(delay + 1), newLimiterGain(delay + 1)},
+		gain:      [2]float64{1, 1},
+		tail:      delay,
+	}
+}
+
+// Stream streams the wrapped Streamer limited to the ceiling.
+func (l *Limiter) Stream(samples [][2]float64) (n int, ok bool) {
+	if len(samples) == 0 {
+		if l.tail > 0 {
+			return 0, true
+		}
+		if l.drained {
+			return 0, false
+		}
+		return l.Streamer.Stream(samples)
+	}
+
+	if !l.drained {
+		n, ok = l.Streamer.Stream(samples)
+		detect := samples[:n]
+		if l.sidechain != nil {
+			detect = streamSidechain(l.sidechain, &l.scBuf, n)
+		}
+		for i := range samples[:n] {
+			samples[i] = l.process(samples[i], detect[i])
+		}
+		if n == len(samples) {
+			return n, ok
+		}
+		l.drained = true
+		if l.Streamer.Err() != nil {
+			l.tail = 0
+		}
+	}
+
+	// the wrapped Streamer is drained, flush the delay line
+	m := len(samples) - n
+	if m > l.tail {
+		m = l.tail
+	}
+	for i := n; i < n+m; i++ {
+		samples[i] = l.process([2]float64{}, [2]float64{})
+	}
+	l.tail -= m
+	n += m
+	return n, n > 0
+}
+
+// Err propagates the wrapped Streamer's errors. Errors of the sidechain are ignored.
+func (l *Limiter) Err() error {
+	return l.Streamer.Err()
+}
+
+// GainReduction returns the gain reduction currently applied by the Limiter in decibels. It is 0
+// when the Limiter doesn't limit. If the channels are unlinked, it's the reduction of the more
+// reduced channel.
+//
+// If the Limiter is playing through the speaker, lock the speaker before calling GainReduction.
+func (l *Limiter) GainReduction() float64 {
+	return -20 * math.Log10(math.Min(l.gain[0], l.gain[1]))
+}
+
+// process feeds a sample and the corresponding detection sample into the Limiter and returns the
+// limited delayed sample.
+func (l *Limiter) process(x, detect [2]float64) [2]float64 {
+	for c := range x {
+		x[c] *= l.drive
+	}
+	if l.sidechain == nil {
+		detect = x
+	} else {
+		for c := range detect {
+			detect[c] *= l.drive
+		}
+	}
+
+	if l.linked {
+		peak := math.Max(math.Abs(detect[0]), math.Abs(detect[1]))
+		l.gain[0] = l.ch[0].next(l.required(peak), l.release)
+		l.gain[1] = l.gain[0]
+	} else {
+		for c := range detect {
+			l.gain[c] = l.ch[c].next(l.required(math.Abs(detect[c])), l.release)
+		}
+	}
+
+	y := x
+	if len(l.delay) > 0 {
+		y, l.delay[l.dpos] = l.delay[l.dpos], x
+		l.dpos = (l.dpos + 1) % len(l.delay)
+	}
+	for c := range y {
+		// the clamp only catches rounding errors of the moving average, and peaks of the signal
+		// not covered by the sidechain
+		y[c] = math.Max(-l.ceiling, math.Min(y[c]*l.gain[c], l.ceiling))
+	}
+	return y
+}
+
+// required returns the gain required to keep peak within the ceiling.
+func (l *Limiter) required(peak float64) float64 {
+	if peak > l.ceiling {
+		return l.ceiling / peak
+	}
+	return 1
+}
+
+// limiterGain computes the gain of a channel of the Limiter.
+type limiterGain struct {
+	mins slidingMin // minimum of the required gain over the window
+	avg  []float64  // window of the released gain, smoothed by a moving average
+	apos int
+	sum  float64
+	env  float64 // released gain
+}
+
+func newLimiterGain(window int) limiterGain {
+	avg := make([]float64, window)
+	for i := range avg {
+		avg[i] = 1
+	}
+	return limiterGain{
+		mins: newSlidingMin(window),
+		avg:  avg,
+		sum:  float64(window),
+		env:  1,
+	}
+}
+
+// next feeds the gain required by the newest sample and returns the gain of the delayed sample.
+func (lg *limiterGain) next(required, release float64) float64 {
+	// The minimum over the window covers the delayed sample, so does the moving average of the
+	// minimums. Released gain never exceeds the minimum, which keeps the guarantee.
+	floor := lg.mins.push(required)
+	if floor < lg.env {
+		lg.env = floor
+	} else {
+		lg.env += (floor - lg.env) * release
+	}
+	lg.sum += lg.env - lg.avg[lg.apos]
+	lg.avg[lg.apos] = lg.env
+	lg.apos = (lg.apos + 1) % len(lg.avg)
+	return math.Min(lg.sum/float64(len(lg.avg)), 1)
+}
+
+// slidingMin computes the minimum of the last window values pushed into it.
+type slidingMin struct {
+	vals []float64
+	idx  []int
+	head int
+	size int
+	t    int
+}
+
+func newSlidingMin(window int) slidingMin {
+	return slidingMin{
+		vals: make([]float64, window),
+		idx:  make([]int, window),
+	}
+}
+
+// push adds v and returns the minimum of the window ending with v.
+func (m *slidingMin) push(v float64) float64 {
+	window := len(m.vals)
+	for m.size > 0 && m.idx[m.head] <= m.t-window {
+		m.head = (m.head + 1) % window
+		m.size--
+	}
+	for m.size > 0 && m.vals[(m.head+m.size-1)%window] >= v {
+		m.size--
+	}
+	i := (m.head + m.size) % window
+	m.vals[i], m.idx[i] = v, m.t
+	m.size++
+	m.t++
+	return m.vals[m.head]
+}
@@ -0,0 +1,64 @@
+package effects_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+)
+
+func TestLimiter(t *testing.T) {
+	const (
+		sr      = beep.SampleRate(44100)
+		ceiling = 0.8
+	)
+	lookahead := sr.N(5 * time.Millisecond)
+
+	data := make([][2]float64, 4410)
+	for i := range data {
+		amp := 0.5
+		if i >= 1000 && i < 2000 {
+			amp = 2 // a loud burst
+		}
+		v := amp * math.Sin(2*math.Pi*440*float64(i)/float64(sr))
+		data[i] = [2]float64{v, -v}
+	}
+
+	l := effects.NewLimiter(beeptest.Samples(data), sr, ceiling, 5*time.Millisecond, 10*time.Millisecond)
+	v := beeptest.Validate(l)
+	got := beeptest.Collect(v, 100)
+	if !v.Valid() {
+		t.Fatalf("limiter violates the Streamer contract: %v", v.Violations())
+	}
+	if len(got) != len(data)+lookahead {
+		t.Fatalf("limiter streamed %d samples, want %d", len(got), len(data)+lookahead)
+	}
+
+	var peak float64
+	for _, sample := range got {
+		peak = math.Max(peak, math.Max(math.Abs(sample[0]), math.Abs(sample[1])))
+	}
+	if peak > ceiling {
+		t.Errorf("peak is %v, exceeds ceiling %v", peak, ceiling)
+	}
+
+	// before the burst arrives in the look-ahead window, the audio passes unchanged
+	if err := beeptest.Diff(got[lookahead:lookahead+700], data[:700], 1e-12); err != nil {
+		t.Errorf("quiet audio is not passed through unchanged: %v", err)
+	}
+	if l.GainReduction() > 0.1 {
+		t.Errorf("gain reduction %vdB after the burst has released", l.GainReduction())
+	}
+}
+
+func TestLimiterGainReduction(t *testing.T) {
+	const sr = beep.SampleRate(8000)
+	l := effects.NewLimiter(beeptest.Constant(400, [2]float64{1, 1}), sr, 0.5, 0, time.Second)
+	beeptest.Collect(l, 400)
+	if gr := l.GainReduction(); math.Abs(gr-20*math.Log10(2)) > 1e-9 {
+		t.Errorf("gain reduction is %vdB, want %vdB", gr, 20*math.Log10(2))
+	}
+}
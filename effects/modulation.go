@@ -0,0 +1,380 @@
+package effects
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/brotholo/beep"
+)
+
+// Waveform is the shape of the wave of an LFO.
+type Waveform int
+
+const (
+	// Sine is a sine wave.
+	Sine Waveform = iota
+
+	// Triangle is a triangle wave, which sweeps at a constant speed.
+	Triangle
+
+	// Square is a square wave, which switches between the extremes.
+	Square
+
+	// Saw is a rising sawtooth wave.
+	Saw
+
+	// Random holds a new random value for each cycle.
+	Random
+)
+
+// LFO is a low frequency oscillator, which modulates the parameters of the modulation effects:
+// Tremolo, Vibrato, Chorus, Flanger and Phaser. It produces a value for each channel in the range
+// [-Depth, +Depth].
+//
+// The fields can be changed while streaming. If the LFO is playing through the speaker, lock the
+// speaker before changing them.
+type LFO struct {
+	// Shape is the waveform of the LFO.
+	Shape Waveform
+
+	// Rate is the frequency of the LFO in Hz.
+	Rate float64
+
+	// Depth is the amount of the modulation, from 0 (none) to 1 (full).
+	Depth float64
+
+	// StereoPhase is the phase of the right channel relative to the left one, in cycles. For
+	// example 0.25 shifts the right channel by a quarter of a cycle, which widens the stereo image
+	// of the effects.
+	StereoPhase float64
+
+	sr    beep.SampleRate
+	phase float64
+	prev  [2]float64 // phase of the channels at the previous value
+	held  [2]float64 // values of the Random shape
+}
+
+// NewLFO returns an LFO with the shape, rate in Hz and depth, running at the sample rate sr.
+func NewLFO(sr beep.SampleRate, shape Waveform, rate, depth float64) *LFO {
+	return &LFO{Shape: shape, Rate: rate, Depth: depth, sr: sr}
+}
+
+// Next returns the values of the LFO for the current sample of both channels and advances it by a
+// sample.
+func (l *LFO) Next() [2]float64 {
+	var v [2]float64
+	for c := range v {
+		p := l.phase + float64(c)*l.StereoPhase
+		p -= math.Floor(p)
+		v[c] = l.Depth * l.wave(c, p)
+		l.prev[c] = p
+	}
+	l.phase += l.Rate / float64(l.sr)
+	l.phase -= math.Floor(l.phase)
+	return v
+}
+
+// wave returns the value of the shape at the phase p of the channel c.
+func (l *LFO) wave(c int, p float64) float64 {
+	switch l.Shape {
+	case Triangle:
+		q := p + 0.25
+		q -= math.Floor(q)
+		return 1 - 4*math.Abs(q-0.5)
+	case Square:
+		if p < 0.5 {
+			return 1
+		}
+		return -1
+	case Saw:
+		return 2*p - 1
+	case Random:
+		if p < l.prev[c] || l.held[c] == 0 {
+			// new cycle
+			l.held[c] = rand.Float64()*2 - 1
+		}
+		return l.held[c]
+	default:
+		return math.Sin(2 * math.Pi * p)
+	}
+}
+
+// Tremolo modulates the volume of the wrapped Streamer by an LFO. The gain sweeps between 1 and
+// 1-Depth.
+type Tremolo struct {
+	Streamer beep.Streamer
+	LFO
+
+	// Mix is the gain of the modulated signal, the original signal is mixed in with 1-Mix.
+	Mix float64
+}
+
+// NewTremolo returns a Tremolo of s at 5 Hz with a sine LFO, Depth 0.5 and Mix 1.
+func NewTremolo(s beep.Streamer, sr beep.SampleRate) *Tremolo {
+	return &Tremolo{
+		Streamer: s,
+		LFO:      LFO{Shape: Sine, Rate: 5, Depth: 0.5, sr: sr},
+		Mix:      1,
+	}
+}
+
+// Stream streams the wrapped Streamer with the modulated volume.
+func (t *Tremolo) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = t.Streamer.Stream(samples)
+	for i := range samples[:n] {
+		v := t.Next()
+		for c := range v {
+			gain := 1 - t.Depth/2 + v[c]/2
+			samples[i][c] *= 1 - t.Mix + gain*t.Mix
+		}
+	}
+	return n, ok
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (t *Tremolo) Err() error {
+	return t.Streamer.Err()
+}
+
+// modDelay is the common implementation of the effects built on a modulated delay line: Vibrato,
+// Chorus and Flanger.
+type modDelay struct {
+	sr    beep.SampleRate
+	lines [2]delayLine
+	tail  tail
+}
+
+func newModDelay(sr beep.SampleRate, maxDelay time.Duration) modDelay {
+	size := sr.N(maxDelay) + 2
+	return modDelay{
+		sr:    sr,
+		lines: [2]delayLine{newDelayLine(size), newDelayLine(size)},
+		tail:  tail{length: -1, window: size},
+	}
+}
+
+// process sweeps the delay between delay and delay+width by the LFO, feeds the delayed signal back
+// with feedback and mixes it with the original signal.
+func (m *modDelay) process(samples [][2]float64, lfo *LFO, delay, width time.Duration, feedback, mix float64) {
+	base := float64(m.sr.N(delay))
+	sweep := float64(m.sr.N(width))
+	for i, x := range samples {
+		v := lfo.Next()
+		for c := range v {
+			y := m.lines[c].read(base + sweep*(1+v[c])/2)
+			m.lines[c].write(x[c] + y*feedback)
+			samples[i][c] = x[c]*(1-mix) + y*mix
+		}
+	}
+}
+
+// Vibrato modulates the pitch of the wrapped Streamer by an LFO sweeping its delay.
+type Vibrato struct {
+	Streamer beep.Streamer
+	LFO
+
+	// Width is the delay swept by the LFO at full depth. Together with Rate it sets how far the
+	// pitch bends.
+	Width time.Duration
+
+	// Mix is the gain of the modulated signal, the original signal is mixed in with 1-Mix.
+	Mix float64
+
+	core modDelay
+}
+
+// NewVibrato returns a Vibrato of s at 5 Hz with a sine LFO, full Depth, Width 2ms and Mix 1.
+// Width can be changed up to maxWidth.
+func NewVibrato(s beep.Streamer, sr beep.SampleRate, maxWidth time.Duration) *Vibrato {
+	return &Vibrato{
+		Streamer: s,
+		LFO:      LFO{Shape: Sine, Rate: 5, Depth: 1, sr: sr},
+		Width:    2 * time.Millisecond,
+		Mix:      1,
+		core:     newModDelay(sr, maxWidth),
+	}
+}
+
+// Stream streams the wrapped Streamer with the modulated pitch.
+func (v *Vibrato) Stream(samples [][2]float64) (n int, ok bool) {
+	return v.core.tail.stream(v.Streamer, samples, func(samples [][2]float64) {
+		v.core.process(samples, &v.LFO, 0, v.Width, 0, v.Mix)
+	})
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (v *Vibrato) Err() error {
+	return v.Streamer.Err()
+}
+
+// Chorus mixes the wrapped Streamer with a copy of it delayed by a slowly swept delay, which
+// sounds like several voices or instruments playing together.
+type Chorus struct {
+	Streamer beep.Streamer
+	LFO
+
+	// Delay is the shortest delay of the copy and Width is the delay swept by the LFO at full
+	// depth on top of it.
+	Delay time.Duration
+	Width time.Duration
+
+	// Mix is the gain of the delayed copy, the original signal is mixed in with 1-Mix.
+	Mix float64
+
+	core modDelay
+}
+
+// NewChorus returns a Chorus of s at 0.8 Hz with a sine LFO, full Depth, StereoPhase 0.25, Delay
+// 15ms, Width 5ms and Mix 0.5. Delay and Width can be changed up to maxDelay together.
+func NewChorus(s beep.Streamer, sr beep.SampleRate, maxDelay time.Duration) *Chorus {
+	return &Chorus{
+		Streamer: s,
+		LFO:      LFO{Shape: Sine, Rate: 0.8, Depth: 1, StereoPhase: 0.25, sr: sr},
+		Delay:    15 * time.Millisecond,
+		Width:    5 * time.Millisecond,
+		Mix:      0.5,
+		core:     newModDelay(sr, maxDelay),
+	}
+}
+
+// Stream streams the wrapped Streamer with the chorus.
+func (c *Chorus) Stream(samples [][2]float64) (n int, ok bool) {
+	return c.core.tail.stream(c.Streamer, samples, func(samples [][2]float64) {
+		c.core.process(samples, &c.LFO, c.Delay, c.Width, 0, c.Mix)
+	})
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (c *Chorus) Err() error {
+	return c.Streamer.Err()
+}
+
+// Flanger mixes the wrapped Streamer with a copy of it delayed by a very short swept delay, with
+// feedback. The resulting comb filter sweeps through the spectrum with the familiar jet sound.
+type Flanger struct {
+	Streamer beep.Streamer
+	LFO
+
+	// Delay is the shortest delay of the copy and Width is the delay swept by the LFO at full
+	// depth on top of it.
+	Delay time.Duration
+	Width time.Duration
+
+	// Feedback is the gain of the delayed copy fed back into the delay, from -1 to 1 exclusive.
+	// Negative feedback emphasizes odd harmonics of the comb filter.
+	Feedback float64
+
+	// Mix is the gain of the delayed copy, the original signal is mixed in with 1-Mix.
+	Mix float64
+
+	core modDelay
+}
+
+// NewFlanger returns a Flanger of s at 0.25 Hz with a triangle LFO, full Depth, Delay 1ms, Width
+// 4ms, Feedback 0.5 and Mix 0.5. Delay and Width can be changed up to maxDelay together.
+func NewFlanger(s beep.Streamer, sr beep.SampleRate, maxDelay time.Duration) *Flanger {
+	return &Flanger{
+		Streamer: s,
+		LFO:      LFO{Shape: Triangle, Rate: 0.25, Depth: 1, sr: sr},
+		Delay:    time.Millisecond,
+		Width:    4 * time.Millisecond,
+		Feedback: 0.5,
+		Mix:      0.5,
+		core:     newModDelay(sr, maxDelay),
+	}
+}
+
+// Stream streams the wrapped Streamer with the flanger.
+func (f *Flanger) Stream(samples [][2]float64) (n int, ok bool) {
+	return f.core.tail.stream(f.Streamer, samples, func(samples [][2]float64) {
+		f.core.process(samples, &f.LFO, f.Delay, f.Width, f.Feedback, f.Mix)
+	})
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (f *Flanger) Err() error {
+	return f.Streamer.Err()
+}
+
+// Phaser mixes the wrapped Streamer with a copy of it passed through a chain of allpass filters,
+// whose frequency is swept by an LFO. The notches where the copy cancels the original signal
+// sweep through the spectrum.
+type Phaser struct {
+	Streamer beep.Streamer
+	LFO
+
+	// Stages is the number of allpass filters. Each two stages make a notch.
+	Stages int
+
+	// MinFreq and MaxFreq in Hz are the range swept by the LFO at full depth.
+	MinFreq float64
+	MaxFreq float64
+
+	// Feedback is the gain of the filtered copy fed back into the filters, from -1 to 1 exclusive.
+	Feedback float64
+
+	// Mix is the gain of the filtered copy, the original signal is mixed in with 1-Mix.
+	Mix float64
+
+	sr    beep.SampleRate
+	state [2][]float64 // state of the allpass filters
+	last  [2]float64   // last output of the filters, for the feedback
+	tail  tail
+}
+
+// NewPhaser returns a Phaser of s at 0.5 Hz with a sine LFO, full Depth, 4 Stages sweeping from
+// 200 Hz to 2000 Hz, Feedback 0.3 and Mix 0.5.
+func NewPhaser(s beep.Streamer, sr beep.SampleRate) *Phaser {
+	return &Phaser{
+		Streamer: s,
+		LFO:      LFO{Shape: Sine, Rate: 0.5, Depth: 1, sr: sr},
+		Stages:   4,
+		MinFreq:  200,
+		MaxFreq:  2000,
+		Feedback: 0.3,
+		Mix:      0.5,
+		sr:       sr,
+		tail:     tail{length: -1, window: sr.N(10 * time.Millisecond)},
+	}
+}
+
+// Stream streams the wrapped Streamer with the phaser.
+func (p *Phaser) Stream(samples [][2]float64) (n int, ok bool) {
+	return p.tail.stream(p.Streamer, samples, p.process)
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (p *Phaser) Err() error {
+	return p.Streamer.Err()
+}
+
+func (p *Phaser) process(samples [][2]float64) {
+	for c := range p.state {
+		if len(p.state[c]) != p.Stages {
+			p.state[c] = make([]float64, p.Stages)
+		}
+	}
+	nyquist := float64(p.sr) / 2
+	minFreq := math.Max(1, math.Min(p.MinFreq, nyquist*0.99))
+	maxFreq := math.Max(minFreq, math.Min(p.MaxFreq, nyquist*0.99))
+
+	for i, x := range samples {
+		v := p.Next()
+		for c := range v {
+			// the sweep is exponential, so it sounds even
+			freq := minFreq * math.Pow(maxFreq/minFreq, (1+v[c])/2)
+			t := math.Tan(math.Pi * freq / float64(p.sr))
+			a := (t - 1) / (t + 1)
+
+			y := x[c] + p.last[c]*p.Feedback
+			for j, z := range p.state[c] {
+				out := a*y + z
+				p.state[c][j] = y - a*out
+				y = out
+			}
+			p.last[c] = y
+			samples[i][c] = x[c]*(1-p.Mix) + y*p.Mix
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package effects_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+)
+
+func TestLFO(t *testing.T) {
+	tests := []struct {
+		shape effects.Waveform
+		want  [4]float64 // values at the quarters of the cycle
+	}{
+		{effects.Sine, [4]float64{0, 1, 0, -1}},
+		{effects.Triangle, [4]float64{0, 1, 0, -1}},
+		{effects.Square, [4]float64{1, 1, -1, -1}},
+		{effects.Saw, [4]float64{-1, -0.5, 0, 0.5}},
+	}
+	for _, tt := range tests {
+		// 4 samples per cycle, the right channel a quarter of a cycle ahead
+		lfo := effects.NewLFO(4, tt.shape, 1, 0.5)
+		lfo.StereoPhase = 0.25
+		for i, want := range tt.want {
+			v := lfo.Next()
+			if math.Abs(v[0]-want*0.5) > 1e-12 {
+				t.Errorf("shape %v: value %d is %v, want %v", tt.shape, i, v[0], want*0.5)
+			}
+			if math.Abs(v[1]-tt.want[(i+1)%4]*0.5) > 1e-12 {
+				t.Errorf("shape %v: right value %d is %v, want %v", tt.shape, i, v[1], tt.want[(i+1)%4]*0.5)
+			}
+		}
+	}
+
+	lfo := effects.NewLFO(8, effects.Random, 1, 1)
+	first := lfo.Next()
+	for i := 1; i < 8; i++ {
+		if v := lfo.Next(); v != first {
+			t.Fatalf("random value changed within a cycle: %v, then %v", first, v)
+		}
+	}
+	if math.Abs(first[0]) > 1 {
+		t.Errorf("random value %v is out of range", first[0])
+	}
+}
+
+func TestTremolo(t *testing.T) {
+	tr := effects.NewTremolo(beeptest.Constant(4, [2]float64{1, 1}), 4)
+	tr.Rate, tr.Depth = 1, 0.5
+	got := beeptest.Collect(tr, 0)
+	want := [][2]float64{{0.75, 0.75}, {1, 1}, {0.75, 0.75}, {0.5, 0.5}}
+	beeptest.AssertSamples(t, got, want, 1e-12)
+}
+
+func TestModulationEffects(t *testing.T) {
+	const sr = beep.SampleRate(44100)
+	source := func() beep.Streamer {
+		return beeptest.Samples(beeptest.Collect(beeptest.Impulse(sr.N(100*time.Millisecond), 0), 0))
+	}
+
+	effects := map[string]beep.Streamer{
+		"vibrato": effects.NewVibrato(source(), sr, 10*time.Millisecond),
+		"chorus":  effects.NewChorus(source(), sr, 30*time.Millisecond),
+		"flanger": effects.NewFlanger(source(), sr, 10*time.Millisecond),
+		"phaser":  effects.NewPhaser(source(), sr),
+	}
+	for name, s := range effects {
+		v := beeptest.Validate(s)
+		got := beeptest.Collect(v, 100)
+		if !v.Valid() {
+			t.Errorf("%s violates the Streamer contract: %v", name, v.Violations())
+		}
+		if len(got) < sr.N(100*time.Millisecond) || len(got) > sr.N(time.Second) {
+			t.Errorf("%s streamed %d samples", name, len(got))
+		}
+	}
+}
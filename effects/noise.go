@@ -0,0 +1,243 @@
+package effects
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/internal/fft"
+)
+
+const (
+	noiseFrameTime   = 25 * time.Millisecond // upper bound of the frame of NoiseReducer
+	noiseAdaptation  = 500 * time.Millisecond
+	noisePriorWeight = 0.98 // weight of the previous frame in the a priori SNR estimate
+)
+
+// SilenceDetector returns a detector of silent frames for NoiseReducer.Silent, which classifies a
+// frame as silent if its peak in both channels is below threshold.
+func SilenceDetector(threshold float64) func(frame [][2]float64) bool {
+	return func(frame [][2]float64) bool {
+		for _, s := range frame {
+			if math.Abs(s[0]) >= threshold || math.Abs(s[1]) >= threshold {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// NoiseReducer reduces steady background noise, such as the hiss and hum of a microphone, in the
+// wrapped Streamer, for example to improve speech recognition.
+//
+// NoiseReducer splits the audio into overlapping frames, estimates the signal-to-noise ratio of
+// each frequency against a noise profile and attenuates the frequencies dominated by noise, by a
+// Wiener filter with the decision-directed estimate of Ephraim and Malah, which avoids most of the
+// "musical noise" of plain spectral subtraction. Both channels get the same gains.
+//
+// The noise profile is learned from a stretch of noise by LearnNoise, or continuously from the
+// frames classified as silent by Silent, or both. Until there's a noise profile, the audio passes
+// unchanged.
+//
+// NoiseReducer works on live streams. It delays the audio by Latency samples, about 25ms. When the
+// wrapped Streamer is drained, NoiseReducer streams the delayed audio and then drains too.
+type NoiseReducer struct {
+	Streamer beep.Streamer
+
+	// Reduction is the highest attenuation of the noise in dB, for example 20. Higher values
+	// remove more noise, but may make the speech sound processed.
+	Reduction float64
+
+	// Silent classifies the frames of the input as silent, so they update the noise profile. nil
+	// disables the continuous learning. SilenceDetector makes a simple detector.
+	Silent func(frame [][2]float64) bool
+
+	plan   *fft.Plan
+	window []float64 // square root of the Hann window, for the analysis and the synthesis
+	hop    int
+	adapt  float64
+
+	noise   []float64 // noise power of the bins
+	learned bool
+	prior   []float64 // gains and posterior SNRs of the previous frame
+	post    []float64
+
+	in   [][2]float64 // last frame of the input
+	ola  [][2]float64 // overlap-add buffer of the output
+	out  [][2]float64 // output block being streamed
+	pos  int
+	spec []complex128
+
+	tail tail
+}
+
+// NewNoiseReducer returns a NoiseReducer of s with Reduction 20 dB, without a noise profile and
+// without continuous learning.
+func NewNoiseReducer(s beep.Streamer, sr beep.SampleRate) *NoiseReducer {
+	size := 64
+	for size*2 <= sr.N(noiseFrameTime) {
+		size *= 2
+	}
+	hop := size / 2
+	nr := &NoiseReducer{
+		Streamer:  s,
+		Reduction: 20,
+		plan:      fft.NewPlan(size),
+		window:    make([]float64, size),
+		hop:       hop,
+		adapt:     math.Exp(-float64(hop) / float64(sr.N(noiseAdaptation))),
+		noise:     make([]float64, size/2+1),
+		prior:     make([]float64, size/2+1),
+		post:      make([]float64, size/2+1),
+		in:        make([][2]float64, size),
+		ola:       make([][2]float64, size),
+		out:       make([][2]float64, hop),
+		spec:      make([]complex128, size),
+		tail:      tail{length: size},
+	}
+	for i := range nr.window {
+		// periodic, so the squared windows overlapping by half sum to 1
+		nr.window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size)))
+	}
+	return nr
+}
+
+// Latency returns the delay of the audio in samples.
+func (nr *NoiseReducer) Latency() int {
+	return len(nr.in)
+}
+
+// LearnNoise learns the noise profile from s, which should contain only the noise, for example a
+// few seconds recorded before the speech. It streams s until it drains. The profile replaces the
+// previous one.
+//
+// If the NoiseReducer is playing through the speaker, lock the speaker before calling LearnNoise.
+func (nr *NoiseReducer) LearnNoise(s beep.Streamer) {
+	size := len(nr.in)
+	frame := make([][2]float64, size)
+	power := make([]float64, len(nr.noise))
+	sum := make([]float64, len(nr.noise))
+	frames, filled := 0, size-nr.hop
+	for {
+		n, ok := s.Stream(frame[filled:])
+		filled += n
+		if filled == size {
+			nr.spectrum(frame, power)
+			for k, p := range power {
+				sum[k] += p
+			}
+			frames++
+			copy(frame, frame[nr.hop:])
+			filled = size - nr.hop
+		}
+		if !ok {
+			break
+		}
+	}
+	if frames == 0 {
+		return
+	}
+	for k := range nr.noise {
+		nr.noise[k] = sum[k] / float64(frames)
+	}
+	nr.learned = true
+}
+
+// Stream streams the wrapped Streamer with the noise reduced.
+func (nr *NoiseReducer) Stream(samples [][2]float64) (n int, ok bool) {
+	return nr.tail.stream(nr.Streamer, samples, nr.process)
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (nr *NoiseReducer) Err() error {
+	return nr.Streamer.Err()
+}
+
+func (nr *NoiseReducer) process(samples [][2]float64) {
+	size := len(nr.in)
+	for i, x := range samples {
+		samples[i] = nr.out[nr.pos]
+		nr.in[size-nr.hop+nr.pos] = x
+		nr.pos++
+		if nr.pos == nr.hop {
+			nr.frame()
+			copy(nr.in, nr.in[nr.hop:])
+			nr.pos = 0
+		}
+	}
+}
+
+// frame processes the last frame of the input and fills the output block.
+func (nr *NoiseReducer) frame() {
+	size := len(nr.in)
+	half := size / 2
+
+	// both channels in a single transform, the left one real and the right one imaginary
+	for i, x := range nr.in {
+		nr.spec[i] = complex(x[0]*nr.window[i], x[1]*nr.window[i])
+	}
+	nr.plan.Forward(nr.spec)
+
+	if nr.Silent != nil && nr.Silent(nr.in[size-nr.hop:]) {
+		for k := 0; k <= half; k++ {
+			p := nr.binPower(k)
+			if nr.learned {
+				nr.noise[k] = nr.noise[k]*nr.adapt + p*(1-nr.adapt)
+			} else {
+				nr.noise[k] = p
+			}
+		}
+		nr.learned = true
+	}
+
+	if nr.learned {
+		floor := math.Pow(10, -nr.Reduction/20)
+		for k := 0; k <= half; k++ {
+			gain := 1.0
+			if nr.noise[k] > 0 {
+				post := nr.binPower(k) / nr.noise[k]
+				prior := noisePriorWeight*nr.prior[k]*nr.prior[k]*nr.post[k] +
+					(1-noisePriorWeight)*math.Max(post-1, 0)
+				gain = math.Max(prior/(1+prior), floor)
+				nr.post[k] = post
+			}
+			nr.prior[k] = gain
+			nr.spec[k] *= complex(gain, 0)
+			if k > 0 && k < half {
+				nr.spec[size-k] *= complex(gain, 0)
+			}
+		}
+	}
+
+	nr.plan.Inverse(nr.spec)
+	for i, z := range nr.spec {
+		nr.ola[i][0] += real(z) * nr.window[i]
+		nr.ola[i][1] += imag(z) * nr.window[i]
+	}
+	copy(nr.out, nr.ola[:nr.hop])
+	copy(nr.ola, nr.ola[nr.hop:])
+	for i := size - nr.hop; i < size; i++ {
+		nr.ola[i] = [2]float64{}
+	}
+}
+
+// binPower returns the power of the bin k of the transform of the current frame, averaged over
+// the channels.
+func (nr *NoiseReducer) binPower(k int) float64 {
+	size := len(nr.spec)
+	z, zc := nr.spec[k], cmplx.Conj(nr.spec[(size-k)%size])
+	l, r := (z+zc)/2, (z-zc)/2 // the right one times i, which doesn't change the power
+	return (real(l)*real(l) + imag(l)*imag(l) + real(r)*real(r) + imag(r)*imag(r)) / 2
+}
+
+// spectrum computes the power of the bins of the frame into power.
+func (nr *NoiseReducer) spectrum(frame [][2]float64, power []float64) {
+	for i, x := range frame {
+		nr.spec[i] = complex(x[0]*nr.window[i], x[1]*nr.window[i])
+	}
+	nr.plan.Forward(nr.spec)
+	for k := range power {
+		power[k] = nr.binPower(k)
+	}
+}
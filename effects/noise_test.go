@@ -0,0 +1,119 @@
+package effects_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+)
+
+func whiteNoise(rng *rand.Rand, level float64, num int) [][2]float64 {
+	data := make([][2]float64, num)
+	for i := range data {
+		data[i] = [2]float64{level * rng.NormFloat64(), level * rng.NormFloat64()}
+	}
+	return data
+}
+
+func energy(samples [][2]float64) float64 {
+	var e float64
+	for _, s := range samples {
+		e += s[0]*s[0] + s[1]*s[1]
+	}
+	return e
+}
+
+func TestNoiseReducerPassThrough(t *testing.T) {
+	const sr = beep.SampleRate(44100)
+	data := whiteNoise(rand.New(rand.NewSource(1)), 0.1, 5000)
+
+	// without a noise profile the audio is only delayed
+	nr := effects.NewNoiseReducer(beeptest.Samples(data), sr)
+	v := beeptest.Validate(nr)
+	got := beeptest.Collect(v, 300)
+	if !v.Valid() {
+		t.Fatalf("noise reducer violates the Streamer contract: %v", v.Violations())
+	}
+	lat := nr.Latency()
+	if len(got) != len(data)+lat {
+		t.Fatalf("noise reducer streamed %d samples, want %d", len(got), len(data)+lat)
+	}
+	beeptest.AssertSamples(t, got[lat:], data, 1e-9)
+}
+
+func TestNoiseReducerLearned(t *testing.T) {
+	const sr = beep.SampleRate(16000)
+	rng := rand.New(rand.NewSource(2))
+
+	nr := effects.NewNoiseReducer(beeptest.Samples(nil), sr)
+	nr.LearnNoise(beeptest.Samples(whiteNoise(rng, 0.05, sr.N(1e9))))
+
+	noise := whiteNoise(rng, 0.05, sr.N(2e9))
+	tone := sine(sr, 1000, len(noise))
+	for i := range tone {
+		tone[i][0] *= 0.5
+		tone[i][1] *= 0.5
+	}
+	mixed := make([][2]float64, len(noise))
+	for i := range mixed {
+		mixed[i] = [2]float64{tone[i][0] + noise[i][0], tone[i][1] + noise[i][1]}
+	}
+	nr.Streamer = beeptest.Samples(mixed)
+	got := beeptest.Collect(nr, 0)
+
+	// compare after the start, leaving time to the gain estimate
+	lat, skip := nr.Latency(), sr.N(2e8)
+	got, tone, noise = got[lat+skip:lat+len(mixed)], tone[skip:], noise[skip:]
+	var residual float64
+	for i := range got {
+		e0, e1 := got[i][0]-tone[i][0], got[i][1]-tone[i][1]
+		residual += e0*e0 + e1*e1
+	}
+	if snrIn, snrOut := 10*math.Log10(energy(tone)/energy(noise)), 10*math.Log10(energy(tone)/residual); snrOut < snrIn+10 {
+		t.Errorf("noise reducer improves the SNR from %.1f dB to %.1f dB, want at least 10 dB more", snrIn, snrOut)
+	}
+}
+
+func TestNoiseReducerAdaptive(t *testing.T) {
+	const sr = beep.SampleRate(16000)
+	rng := rand.New(rand.NewSource(3))
+	noise := whiteNoise(rng, 0.01, sr.N(3e9))
+
+	nr := effects.NewNoiseReducer(beeptest.Samples(noise), sr)
+	nr.Silent = effects.SilenceDetector(0.1)
+	got := beeptest.Collect(nr, 0)
+
+	lat, skip := nr.Latency(), sr.N(1e9)
+	reduction := 10 * math.Log10(energy(got[lat+skip:lat+len(noise)])/energy(noise[skip:]))
+	if reduction > -15 {
+		t.Errorf("noise reducer learning continuously reduces the noise by %.1f dB, want at least 15 dB", -reduction)
+	}
+
+	// loud audio isn't classified as silent and doesn't become the noise profile
+	loud := whiteNoise(rng, 0.5, sr.N(1e9))
+	nr = effects.NewNoiseReducer(beeptest.Samples(loud), sr)
+	nr.Silent = effects.SilenceDetector(0.1)
+	got = beeptest.Collect(nr, 0)
+	// the silence flushing the delayed audio is learned, so leave out the end
+	beeptest.AssertSamples(t, got[lat:len(loud)], loud[:len(loud)-lat], 1e-9)
+}
+
+func TestSilenceDetector(t *testing.T) {
+	silent := effects.SilenceDetector(0.1)
+	tests := []struct {
+		frame [][2]float64
+		want  bool
+	}{
+		{[][2]float64{{0.05, -0.05}, {-0.09, 0.09}}, true},
+		{[][2]float64{{0.05, 0}, {-0.5, 0}}, false},
+		{[][2]float64{{0, 0.05}, {0, 0.5}}, false}, // only the right channel is loud
+	}
+	for _, tt := range tests {
+		if got := silent(tt.frame); got != tt.want {
+			t.Errorf("SilenceDetector(0.1)(%v) = %v, want %v", tt.frame, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package effects
 
-import "github.com/brotholo/beep"
+import (
+	"math"
+
+	"github.com/brotholo/beep"
+)
 
 // Pan balances the wrapped Streamer between the left and the right channel. The Pan field value of
 // -1 means that both original channels go through the left channel. The value of +1 means the same
@@ -34,3 +38,63 @@ func (p *Pan) Stream(samples [][2]float64) (n int, ok bool) {
 func (p *Pan) Err() error {
 	return p.Streamer.Err()
 }
+
+// PanLaw is the pan law of a Panner, the gain of the channels as the sound moves between them.
+// The laws differ in the level of the sound in the center.
+type PanLaw int
+
+const (
+	// ConstantPower keeps the power of the sound constant, the center is at -3 dB in each
+	// channel. It keeps the perceived loudness in most rooms.
+	ConstantPower PanLaw = iota
+
+	// Compromise is halfway between ConstantPower and Linear, the center is at -4.5 dB in each
+	// channel.
+	Compromise
+
+	// Linear keeps the sum of the amplitudes constant, the center is at -6 dB in each channel.
+	// The sound keeps its level when the channels are summed to mono, but dips in the middle when
+	// played in stereo.
+	Linear
+)
+
+// gains returns the gains of the left and the right channel for the position pan.
+func (law PanLaw) gains(pan float64) (left, right float64) {
+	pan = math.Max(-1, math.Min(pan, 1))
+	linL, linR := (1-pan)/2, (1+pan)/2
+	powR, powL := math.Sincos((pan + 1) * math.Pi / 4)
+	switch law {
+	case Linear:
+		return linL, linR
+	case Compromise:
+		return math.Sqrt(linL * powL), math.Sqrt(linR * powR)
+	default:
+		return powL, powR
+	}
+}
+
+// Panner positions the wrapped Streamer between the left and the right channel by the pan law Law.
+// Unlike Pan, which balances the channels, Panner pans the mono sum of the channels, the way a
+// mixing console pans a mono source. The Pan field value of -1 means fully left, +1 fully right
+// and 0 is the center.
+type Panner struct {
+	Streamer beep.Streamer
+	Pan      float64
+	Law      PanLaw
+}
+
+// Stream streams the wrapped Streamer panned by Pan.
+func (p *Panner) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = p.Streamer.Stream(samples)
+	left, right := p.Law.gains(p.Pan)
+	for i := range samples[:n] {
+		mix := (samples[i][0] + samples[i][1]) / 2
+		samples[i][0], samples[i][1] = mix*left, mix*right
+	}
+	return n, ok
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (p *Panner) Err() error {
+	return p.Streamer.Err()
+}
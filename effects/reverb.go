@@ -0,0 +1,145 @@
+package effects
+
+import (
+	"github.com/brotholo/beep"
+)
+
+// Tuning of the Freeverb algorithm, the delays are in samples at 44100 Hz.
+var (
+	reverbCombDelays    = [...]int{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	reverbAllpassDelays = [...]int{556, 441, 341, 225}
+)
+
+const (
+	reverbStereoSpread = 23 // delay difference of the right channel
+	reverbInputGain    = 0.015
+	reverbWetScale     = 3
+	reverbRoomScale    = 0.28
+	reverbRoomOffset   = 0.7
+	reverbDampScale    = 0.4
+)
+
+// Reverb is an algorithmic reverb based on Freeverb, the Schroeder-Moorer reverb by Jezar at
+// Dreampoint. The reverberation is made by eight parallel comb filters with damped feedback
+// followed by four allpass filters, tuned slightly differently for each channel.
+//
+// The parameters can be changed while streaming. If the Reverb is playing through the speaker,
+// lock the speaker before changing them.
+//
+// When the wrapped Streamer is drained, Reverb streams the reverberation until it decays to
+// silence and then drains too.
+type Reverb struct {
+	Streamer beep.Streamer
+
+	// RoomSize sets the length of the reverberation, from 0 (small room) to 1 (large hall).
+	RoomSize float64
+
+	// Damping sets the absorption of high frequencies, from 0 (bright) to 1 (dark).
+	Damping float64
+
+	// Width sets the stereo width of the reverberation, from 0 (mono) to 1 (full stereo).
+	Width float64
+
+	// Wet and Dry are the gains of the reverberation and of the original signal, from 0 to 1.
+	Wet float64
+	Dry float64
+
+	combs     [2][len(reverbCombDelays)]reverbComb
+	allpasses [2][len(reverbAllpassDelays)]reverbAllpass
+	tail      tail
+}
+
+// NewReverb returns a Reverb of s with a medium room: RoomSize and Damping 0.5, Width 1, Wet 1/3
+// and Dry 1.
+func NewReverb(s beep.Streamer, sr beep.SampleRate) *Reverb {
+	r := &Reverb{
+		Streamer: s,
+		RoomSize: 0.5,
+		Damping:  0.5,
+		Width:    1,
+		Wet:      1.0 / 3,
+		Dry:      1,
+	}
+	scale := func(delay int) int {
+		n := delay * int(sr) / 44100
+		if n < 1 {
+			n = 1
+		}
+		return n
+	}
+	longest := 0
+	for c := range r.combs {
+		for i, delay := range reverbCombDelays {
+			n := scale(delay + c*reverbStereoSpread)
+			r.combs[c][i].buf = make([]float64, n)
+			if n > longest {
+				longest = n
+			}
+		}
+		for i, delay := range reverbAllpassDelays {
+			r.allpasses[c][i].buf = make([]float64, scale(delay+c*reverbStereoSpread))
+		}
+	}
+	r.tail = tail{length: -1, window: longest}
+	return r
+}
+
+// Stream streams the wrapped Streamer with reverberation.
+func (r *Reverb) Stream(samples [][2]float64) (n int, ok bool) {
+	return r.tail.stream(r.Streamer, samples, r.process)
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (r *Reverb) Err() error {
+	return r.Streamer.Err()
+}
+
+func (r *Reverb) process(samples [][2]float64) {
+	feedback := r.RoomSize*reverbRoomScale + reverbRoomOffset
+	damp := r.Damping * reverbDampScale
+	wet1 := r.Wet * reverbWetScale * (r.Width/2 + 0.5)
+	wet2 := r.Wet * reverbWetScale * (1 - r.Width) / 2
+
+	for i, x := range samples {
+		in := (x[0] + x[1]) * reverbInputGain
+		var out [2]float64
+		for c := range out {
+			for j := range r.combs[c] {
+				out[c] += r.combs[c][j].process(in, feedback, damp)
+			}
+			for j := range r.allpasses[c] {
+				out[c] = r.allpasses[c][j].process(out[c])
+			}
+		}
+		samples[i][0] = out[0]*wet1 + out[1]*wet2 + x[0]*r.Dry
+		samples[i][1] = out[1]*wet1 + out[0]*wet2 + x[1]*r.Dry
+	}
+}
+
+// reverbComb is a comb filter with a lowpass filter in the feedback path.
+type reverbComb struct {
+	buf   []float64
+	pos   int
+	store float64
+}
+
+func (c *reverbComb) process(x, feedback, damp float64) float64 {
+	y := c.buf[c.pos]
+	c.store = y*(1-damp) + c.store*damp
+	c.buf[c.pos] = x + c.store*feedback
+	c.pos = (c.pos + 1) % len(c.buf)
+	return y
+}
+
+// reverbAllpass is the allpass filter of Freeverb, which diffuses the echoes of the comb filters.
+type reverbAllpass struct {
+	buf []float64
+	pos int
+}
+
+func (a *reverbAllpass) process(x float64) float64 {
+	b := a.buf[a.pos]
+	a.buf[a.pos] = x + b*0.5
+	a.pos = (a.pos + 1) % len(a.buf)
+	return b - x
+}
@@ -0,0 +1,113 @@
+package effects_test
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+	"github.com/brotholo/beep/wav"
+)
+
+func TestReverbTail(t *testing.T) {
+	const sr = beep.SampleRate(44100)
+	data := beeptest.Collect(beeptest.Impulse(1000, 0), 0)
+
+	r := effects.NewReverb(beeptest.Samples(data), sr)
+	r.Dry = 0
+	v := beeptest.Validate(r)
+	got := beeptest.Collect(v, 300)
+	if !v.Valid() {
+		t.Fatalf("reverb violates the Streamer contract: %v", v.Violations())
+	}
+	if len(got) <= len(data) {
+		t.Fatalf("reverb streamed %d samples, want a tail after %d", len(got), len(data))
+	}
+	if len(got) > sr.N(20e9) {
+		t.Fatalf("reverb tail is %d samples long, it doesn't decay", len(got))
+	}
+
+	var energy float64
+	for _, sample := range got[len(data):] {
+		energy += sample[0]*sample[0] + sample[1]*sample[1]
+	}
+	if energy == 0 {
+		t.Errorf("reverb tail is silent")
+	}
+}
+
+func TestConvolutionReverb(t *testing.T) {
+	const sr = beep.SampleRate(8000)
+	rnd := rand.New(rand.NewSource(1))
+
+	irBuf := beep.NewBuffer(beep.Format{SampleRate: sr, NumChannels: 2, Precision: 3})
+	irData := make([][2]float64, 1500) // three partitions
+	for i := range irData {
+		decay := math.Exp(-float64(i) / 300)
+		irData[i] = [2]float64{(rnd.Float64()*2 - 1) * decay, (rnd.Float64()*2 - 1) * decay}
+	}
+	irBuf.Append(beeptest.Samples(irData))
+	ir := beeptest.Collect(irBuf.Streamer(0, irBuf.Len()), 0)
+
+	data := make([][2]float64, 2000)
+	for i := range data {
+		data[i] = [2]float64{rnd.Float64()*2 - 1, rnd.Float64()*2 - 1}
+	}
+
+	const delay = effects.ConvolutionBlockSize
+	want := make([][2]float64, delay+len(data)+len(ir)-1)
+	for i, x := range data {
+		for j, h := range ir {
+			want[delay+i+j][0] += x[0] * h[0]
+			want[delay+i+j][1] += x[1] * h[1]
+		}
+	}
+
+	cr := effects.NewConvolutionReverb(beeptest.Samples(data), sr, irBuf)
+	v := beeptest.Validate(cr)
+	got := beeptest.Collect(v, 333)
+	if !v.Valid() {
+		t.Fatalf("convolution reverb violates the Streamer contract: %v", v.Violations())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("convolution reverb streamed %d samples, want %d", len(got), len(want))
+	}
+	beeptest.AssertSamples(t, got, want, 1e-9)
+}
+
+func TestDecodeImpulseResponse(t *testing.T) {
+	format := beep.Format{SampleRate: 22050, NumChannels: 1, Precision: 2}
+	f, err := os.Create(filepath.Join(t.TempDir(), "ir.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wav.Encode(f, beeptest.Impulse(100, 10), format); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	ir, err := effects.DecodeImpulseResponse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ir.Len() != 100 || ir.Format().SampleRate != format.SampleRate {
+		t.Errorf("decoded %d samples at %v, want 100 at %v", ir.Len(), ir.Format().SampleRate, format.SampleRate)
+	}
+
+	// an impulse convolved with an impulse response is the impulse response
+	const sr = beep.SampleRate(44100)
+	cr := effects.NewConvolutionReverb(beeptest.Impulse(1, 0), sr, ir)
+	got := beeptest.Collect(cr, 0)
+	want := beeptest.Collect(beep.Resample(4, format.SampleRate, sr, ir.Streamer(0, ir.Len())), 0)
+	if len(got) != effects.ConvolutionBlockSize+len(want) {
+		t.Fatalf("convolution reverb streamed %d samples, want %d", len(got), effects.ConvolutionBlockSize+len(want))
+	}
+	beeptest.AssertSamples(t, got[effects.ConvolutionBlockSize:], want, 1e-9)
+}
@@ -0,0 +1,145 @@
+package effects
+
+import (
+	"io"
+	"math"
+	"strings"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/internal/hdf5"
+	"github.com/pkg/errors"
+)
+
+// The range of the sampling rates of SOFA files, beyond which they are taken as corrupt.
+const (
+	minSOFARate = 8000
+	maxSOFARate = 384000
+)
+
+// AddSOFA decodes the HRIRs of a SOFA file (AES69) of the SimpleFreeFieldHRIR convention and adds
+// them to the set. The impulse responses are delayed by Data.Delay, if present, and resampled to
+// the sample rate of the set if needed. The source positions can be spherical, in degrees, or
+// cartesian.
+func (set *HRIRSet) AddSOFA(r io.ReaderAt) error {
+	f, err := hdf5.Open(r)
+	if err != nil {
+		return errors.Wrap(err, "effects")
+	}
+	root, err := f.Root()
+	if err != nil {
+		return errors.Wrap(err, "effects")
+	}
+	if v, err := root.Attr("DataType"); err == nil {
+		if s, err := v.Strings(); err == nil && len(s) == 1 && s[0] != "FIR" {
+			return errors.Errorf("effects: SOFA data type %s is not FIR", s[0])
+		}
+	}
+
+	ir, dims, err := sofaVariable(root, "Data.IR")
+	if err != nil {
+		return err
+	}
+	if len(dims) != 3 || dims[1] != 2 {
+		return errors.New("effects: SOFA impulse responses are not of two receivers")
+	}
+	m, n := dims[0], dims[2]
+	if n == 0 || len(ir) != m*2*n {
+		return errors.New("effects: invalid SOFA impulse responses")
+	}
+	rate, _, err := sofaVariable(root, "Data.SamplingRate")
+	if err != nil {
+		return err
+	}
+	if len(rate) == 0 || !(rate[0] >= minSOFARate && rate[0] <= maxSOFARate) {
+		return errors.New("effects: invalid SOFA sampling rate")
+	}
+	sr := beep.SampleRate(math.Round(rate[0]))
+
+	pos, posDims, err := sofaVariable(root, "SourcePosition")
+	if err != nil {
+		return err
+	}
+	if len(posDims) != 2 || posDims[1] != 3 || (posDims[0] != m && posDims[0] != 1) {
+		return errors.New("effects: invalid SOFA source positions")
+	}
+	cartesian := false
+	if src, err := root.Child("SourcePosition"); err == nil {
+		if v, err := src.Attr("Type"); err == nil {
+			if s, err := v.Strings(); err == nil && len(s) == 1 {
+				cartesian = strings.EqualFold(s[0], "cartesian")
+			}
+		}
+	}
+
+	delay, delayDims, err := sofaVariable(root, "Data.Delay")
+	if errors.Is(err, hdf5.ErrNotExist) {
+		delay, delayDims, err = []float64{0, 0}, []int{1, 2}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(delayDims) != 2 || delayDims[1] != 2 || (delayDims[0] != m && delayDims[0] != 1) {
+		return errors.New("effects: invalid SOFA delays")
+	}
+	for _, d := range delay {
+		// sound travels 34 m in 100 ms, a longer delay is surely corrupt
+		if !(d >= 0 && d <= float64(sr)/10) {
+			return errors.New("effects: invalid SOFA delays")
+		}
+	}
+
+	for i := 0; i < m; i++ {
+		p := pos[i%posDims[0]*3:][:3]
+		dir := Direction{Azimuth: p[0], Elevation: p[1]}
+		if cartesian {
+			dir.Azimuth = math.Atan2(p[1], p[0]) * 180 / math.Pi
+			dir.Elevation = math.Atan2(p[2], math.Hypot(p[0], p[1])) * 180 / math.Pi
+		}
+
+		var ears [2][]float64
+		for e := range ears {
+			d := int(math.Round(delay[i%delayDims[0]*2+e]))
+			ears[e] = make([]float64, d, d+n)
+			ears[e] = append(ears[e], ir[(i*2+e)*n:][:n]...)
+		}
+		length := len(ears[0])
+		if len(ears[1]) > length {
+			length = len(ears[1])
+		}
+		j := 0
+		s := beep.StreamerFunc(func(samples [][2]float64) (k int, ok bool) {
+			for k = range samples {
+				if j == length {
+					return k, k > 0
+				}
+				for e := range ears {
+					samples[k][e] = 0
+					if j < len(ears[e]) {
+						samples[k][e] = ears[e][j]
+					}
+				}
+				j++
+			}
+			return len(samples), true
+		})
+		set.Add(set.resample(s, sr, dir))
+	}
+	return nil
+}
+
+// sofaVariable returns the values and the dimensions of the variable of a SOFA file.
+func sofaVariable(root *hdf5.Object, name string) ([]float64, []int, error) {
+	o, err := root.Child(name)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "effects: SOFA variable %s", name)
+	}
+	v, err := o.Value()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "effects: SOFA variable %s", name)
+	}
+	x, err := v.Floats()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "effects: SOFA variable %s", name)
+	}
+	return x, v.Dims, nil
+}
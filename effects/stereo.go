@@ -0,0 +1,165 @@
+package effects
+
+import (
+	"math"
+	"time"
+
+	"github.com/brotholo/beep"
+)
+
+// MidSideEncode converts the stereo wrapped Streamer to mid/side: the left channel of the returned
+// Streamer is the mid signal (L+R)/2, the right channel is the side signal (L-R)/2.
+//
+// The returned Streamer propagates s's errors through Err.
+func MidSideEncode(s beep.Streamer) beep.Streamer {
+	return &midSide{s, true}
+}
+
+// MidSideDecode converts the mid/side wrapped Streamer, as produced by MidSideEncode, back to
+// stereo: L = M+S and R = M-S.
+//
+// The returned Streamer propagates s's errors through Err.
+func MidSideDecode(s beep.Streamer) beep.Streamer {
+	return &midSide{s, false}
+}
+
+type midSide struct {
+	Streamer beep.Streamer
+	encode   bool
+}
+
+func (ms *midSide) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = ms.Streamer.Stream(samples)
+	for i, x := range samples[:n] {
+		if ms.encode {
+			samples[i] = [2]float64{(x[0] + x[1]) / 2, (x[0] - x[1]) / 2}
+		} else {
+			samples[i] = [2]float64{x[0] + x[1], x[0] - x[1]}
+		}
+	}
+	return n, ok
+}
+
+func (ms *midSide) Err() error {
+	return ms.Streamer.Err()
+}
+
+// stereoWidthWindow is the time constant of the level measurements of StereoWidth.
+const stereoWidthWindow = 300 * time.Millisecond
+
+// StereoWidth changes the stereo width of the wrapped Streamer by scaling its side signal.
+//
+// Widening makes the channels less correlated, and once they get anti-correlated, the sound
+// gets hollow and parts of it cancel when the channels are summed to mono, for example on a phone
+// speaker. With Safe set, StereoWidth limits the widening so the correlation of the channels
+// stays at MinCorrelation or above. Correlation and MonoLoss measure the output, so the mono
+// compatibility can be checked, for example on a meter.
+//
+// Create a StereoWidth with NewStereoWidth. The parameters can be changed while streaming. If the
+// StereoWidth is playing through the speaker, lock the speaker before changing them.
+type StereoWidth struct {
+	Streamer beep.Streamer
+
+	// Width is the gain of the side signal: 0 is mono, 1 leaves the audio unchanged and values
+	// above 1 widen it.
+	Width float64
+
+	// Safe limits the widening to keep the correlation of the channels at MinCorrelation or
+	// above, from -1 to 1. Narrowing is never limited.
+	Safe           bool
+	MinCorrelation float64
+
+	encoded beep.Streamer // MidSideEncode of Streamer
+	decoded beep.Streamer // MidSideDecode of the scaled side signal
+	coef    float64       // per sample smoothing coefficient of the measurements
+	mid     float64       // input mid and side power
+	side    float64
+	gain    float64 // applied side gain
+	l, r, m float64 // output left, right and cross power
+}
+
+// NewStereoWidth returns a StereoWidth of s with the width, not Safe.
+func NewStereoWidth(s beep.Streamer, sr beep.SampleRate, width float64) *StereoWidth {
+	w := &StereoWidth{
+		Streamer: s,
+		Width:    width,
+		coef:     1 - math.Exp(-1/float64(sr.N(stereoWidthWindow))),
+		gain:     width,
+	}
+	// through a closure, so that changing the Streamer field takes effect
+	w.encoded = MidSideEncode(beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		return w.Streamer.Stream(samples)
+	}))
+	w.decoded = MidSideDecode(beep.StreamerFunc(w.scaleSide))
+	return w
+}
+
+// Stream streams the wrapped Streamer with the changed width. It encodes the audio to mid/side,
+// scales the side signal and decodes it back to stereo.
+func (w *StereoWidth) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = w.decoded.Stream(samples)
+	for _, y := range samples[:n] {
+		w.l += (y[0]*y[0] - w.l) * w.coef
+		w.r += (y[1]*y[1] - w.r) * w.coef
+		w.m += (y[0]*y[1] - w.m) * w.coef
+	}
+	return n, ok
+}
+
+// scaleSide streams the mid/side encoded Streamer with the side signal scaled by the width.
+func (w *StereoWidth) scaleSide(samples [][2]float64) (n int, ok bool) {
+	n, ok = w.encoded.Stream(samples)
+	for i, x := range samples[:n] {
+		mid, side := x[0], x[1]
+		w.mid += (mid*mid - w.mid) * w.coef
+		w.side += (side*side - w.side) * w.coef
+
+		target := w.Width
+		if w.Safe && target > 1 {
+			target = math.Max(1, math.Min(target, w.maxGain()))
+		}
+		w.gain += (target - w.gain) * w.coef
+		samples[i][1] = side * w.gain
+	}
+	return n, ok
+}
+
+// Err propagates the wrapped Streamer's errors.
+func (w *StereoWidth) Err() error {
+	return w.Streamer.Err()
+}
+
+// maxGain returns the largest side gain which keeps the correlation at MinCorrelation. With the
+// output mid power M and side power g²S, the correlation is (M-g²S)/(M+g²S).
+func (w *StereoWidth) maxGain() float64 {
+	c := math.Max(-1, math.Min(w.MinCorrelation, 1))
+	if w.side <= 0 || c >= 1 {
+		return 1
+	}
+	return math.Sqrt(w.mid * (1 - c) / (w.side * (1 + c)))
+}
+
+// Correlation returns the recent correlation of the output channels, from -1 (opposite) through 0
+// (unrelated) to +1 (mono). Negative values mean the audio loses level when summed to mono.
+//
+// If the StereoWidth is playing through the speaker, lock the speaker before calling Correlation.
+func (w *StereoWidth) Correlation() float64 {
+	if w.l <= 0 || w.r <= 0 {
+		return 1
+	}
+	return math.Max(-1, math.Min(w.m/math.Sqrt(w.l*w.r), 1))
+}
+
+// MonoLoss returns the recent change of the level in dB when the output is summed to mono,
+// relative to the level of the stereo channels. It's 0 for mono audio, -3 dB for unrelated
+// channels and falls rapidly as the channels get anti-correlated.
+//
+// If the StereoWidth is playing through the speaker, lock the speaker before calling MonoLoss.
+func (w *StereoWidth) MonoLoss() float64 {
+	stereo := (w.l + w.r) / 2
+	if stereo <= 0 {
+		return 0
+	}
+	mono := (w.l + w.r + 2*w.m) / 4
+	return 10 * math.Log10(math.Max(mono, 1e-12)/stereo)
+}
@@ -0,0 +1,92 @@
+package effects_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+)
+
+func TestPanner(t *testing.T) {
+	tests := []struct {
+		law    effects.PanLaw
+		center float64 // dB
+	}{
+		{effects.ConstantPower, -3.01},
+		{effects.Compromise, -4.52},
+		{effects.Linear, -6.02},
+	}
+	for _, tt := range tests {
+		for _, pan := range []float64{-1, 0, 0.5} {
+			p := &effects.Panner{Streamer: beeptest.Constant(1, [2]float64{1, 1}), Pan: pan, Law: tt.law}
+			got := beeptest.Collect(p, 0)[0]
+			switch pan {
+			case -1:
+				if got != [2]float64{1, 0} {
+					t.Errorf("law %v: fully left is %v", tt.law, got)
+				}
+			case 0:
+				if db := 20 * math.Log10(got[0]); math.Abs(db-tt.center) > 0.01 || math.Abs(got[0]-got[1]) > 1e-12 {
+					t.Errorf("law %v: center is %v (%.2f dB), want %.2f dB", tt.law, got, db, tt.center)
+				}
+			default:
+				if got[1] <= got[0] {
+					t.Errorf("law %v: %v panned to the right is %v", tt.law, pan, got)
+				}
+			}
+		}
+	}
+
+	// the constant power law keeps the power
+	p := &effects.Panner{Streamer: beeptest.Constant(1, [2]float64{1, 1}), Pan: 0.3}
+	got := beeptest.Collect(p, 0)[0]
+	if power := got[0]*got[0] + got[1]*got[1]; math.Abs(power-1) > 1e-12 {
+		t.Errorf("constant power law changes the power to %v", power)
+	}
+}
+
+func TestMidSide(t *testing.T) {
+	data := [][2]float64{{1, 0}, {0.5, 0.5}, {-0.25, 0.75}}
+	mid := beeptest.Collect(effects.MidSideEncode(beeptest.Samples(data)), 0)
+	beeptest.AssertSamples(t, mid, [][2]float64{{0.5, 0.5}, {0.5, 0}, {0.25, -0.5}}, 1e-12)
+	beeptest.AssertStreamer(t, effects.MidSideDecode(beeptest.Samples(mid)), data, 1e-12)
+}
+
+func TestStereoWidth(t *testing.T) {
+	const sr = beep.SampleRate(8000)
+	// quadrature channels are unrelated
+	data := make([][2]float64, sr.N(2e9))
+	for i := range data {
+		s, c := math.Sincos(2 * math.Pi * 100 * float64(i) / float64(sr))
+		data[i] = [2]float64{s / 2, c / 2}
+	}
+
+	mono := beeptest.Collect(effects.NewStereoWidth(beeptest.Samples(data), sr, 0), 0)
+	for i, s := range mono {
+		if s[0] != s[1] {
+			t.Fatalf("width 0: sample %d is %v, not mono", i, s)
+		}
+	}
+	beeptest.AssertStreamer(t, effects.NewStereoWidth(beeptest.Samples(data), sr, 1), data, 1e-12)
+
+	wide := effects.NewStereoWidth(beeptest.Samples(data), sr, 2)
+	beeptest.Collect(wide, 0)
+	if c := wide.Correlation(); math.Abs(c+0.6) > 0.05 {
+		t.Errorf("unsafe widening: correlation is %v, want -0.6", c)
+	}
+	if l := wide.MonoLoss(); l > -6 {
+		t.Errorf("unsafe widening: mono loss is %v dB, want a large loss", l)
+	}
+
+	safe := effects.NewStereoWidth(beeptest.Samples(data), sr, 2)
+	safe.Safe = true
+	beeptest.Collect(safe, 0)
+	if c := safe.Correlation(); c < -0.05 {
+		t.Errorf("safe widening: correlation is %v, want at least 0", c)
+	}
+	if l := safe.MonoLoss(); math.Abs(l+3) > 0.3 {
+		t.Errorf("safe widening: mono loss is %v dB, want -3 dB", l)
+	}
+}
@@ -0,0 +1,86 @@
+package effects
+
+import (
+	"math"
+
+	"github.com/brotholo/beep"
+)
+
+// tailThreshold is the level below which the tail of an effect counts as silence, about -100 dBFS.
+const tailThreshold = 1e-5
+
+// tail streams a Streamer through an effect and keeps the effect running on silence after the
+// Streamer drains, so that the effect can finish its echoes or reverberation. The tail ends after
+// length samples, or, if length is negative, once the output stayed below tailThreshold for
+// window samples.
+type tail struct {
+	length int
+	window int
+
+	quiet   int
+	drained bool
+	done    bool
+}
+
+// stream streams s into samples and processes them in place by process. After s is drained,
+// process is fed silence until the tail ends.
+func (t *tail) stream(s beep.Streamer, samples [][2]float64, process func([][2]float64)) (n int, ok bool) {
+	if t.done {
+		return 0, false
+	}
+	if len(samples) == 0 {
+		return 0, true
+	}
+
+	if !t.drained {
+		n, ok = s.Stream(samples)
+		if ok && n == len(samples) {
+			process(samples)
+			return n, true
+		}
+		t.drained = true
+		process(samples[:n])
+		if s.Err() != nil {
+			t.done = true
+			return n, n > 0
+		}
+	}
+	for n < len(samples) {
+		// process the tail in chunks which can't pass its end
+		m := len(samples) - n
+		if t.length >= 0 {
+			if t.length == 0 {
+				t.done = true
+				break
+			}
+			if m > t.length {
+				m = t.length
+			}
+			t.length -= m
+		} else if m > t.window-t.quiet {
+			m = t.window - t.quiet
+		}
+
+		chunk := samples[n : n+m]
+		for i := range chunk {
+			chunk[i] = [2]float64{}
+		}
+		process(chunk)
+		n += m
+
+		if t.length < 0 {
+			for _, sample := range chunk {
+				if math.Abs(sample[0]) < tailThreshold && math.Abs(sample[1]) < tailThreshold {
+					t.quiet++
+				} else {
+					t.quiet = 0
+				}
+			}
+			if t.quiet >= t.window {
+				t.done = true
+				break
+			}
+		}
+	}
+	return n, n > 0
+}
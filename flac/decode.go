@@ -6,6 +6,7 @@ import (
 
 	"github.com/brotholo/beep"
 	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/meta"
 	"github.com/pkg/errors"
 )
 
@@ -14,7 +15,21 @@ import (
 //
 // Do not close the supplied Reader, instead, use the Close method of the returned
 // StreamSeekCloser when you want to release the resources.
+//
+// Audio with more than two channels is mixed down to stereo according to the FLAC channel
+// assignment, see beep.Format.Downmix. Use DecodeDownmix to specify a different Downmix.
+//
+// The returned StreamSeekCloser also has a Tags method, which returns the Vorbis comments of the
+// stream as name/value pairs, see loudness.ReadReplayGain.
 func Decode(r io.Reader) (s beep.StreamSeekCloser, format beep.Format, err error) {
+	return DecodeDownmix(r, nil)
+}
+
+// DecodeDownmix is like Decode, but audio with more than two channels is mixed down to stereo
+// using the provided Downmix. The Downmix must have a row for each channel in the stream, otherwise
+// an error is returned. If downmix is nil, the Downmix of the returned format is used. Audio with
+// one or two channels isn't mixed, downmix is ignored for it.
+func DecodeDownmix(r io.Reader, downmix beep.Downmix) (s beep.StreamSeekCloser, format beep.Format, err error) {
 	d := decoder{r: r}
 	defer func() { // hacky way to always close r if an error occurred
 		if closer, ok := d.r.(io.Closer); ok {
@@ -26,10 +41,24 @@ func Decode(r io.Reader) (s beep.StreamSeekCloser, format beep.Format, err error
 
 	rs, seeker := r.(io.ReadSeeker)
 	if seeker {
-		d.stream, err = flac.NewSeek(rs)
+		// NewSeek doesn't keep the metadata blocks, read them first and start over
+		var start int64
+		start, err = rs.Seek(0, io.SeekCurrent)
+		if err == nil {
+			d.tags, err = readTags(rs)
+		}
+		if err == nil {
+			_, err = rs.Seek(start, io.SeekStart)
+		}
+		if err == nil {
+			d.stream, err = flac.NewSeek(rs)
+		}
 		d.seekEnabled = true
 	} else {
-		d.stream, err = flac.New(r)
+		d.stream, err = flac.Parse(r)
+		if err == nil {
+			d.tags = vorbisComments(d.stream.Blocks)
+		}
 	}
 
 	if err != nil {
@@ -40,16 +69,49 @@ func Decode(r io.Reader) (s beep.StreamSeekCloser, format beep.Format, err error
 		NumChannels: int(d.stream.Info.NChannels),
 		Precision:   int(d.stream.Info.BitsPerSample / 8),
 	}
+	if downmix == nil {
+		downmix = format.Downmix()
+	}
+	if format.NumChannels > 2 {
+		if len(downmix) != format.NumChannels {
+			return nil, beep.Format{}, fmt.Errorf("flac: downmix has %d rows, but the audio has %d channels", len(downmix), format.NumChannels)
+		}
+		d.downmix = downmix
+		d.frame = make([]float64, format.NumChannels)
+	}
 	return &d, format, nil
 }
 
+// readTags reads the Vorbis comments of the metadata blocks of r.
+func readTags(r io.Reader) ([][2]string, error) {
+	stream, err := flac.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return vorbisComments(stream.Blocks), nil
+}
+
+// vorbisComments returns the tags of the VorbisComment blocks.
+func vorbisComments(blocks []*meta.Block) [][2]string {
+	var tags [][2]string
+	for _, block := range blocks {
+		if c, ok := block.Body.(*meta.VorbisComment); ok {
+			tags = append(tags, c.Tags...)
+		}
+	}
+	return tags
+}
+
 type decoder struct {
 	r           io.Reader
 	stream      *flac.Stream
+	tags        [][2]string
 	buf         [][2]float64
 	pos         int
 	err         error
 	seekEnabled bool
+	downmix     beep.Downmix
+	frame       []float64
 }
 
 func (d *decoder) Stream(samples [][2]float64) (n int, ok bool) {
@@ -114,21 +176,28 @@ func (d *decoder) refill() error {
 			d.buf[i][0] = float64(int32(frame.Subframes[0].Samples[i])) * q
 			d.buf[i][1] = float64(int32(frame.Subframes[0].Samples[i])) * q
 		}
-	case bps == 8 && nchannels >= 2:
+	case bps == 8 && nchannels == 2:
 		for i := 0; i < n; i++ {
 			d.buf[i][0] = float64(int8(frame.Subframes[0].Samples[i])) * q
 			d.buf[i][1] = float64(int8(frame.Subframes[1].Samples[i])) * q
 		}
-	case bps == 16 && nchannels >= 2:
+	case bps == 16 && nchannels == 2:
 		for i := 0; i < n; i++ {
 			d.buf[i][0] = float64(int16(frame.Subframes[0].Samples[i])) * q
 			d.buf[i][1] = float64(int16(frame.Subframes[1].Samples[i])) * q
 		}
-	case bps == 24 && nchannels >= 2:
+	case bps == 24 && nchannels == 2:
 		for i := 0; i < n; i++ {
 			d.buf[i][0] = float64(frame.Subframes[0].Samples[i]) * q
 			d.buf[i][1] = float64(frame.Subframes[1].Samples[i]) * q
 		}
+	case (bps == 8 || bps == 16 || bps == 24) && nchannels > 2:
+		for i := 0; i < n; i++ {
+			for c := range d.frame {
+				d.frame[c] = float64(frame.Subframes[c].Samples[i]) * q
+			}
+			d.buf[i] = d.downmix.Apply(d.frame)
+		}
 	default:
 		panic(fmt.Errorf("support for %d bits-per-sample and %d channels combination not yet implemented", bps, nchannels))
 	}
@@ -139,6 +208,12 @@ func (d *decoder) Err() error {
 	return d.err
 }
 
+// Tags returns the Vorbis comments of the stream, such as TITLE or REPLAYGAIN_TRACK_GAIN, as
+// name/value pairs.
+func (d *decoder) Tags() [][2]string {
+	return d.tags
+}
+
 func (d *decoder) Len() int {
 	return int(d.stream.Info.NSamples)
 }
@@ -1,9 +1,8 @@
-module beep
+module github.com/brotholo/beep
 
 go 1.20
 
 require (
-	github.com/brotholo/beep v0.0.0-20230328163420-ca2a63dd7512
 	github.com/gdamore/tcell v1.4.0
 	github.com/hajimehoshi/go-mp3 v0.3.4
 	github.com/hajimehoshi/oto v1.0.1
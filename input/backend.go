@@ -0,0 +1,153 @@
+package input
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/internal/pacer"
+	"github.com/brotholo/beep/speaker"
+	"github.com/brotholo/beep/wav"
+	"github.com/pkg/errors"
+)
+
+// Backend is an audio source the input captures audio from.
+//
+// The input calls Open once, then Read repeatedly from a single goroutine and finally Close.
+type Backend interface {
+	// Open prepares the Backend for capturing audio at the provided sample rate, read in chunks of
+	// bufferSize samples.
+	Open(sampleRate beep.SampleRate, bufferSize int) error
+
+	// Read fills samples with the next captured audio and returns the number of samples read.
+	// Read blocks until the audio is captured, which paces the input. When the source ends, Read
+	// returns io.EOF.
+	Read(samples [][2]float64) (n int, err error)
+
+	// Close stops the capture and releases all resources held by the Backend. Close may be called
+	// while Read is blocked and makes it return.
+	Close() error
+}
+
+// File returns a Backend which captures the audio of a WAVE file at path, in real time, as if it
+// was played into a microphone. The audio is resampled to the sample rate of the input if needed.
+// The capture ends at the end of the file.
+func File(path string) Backend {
+	return &fileBackend{path: path}
+}
+
+type fileBackend struct {
+	path  string
+	pacer pacer.Pacer
+
+	mu     sync.Mutex // Close may be called while Read is blocked
+	s      beep.StreamSeekCloser
+	r      beep.Streamer
+	closed bool
+}
+
+func (fb *fileBackend) Open(sampleRate beep.SampleRate, bufferSize int) error {
+	f, err := os.Open(fb.path)
+	if err != nil {
+		return errors.Wrap(err, "input: file backend")
+	}
+	s, format, err := wav.Decode(f)
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "input: file backend")
+	}
+	var r beep.Streamer = s
+	if format.SampleRate != sampleRate {
+		r = beep.Resample(4, format.SampleRate, sampleRate, s)
+	}
+	fb.mu.Lock()
+	fb.s, fb.r, fb.closed = s, r, false
+	fb.mu.Unlock()
+	fb.pacer = pacer.New(sampleRate, bufferSize)
+	return nil
+}
+
+func (fb *fileBackend) Read(samples [][2]float64) (n int, err error) {
+	fb.mu.Lock()
+	if fb.closed {
+		fb.mu.Unlock()
+		return 0, io.EOF
+	}
+	n, ok := fb.r.Stream(samples)
+	err = fb.r.Err()
+	fb.mu.Unlock()
+
+	fb.pacer.Wait(n)
+	if !ok || n < len(samples) {
+		if err != nil {
+			return n, errors.Wrap(err, "input: file backend")
+		}
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (fb *fileBackend) Close() error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	if fb.s == nil || fb.closed {
+		return nil
+	}
+	fb.closed = true
+	// the decoder closes the file
+	if err := fb.s.Close(); err != nil {
+		return errors.Wrap(err, "input: file backend")
+	}
+	return nil
+}
+
+// Loopback returns a Backend which captures the audio played by sp, recorded by a speaker.Tap. If
+// sp is nil, it captures the default speaker of the speaker package. The audio is resampled to the
+// sample rate of the input if needed. The capture ends when the speaker is closed.
+//
+// Loopback makes it possible to develop and test capture pipelines without a microphone.
+func Loopback(sp *speaker.Speaker) Backend {
+	return &loopbackBackend{sp: sp}
+}
+
+type loopbackBackend struct {
+	sp  *speaker.Speaker
+	tap *speaker.Tap
+	r   beep.Streamer
+}
+
+func (lb *loopbackBackend) Open(sampleRate beep.SampleRate, bufferSize int) error {
+	if lb.sp != nil {
+		lb.tap = lb.sp.NewTap(loopbackQueueLen)
+	} else {
+		lb.tap = speaker.NewTap(loopbackQueueLen)
+	}
+	if lb.tap.SampleRate() == 0 {
+		lb.tap.Close()
+		return errors.New("input: loopback backend: the speaker is not initialized")
+	}
+	lb.r = lb.tap
+	if lb.tap.SampleRate() != sampleRate {
+		lb.r = beep.Resample(4, lb.tap.SampleRate(), sampleRate, lb.tap)
+	}
+	return nil
+}
+
+// loopbackQueueLen is the number of speaker blocks the loopback backend queues.
+const loopbackQueueLen = 16
+
+func (lb *loopbackBackend) Read(samples [][2]float64) (n int, err error) {
+	n, ok := lb.r.Stream(samples)
+	if !ok || n < len(samples) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (lb *loopbackBackend) Close() error {
+	if lb.tap != nil {
+		lb.tap.Close()
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+// Package input implements capturing of audio from microphones or other sources, see Backend. It
+// mirrors the speaker package: the captured audio is streamed by a beep.Streamer.
+//
+// The package level functions control a default Input. Use New to create additional independent
+// Inputs.
+//
+//	input.Init(sr, sr.N(time.Second/10), input.WithBackend(input.Loopback(nil)))
+//	defer input.Close()
+//	wav.Encode(f, beep.Take(sr.N(5*time.Second), input.Streamer()), format)
+package input
+
+import (
+	"io"
+	"sync"
+
+	"github.com/brotholo/beep"
+	"github.com/pkg/errors"
+)
+
+// Input captures audio from a Backend and streams it.
+//
+// Stream may be called from one goroutine at a time, the other methods of Input are safe for
+// concurrent use.
+type Input struct {
+	ctl        sync.Mutex // serializes starting and stopping
+	sampleRate beep.SampleRate
+	backend    Backend
+	done       chan struct{} // closed to stop the capture goroutine
+	stopped    chan struct{} // closed when the capture goroutine returns
+
+	mu  sync.Mutex
+	cur *capture
+}
+
+// capture is the state of a single capture, from starting the Input until it's stopped.
+type capture struct {
+	queue     chan [][2]float64
+	free      chan [][2]float64 // consumed blocks for reuse
+	queueDone chan struct{}     // closed when no more blocks will be queued
+
+	// guarded by Input.mu
+	err       error
+	overruns  int
+	streamErr error // err, once the consumer drained the capture
+
+	// accessed only by the consumer
+	full    [][2]float64 // block being consumed
+	block   [][2]float64 // rest of full
+	drained bool
+}
+
+// New creates an Input and starts capturing audio at the provided sample rate from the Backend
+// specified by WithBackend.
+//
+// The bufferSize argument specifies the number of samples captured at once. Bigger bufferSize
+// means lower CPU usage, lower bufferSize means less delay.
+func New(sampleRate beep.SampleRate, bufferSize int, opts ...Option) (*Input, error) {
+	in := &Input{}
+	if err := in.start(sampleRate, bufferSize, newConfig(opts)); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// start opens the backend and starts the capture goroutine. in.ctl must be held or in must not be
+// shared yet.
+func (in *Input) start(sampleRate beep.SampleRate, bufferSize int, c config) error {
+	if c.backend == nil {
+		return errors.New("input: no backend, see WithBackend")
+	}
+	if c.queueLen < 1 {
+		c.queueLen = 1
+	}
+	if err := c.backend.Open(sampleRate, bufferSize); err != nil {
+		return err
+	}
+	in.sampleRate = sampleRate
+	in.backend = c.backend
+	in.done = make(chan struct{})
+	in.stopped = make(chan struct{})
+
+	cp := &capture{
+		queue:     make(chan [][2]float64, c.queueLen),
+		free:      make(chan [][2]float64, c.queueLen),
+		queueDone: make(chan struct{}),
+	}
+	in.mu.Lock()
+	in.cur = cp
+	in.mu.Unlock()
+
+	go in.run(in.backend, bufferSize, cp, in.done, in.stopped)
+	return nil
+}
+
+// stop stops the capture goroutine and closes the backend. in.ctl must be held.
+func (in *Input) stop() error {
+	if in.backend == nil {
+		return nil
+	}
+	close(in.done)
+	err := in.backend.Close() // unblocks Read
+	<-in.stopped
+	in.backend = nil
+	return err
+}
+
+// run reads blocks from the backend and queues them until done is closed or the backend ends.
+func (in *Input) run(backend Backend, bufferSize int, cp *capture, done, stopped chan struct{}) {
+	defer close(stopped)
+	defer close(cp.queueDone)
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		var block [][2]float64
+		select {
+		case block = <-cp.free:
+		default:
+		}
+		if cap(block) < bufferSize {
+			block = make([][2]float64, bufferSize)
+		}
+		n, err := backend.Read(block[:bufferSize])
+		if n > 0 {
+			select {
+			case cp.queue <- block[:n]:
+			default:
+				in.mu.Lock()
+				cp.overruns++
+				in.mu.Unlock()
+			}
+		}
+		if err != nil {
+			select {
+			case <-done:
+				// errors caused by closing the backend are expected
+			default:
+				if err != io.EOF {
+					in.mu.Lock()
+					cp.err = err
+					in.mu.Unlock()
+				}
+			}
+			return
+		}
+	}
+}
+
+// Stream streams the captured audio. It blocks until enough audio is captured. When the capture
+// ends, because the Input is closed or the source ended, it streams the remaining queued audio and
+// drains.
+func (in *Input) Stream(samples [][2]float64) (n int, ok bool) {
+	in.mu.Lock()
+	cp := in.cur
+	in.mu.Unlock()
+	if cp == nil || cp.drained {
+		return 0, false
+	}
+
+	for n < len(samples) {
+		if len(cp.block) == 0 && !cp.next() {
+			break
+		}
+		c := copy(samples[n:], cp.block)
+		cp.block = cp.block[c:]
+		n += c
+	}
+	if n < len(samples) {
+		cp.drained = true
+		in.mu.Lock()
+		cp.streamErr = cp.err
+		in.mu.Unlock()
+	}
+	return n, n > 0 || len(samples) == 0
+}
+
+// next waits for the next captured block. It reports false if the capture ended and no more blocks
+// are queued.
+func (cp *capture) next() bool {
+	if cp.full != nil {
+		select {
+		case cp.free <- cp.full:
+		default:
+		}
+		cp.full = nil
+	}
+	select {
+	case cp.full = <-cp.queue:
+	case <-cp.queueDone:
+		// the capture ended, but blocks queued before are still to be streamed
+		select {
+		case cp.full = <-cp.queue:
+		default:
+			return false
+		}
+	}
+	cp.block = cp.full
+	return true
+}
+
+// Err returns the error which ended the capture, if any. The error is returned once all audio
+// captured before it has been streamed.
+func (in *Input) Err() error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.cur == nil {
+		return nil
+	}
+	return in.cur.streamErr
+}
+
+// Overruns returns the number of captured blocks dropped because the consumer fell behind.
+func (in *Input) Overruns() int {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.cur == nil {
+		return 0
+	}
+	return in.cur.overruns
+}
+
+// SampleRate returns the sample rate of the captured audio. It returns 0 if the Input is closed.
+func (in *Input) SampleRate() beep.SampleRate {
+	in.ctl.Lock()
+	defer in.ctl.Unlock()
+	if in.backend == nil {
+		return 0
+	}
+	return in.sampleRate
+}
+
+// Close stops the capture and closes the Backend. The audio captured up to that moment can still
+// be streamed.
+func (in *Input) Close() error {
+	in.ctl.Lock()
+	defer in.ctl.Unlock()
+	return in.stop()
+}
+
+// std is the default Input controlled by the package level functions.
+var std Input
+
+// Init initializes audio capture at the provided sample rate from the Backend specified by
+// WithBackend. Must be called before using this package. Calling Init again stops the previous
+// capture and drops the audio captured but not streamed yet.
+//
+// The bufferSize argument specifies the number of samples captured at once. Bigger bufferSize
+// means lower CPU usage, lower bufferSize means less delay.
+func Init(sampleRate beep.SampleRate, bufferSize int, opts ...Option) error {
+	std.ctl.Lock()
+	defer std.ctl.Unlock()
+	std.stop()
+	return std.start(sampleRate, bufferSize, newConfig(opts))
+}
+
+// Close stops the capture and closes the Backend.
+func Close() {
+	std.Close()
+}
+
+// Stream streams the captured audio, see Input.Stream.
+func Stream(samples [][2]float64) (n int, ok bool) {
+	return std.Stream(samples)
+}
+
+// Err returns the error which ended the capture, if any.
+func Err() error {
+	return std.Err()
+}
+
+// Streamer returns a Streamer of the captured audio, which can be passed to anything that takes
+// a beep.Streamer, such as wav.Encode.
+func Streamer() beep.Streamer {
+	return &std
+}
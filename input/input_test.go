@@ -0,0 +1,148 @@
+package input_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/input"
+	"github.com/brotholo/beep/speaker"
+	"github.com/brotholo/beep/wav"
+)
+
+const sampleRate = beep.SampleRate(8000)
+
+func writeWAV(t *testing.T, s beep.Streamer, sr beep.SampleRate) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "in.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := wav.Encode(f, s, beep.Format{SampleRate: sr, NumChannels: 2, Precision: 2}); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileBackend(t *testing.T) {
+	path := writeWAV(t, beeptest.Constant(800, [2]float64{0.5, -0.5}), sampleRate)
+	if err := input.Init(sampleRate, 80, input.WithBackend(input.File(path))); err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+
+	start := time.Now()
+	v := beeptest.Validate(input.Streamer())
+	got := beeptest.Collect(v, 100)
+	if elapsed := time.Since(start); elapsed < sampleRate.D(800)/2 {
+		t.Errorf("capture of %v took only %v, the backend is not paced", sampleRate.D(800), elapsed)
+	}
+	if !v.Valid() {
+		t.Errorf("input violates the Streamer contract: %v", v.Violations())
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, _, err := wav.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := beeptest.Diff(got, beeptest.Collect(want, 0), 0); err != nil {
+		t.Error(err)
+	}
+	if input.Err() != nil {
+		t.Errorf("unexpected error: %v", input.Err())
+	}
+}
+
+func TestFileBackendResample(t *testing.T) {
+	path := writeWAV(t, beeptest.Constant(400, [2]float64{0.5, 0.5}), 4000)
+	in, err := input.New(sampleRate, 80, input.WithBackend(input.File(path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if got := len(beeptest.Collect(in, 0)); math.Abs(float64(got-800)) > 8 {
+		t.Errorf("captured %d samples of a 4000Hz file at 8000Hz, want about 800", got)
+	}
+}
+
+func TestFileBackendClose(t *testing.T) {
+	path := writeWAV(t, beeptest.Constant(800, [2]float64{0.5, 0.5}), sampleRate)
+	in, err := input.New(sampleRate, 80, input.WithBackend(input.File(path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// close in the middle of the capture
+	time.Sleep(sampleRate.D(400))
+	if err := in.Close(); err != nil {
+		t.Errorf("closing the file backend failed: %v", err)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	sp, err := speaker.New(sampleRate, 80, speaker.WithBackend(speaker.Null()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+	in, err := input.New(sampleRate, 80, input.WithBackend(input.Loopback(sp)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	sp.Play(beeptest.Constant(400, [2]float64{0.5, 0.5}))
+	captured := make(chan int)
+	go func() {
+		var played int
+		samples := make([][2]float64, 80)
+		for played < 400 {
+			n, ok := in.Stream(samples)
+			if !ok {
+				break
+			}
+			for _, sample := range samples[:n] {
+				if math.Abs(sample[0]-0.5) < 0.01 {
+					played++
+				}
+			}
+		}
+		captured <- played
+	}()
+	select {
+	case played := <-captured:
+		if played != 400 {
+			t.Errorf("captured %d played samples, want 400", played)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("played audio was not captured")
+	}
+
+	sp.Close()
+	beeptest.Collect(in, 0) // the capture ends with the speaker
+}
+
+func TestOverruns(t *testing.T) {
+	path := writeWAV(t, beeptest.Constant(800, [2]float64{}), sampleRate)
+	in, err := input.New(sampleRate, 80, input.WithBackend(input.File(path)), input.WithQueueLen(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	time.Sleep(sampleRate.D(800) + 20*time.Millisecond)
+	if in.Overruns() == 0 {
+		t.Error("no overruns while nobody consumed the captured audio")
+	}
+	if got := len(beeptest.Collect(in, 0)); got != 160 {
+		t.Errorf("streamed %d queued samples, want 160", got)
+	}
+}
@@ -0,0 +1,34 @@
+package input
+
+// Option configures the input in Init.
+type Option func(*config)
+
+type config struct {
+	backend  Backend
+	queueLen int
+}
+
+func newConfig(opts []Option) config {
+	c := config{queueLen: 16}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithBackend makes the input capture audio from the provided Backend.
+//
+//	input.Init(sr, sr.N(time.Second/10), input.WithBackend(input.File("test.wav")))
+func WithBackend(b Backend) Option {
+	return func(c *config) {
+		c.backend = b
+	}
+}
+
+// WithQueueLen sets the number of captured blocks of bufferSize samples the input queues for the
+// consumer. When the queue is full, new blocks are dropped, see Input.Overruns. The default is 16.
+func WithQueueLen(n int) Option {
+	return func(c *config) {
+		c.queueLen = n
+	}
+}
@@ -0,0 +1,82 @@
+// Package fft implements the fast Fourier transform of power of two sizes.
+package fft
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// Plan holds the precomputed tables for transforms of a single size.
+type Plan struct {
+	n       int
+	twiddle []complex128
+	rev     []int
+}
+
+// NewPlan returns a Plan for transforms of size n. It panics if n is not a power of two.
+func NewPlan(n int) *Plan {
+	if n < 1 || n&(n-1) != 0 {
+		panic(fmt.Errorf("fft: size %d is not a power of two", n))
+	}
+	p := &Plan{
+		n:       n,
+		twiddle: make([]complex128, n/2),
+		rev:     make([]int, n),
+	}
+	for i := range p.twiddle {
+		s, c := math.Sincos(-2 * math.Pi * float64(i) / float64(n))
+		p.twiddle[i] = complex(c, s)
+	}
+	shift := 64 - bits.Len(uint(n-1))
+	for i := range p.rev {
+		if n > 1 {
+			p.rev[i] = int(bits.Reverse64(uint64(i)) >> uint(shift))
+		}
+	}
+	return p
+}
+
+// Len returns the size of the transforms of the Plan.
+func (p *Plan) Len() int {
+	return p.n
+}
+
+// Forward replaces x with its discrete Fourier transform. The length of x must be p.Len().
+func (p *Plan) Forward(x []complex128) {
+	p.transform(x, false)
+}
+
+// Inverse replaces x with its inverse discrete Fourier transform, including the 1/n scaling. The
+// length of x must be p.Len().
+func (p *Plan) Inverse(x []complex128) {
+	p.transform(x, true)
+	scale := complex(1/float64(p.n), 0)
+	for i := range x {
+		x[i] *= scale
+	}
+}
+
+func (p *Plan) transform(x []complex128, inverse bool) {
+	if len(x) != p.n {
+		panic(fmt.Errorf("fft: length %d doesn't match the plan size %d", len(x), p.n))
+	}
+	for i, j := range p.rev {
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= p.n; size <<= 1 {
+		half, step := size/2, p.n/size
+		for start := 0; start < p.n; start += size {
+			for k := 0; k < half; k++ {
+				w := p.twiddle[k*step]
+				if inverse {
+					w = complex(real(w), -imag(w))
+				}
+				a, b := x[start+k], x[start+k+half]*w
+				x[start+k], x[start+k+half] = a+b, a-b
+			}
+		}
+	}
+}
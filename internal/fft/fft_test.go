@@ -0,0 +1,43 @@
+package fft_test
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+
+	"github.com/brotholo/beep/internal/fft"
+)
+
+func TestForwardInverse(t *testing.T) {
+	for _, n := range []int{1, 2, 8, 64, 1024} {
+		x := make([]complex128, n)
+		for i := range x {
+			x[i] = complex(rand.Float64()*2-1, rand.Float64()*2-1)
+		}
+
+		// compare with the definition of the DFT
+		want := make([]complex128, n)
+		for k := range want {
+			for j, v := range x {
+				want[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(j*k)/float64(n)))
+			}
+		}
+
+		p := fft.NewPlan(n)
+		got := append([]complex128(nil), x...)
+		p.Forward(got)
+		for k := range got {
+			if cmplx.Abs(got[k]-want[k]) > 1e-9 {
+				t.Fatalf("n=%d: bin %d is %v, want %v", n, k, got[k], want[k])
+			}
+		}
+
+		p.Inverse(got)
+		for i := range got {
+			if cmplx.Abs(got[i]-x[i]) > 1e-12 {
+				t.Fatalf("n=%d: inverse sample %d is %v, want %v", n, i, got[i], x[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package hdf5
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// btree1 calls fn with the keys and the children of the leaves of the version 1 B-tree at addr,
+// of keys of keySize bytes, until fn returns false.
+func (f *File) btree1(addr uint64, keySize int, fn func(key []byte, child uint64) (bool, error)) error {
+	_, err := f.btree1Node(addr, -1, keySize, fn)
+	return err
+}
+
+// btree1Node walks the node at addr, which is at the level, or at any level if it's the root (-1).
+func (f *File) btree1Node(addr uint64, want, keySize int, fn func(key []byte, child uint64) (bool, error)) (bool, error) {
+	c, err := f.read(addr, 8)
+	if err != nil {
+		return false, err
+	}
+	if err := c.signature("TREE"); err != nil {
+		return false, err
+	}
+	c.skip(1) // node type
+	level := int(c.u8())
+	entries := int(c.u16())
+	if want >= 0 && level != want {
+		// the levels decrease down to the leaves, which ends the recursion of corrupt trees
+		return false, errors.New("hdf5: corrupt B-tree")
+	}
+	c, err = f.read(addr+8+2*uint64(f.offSize), entries*(keySize+f.offSize)+keySize)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < entries; i++ {
+		key := c.bytes(keySize)
+		child := c.addr()
+		if c.err != nil {
+			return false, c.err
+		}
+		more := true
+		if level > 0 {
+			more, err = f.btree1Node(child, level-1, keySize, fn)
+		} else {
+			more, err = fn(key, child)
+		}
+		if err != nil || !more {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// symbolTable calls fn with the name and the object header address of the members of the group of
+// the symbol table with the B-tree and the local heap at the addresses, until fn returns false.
+func (f *File) symbolTable(btree, heap uint64, fn func(name string, addr uint64) bool) error {
+	names, err := f.localHeap(heap)
+	if err != nil {
+		return err
+	}
+	entrySize := 2*f.offSize + 24
+	return f.btree1(btree, f.lenSize, func(_ []byte, node uint64) (bool, error) {
+		c, err := f.read(node, 8)
+		if err != nil {
+			return false, err
+		}
+		if err := c.signature("SNOD"); err != nil {
+			return false, err
+		}
+		c.skip(2) // version, reserved
+		n := int(c.u16())
+		c, err = f.read(node+8, n*entrySize)
+		if err != nil {
+			return false, err
+		}
+		for i := 0; i < n; i++ {
+			off := c.uint(f.offSize)
+			addr := c.addr()
+			c.skip(24) // cache type, reserved, scratch-pad
+			if c.err != nil {
+				return false, c.err
+			}
+			if off >= uint64(len(names)) {
+				return false, errors.New("hdf5: corrupt symbol table")
+			}
+			name := strings.SplitN(string(names[off:]), "\x00", 2)[0]
+			if !fn(name, addr) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// btree2 calls fn with the records of the version 2 B-tree at addr, until fn returns false.
+func (f *File) btree2(addr uint64, fn func(rec []byte) (bool, error)) error {
+	c, err := f.read(addr, 16+f.offSize+2+f.lenSize)
+	if err != nil {
+		return err
+	}
+	if err := c.signature("BTHD"); err != nil {
+		return err
+	}
+	c.skip(2) // version, type
+	t := &btree2{f: f, nodeSize: int(c.u32()), recSize: int(c.u16())}
+	depth := int(c.u16())
+	c.skip(2) // split and merge percents
+	root := c.addr()
+	n := int(c.u16())
+	if c.err != nil {
+		return c.err
+	}
+	if root == undefined {
+		return nil
+	}
+	if t.recSize == 0 || t.nodeSize <= 10 {
+		return errors.New("hdf5: corrupt B-tree")
+	}
+
+	// the sizes of the numbers of records in the child pointers depend on the maximum numbers of
+	// records at each depth
+	const prefix = 10 // signature, version, type and checksum
+	maxRec := uint64((t.nodeSize - prefix) / t.recSize)
+	t.nrecSize = encSize(maxRec)
+	t.cumSize = make([]int, depth+1)
+	cum := maxRec
+	for d := 1; d <= depth; d++ {
+		ptr := f.offSize + t.nrecSize + t.cumSize[d-1]
+		if t.nodeSize-prefix-ptr < 0 {
+			return errors.New("hdf5: corrupt B-tree")
+		}
+		max := uint64((t.nodeSize - prefix - ptr) / (t.recSize + ptr))
+		cum = (max+1)*cum + max
+		t.cumSize[d] = encSize(cum)
+	}
+	_, err = t.node(root, n, depth, fn)
+	return err
+}
+
+type btree2 struct {
+	f        *File
+	nodeSize int
+	recSize  int
+	nrecSize int
+	cumSize  []int
+}
+
+func (t *btree2) node(addr uint64, n, depth int, fn func(rec []byte) (bool, error)) (bool, error) {
+	c, err := t.f.read(addr, t.nodeSize)
+	if err != nil {
+		return false, err
+	}
+	sig := "BTLF"
+	if depth > 0 {
+		sig = "BTIN"
+	}
+	if err := c.signature(sig); err != nil {
+		return false, err
+	}
+	c.skip(2) // version, type
+	if n < 0 || n > c.left()/t.recSize {
+		return false, errors.New("hdf5: corrupt B-tree")
+	}
+	recs := make([][]byte, n)
+	for i := range recs {
+		recs[i] = c.bytes(t.recSize)
+	}
+	if c.err != nil {
+		return false, c.err
+	}
+	if depth == 0 {
+		for _, rec := range recs {
+			if more, err := fn(rec); err != nil || !more {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	for i := 0; i <= n; i++ {
+		child := c.addr()
+		nc := int(c.uint(t.nrecSize))
+		if depth > 1 {
+			c.skip(t.cumSize[depth-1])
+		}
+		if c.err != nil {
+			return false, c.err
+		}
+		if more, err := t.node(child, nc, depth-1, fn); err != nil || !more {
+			return false, err
+		}
+		if i < n {
+			if more, err := fn(recs[i]); err != nil || !more {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,467 @@
+package hdf5
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// layout classes
+const (
+	layoutCompact    = 0
+	layoutContiguous = 1
+	layoutChunked    = 2
+)
+
+// chunk index types
+const (
+	indexBTree1     = 0 // version 3 layouts
+	indexSingle     = 1
+	indexImplicit   = 2
+	indexFixedArray = 3
+)
+
+type layout struct {
+	class int
+	data  []byte // compact data
+	addr  uint64
+	chunk []int // chunk dimensions
+	index int
+
+	// single filtered chunk
+	filteredSize uint64
+	filterMask   uint32
+}
+
+type filter struct {
+	id     int
+	values []uint32
+}
+
+// Value returns the value of the dataset.
+func (o *Object) Value() (*Value, error) {
+	dtMsg, spaceMsg, layoutMsg := o.message(msgDatatype), o.message(msgDataspace), o.message(msgLayout)
+	if dtMsg == nil || spaceMsg == nil || layoutMsg == nil {
+		return nil, errors.New("hdf5: object isn't a dataset")
+	}
+	dt, err := o.f.datatype(dtMsg.b)
+	if err != nil {
+		return nil, err
+	}
+	dims, err := dataspace(spaceMsg.b, o.f)
+	if err != nil {
+		return nil, err
+	}
+	l, err := o.layout(layoutMsg, len(dims))
+	if err != nil {
+		return nil, err
+	}
+	var filters []filter
+	if c := o.message(msgFilters); c != nil {
+		if filters, err = parseFilters(c); err != nil {
+			return nil, err
+		}
+	}
+
+	v := &Value{Dims: dims, f: o.f, dt: dt}
+	size, err := o.f.dataSize(dims, dt.size)
+	if err != nil {
+		return nil, err
+	}
+	switch l.class {
+	case layoutCompact:
+		if len(l.data) < size {
+			return nil, errors.New("hdf5: corrupt compact dataset")
+		}
+		v.data = l.data[:size]
+	case layoutContiguous:
+		if l.addr == undefined {
+			// never written
+			v.data = make([]byte, size)
+			break
+		}
+		c, err := o.f.read(l.addr, size)
+		if err != nil {
+			return nil, err
+		}
+		v.data = c.b
+	case layoutChunked:
+		if v.data, err = o.f.chunked(l, filters, dims, dt.size); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// layout decodes a layout message of a dataset of the rank.
+func (o *Object) layout(c *cursor, rank int) (*layout, error) {
+	l := &layout{}
+	version := c.u8()
+	switch version {
+	case 1, 2:
+		n := int(c.u8())
+		l.class = int(c.u8())
+		c.skip(5)
+		if l.class != layoutCompact {
+			l.addr = c.addr()
+		}
+		l.chunk = make([]int, n)
+		for i := range l.chunk {
+			l.chunk[i] = int(c.u32())
+		}
+		if l.class == layoutCompact {
+			l.data = c.bytes(int(c.u32()))
+		}
+	case 3, 4:
+		l.class = int(c.u8())
+		switch l.class {
+		case layoutCompact:
+			l.data = c.bytes(int(c.u16()))
+		case layoutContiguous:
+			l.addr = c.addr()
+		case layoutChunked:
+			encLen := 4
+			var flags uint8
+			if version == 4 {
+				flags = c.u8()
+			}
+			n := int(c.u8())
+			if version == 3 {
+				l.addr = c.addr()
+			} else {
+				encLen = int(c.u8())
+			}
+			l.chunk = make([]int, n)
+			for i := range l.chunk {
+				l.chunk[i] = int(c.uint(encLen))
+			}
+			if version == 4 {
+				if err := l.index4(c, flags); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, errors.Errorf("hdf5: unsupported layout class %d", l.class)
+		}
+	default:
+		return nil, errors.Errorf("hdf5: unsupported layout message version %d", version)
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	if l.class == layoutChunked {
+		// the last dimension of the chunks is the size of the elements
+		if len(l.chunk) != rank+1 {
+			return nil, errors.New("hdf5: corrupt chunk dimensions")
+		}
+		l.chunk = l.chunk[:rank]
+		for _, d := range l.chunk {
+			if d <= 0 {
+				return nil, errors.New("hdf5: corrupt chunk dimensions")
+			}
+		}
+	}
+	return l, nil
+}
+
+// index4 decodes the chunk index of a version 4 layout message with the flags.
+func (l *layout) index4(c *cursor, flags uint8) error {
+	l.index = int(c.u8())
+	switch l.index {
+	case indexSingle:
+		if flags&2 != 0 {
+			l.filteredSize = c.length()
+			l.filterMask = c.u32()
+		}
+	case indexImplicit:
+	case indexFixedArray:
+		c.skip(1) // page bits
+	default:
+		return errors.Errorf("hdf5: unsupported chunk index type %d", l.index)
+	}
+	l.addr = c.addr()
+	return nil
+}
+
+// parseFilters decodes a filter pipeline message.
+func parseFilters(c *cursor) ([]filter, error) {
+	version := c.u8()
+	if version != 1 && version != 2 {
+		return nil, errors.Errorf("hdf5: unsupported filter pipeline message version %d", version)
+	}
+	filters := make([]filter, c.u8())
+	if version == 1 {
+		c.skip(6)
+	}
+	for i := range filters {
+		fl := &filters[i]
+		fl.id = int(c.u16())
+		nameLen := 0
+		if version == 1 || fl.id >= 256 {
+			nameLen = int(c.u16())
+		}
+		c.skip(2) // flags
+		fl.values = make([]uint32, c.u16())
+		if version == 1 {
+			nameLen = (nameLen + 7) &^ 7
+		}
+		c.skip(nameLen)
+		for j := range fl.values {
+			fl.values[j] = c.u32()
+		}
+		if version == 1 && len(fl.values)%2 == 1 {
+			c.skip(4)
+		}
+	}
+	return filters, c.err
+}
+
+// chunk is a stored chunk of a dataset.
+type chunk struct {
+	offset []int // of the first element
+	addr   uint64
+	size   int
+	mask   uint32 // filters to skip
+}
+
+// chunked reads the data of a chunked dataset.
+func (f *File) chunked(l *layout, filters []filter, dims []int, elemSize int) ([]byte, error) {
+	size, err := f.dataSize(dims, elemSize)
+	if err != nil {
+		return nil, err
+	}
+	chunkLen, err := f.dataSize(l.chunk, elemSize)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if size == 0 || l.addr == undefined {
+		return data, nil
+	}
+	grid := make([]int, len(dims)) // number of chunks in each dimension
+	for i, d := range dims {
+		grid[i] = (d + l.chunk[i] - 1) / l.chunk[i]
+	}
+
+	// the chunks of the implicit and fixed array indexes are in row-major order of the grid
+	offset := func(i int) []int {
+		off := make([]int, len(dims))
+		for d := len(dims) - 1; d >= 0; d-- {
+			off[d] = i % grid[d] * l.chunk[d]
+			i /= grid[d]
+		}
+		return off
+	}
+	numChunks := 1
+	for _, g := range grid {
+		numChunks *= g
+	}
+
+	var chunks []chunk
+	switch l.index {
+	case indexBTree1:
+		keySize := 8 + 8*(len(dims)+1)
+		err := f.btree1(l.addr, keySize, func(key []byte, child uint64) (bool, error) {
+			c := &cursor{f: f, b: key}
+			ch := chunk{addr: child, size: int(c.u32()), mask: c.u32(), offset: make([]int, len(dims))}
+			for i := range ch.offset {
+				ch.offset[i] = int(c.u64())
+			}
+			chunks = append(chunks, ch)
+			return true, c.err
+		})
+		if err != nil {
+			return nil, err
+		}
+	case indexSingle:
+		ch := chunk{addr: l.addr, size: chunkLen, mask: l.filterMask, offset: make([]int, len(dims))}
+		if l.filteredSize > 0 {
+			ch.size = int(l.filteredSize)
+		}
+		chunks = append(chunks, ch)
+	case indexImplicit:
+		for i := 0; i < numChunks; i++ {
+			chunks = append(chunks, chunk{addr: l.addr + uint64(i*chunkLen), size: chunkLen, offset: offset(i)})
+		}
+	case indexFixedArray:
+		entries, err := f.fixedArray(l.addr, numChunks)
+		if err != nil {
+			return nil, err
+		}
+		for i, e := range entries {
+			if e.addr == undefined {
+				continue
+			}
+			if e.size == 0 {
+				e.size = chunkLen
+			}
+			e.offset = offset(i)
+			chunks = append(chunks, e)
+		}
+	}
+
+	for _, ch := range chunks {
+		for i, off := range ch.offset {
+			if off < 0 || off >= dims[i] {
+				return nil, errors.New("hdf5: corrupt chunk offset")
+			}
+		}
+		c, err := f.read(ch.addr, ch.size)
+		if err != nil {
+			return nil, err
+		}
+		// a trailing checksum may be left of the filtered data
+		b, err := unfilter(c.b, filters, ch.mask, chunkLen+4)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) < chunkLen {
+			return nil, errors.New("hdf5: corrupt chunk")
+		}
+		copyChunk(data, dims, b, l.chunk, ch.offset, elemSize)
+	}
+	return data, nil
+}
+
+// fixedArray reads the n entries of the fixed array chunk index at addr.
+func (f *File) fixedArray(addr uint64, n int) ([]chunk, error) {
+	c, err := f.read(addr, 8+f.lenSize+f.offSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.signature("FAHD"); err != nil {
+		return nil, err
+	}
+	c.skip(1) // version
+	client := c.u8()
+	entrySize := int(c.u8())
+	pageBits := c.u8()
+	max := c.length()
+	data := c.addr()
+	if c.err != nil {
+		return nil, c.err
+	}
+	if max > 1<<pageBits {
+		return nil, errors.New("hdf5: paged fixed array chunk indexes aren't supported")
+	}
+	if uint64(n) > max {
+		return nil, errors.New("hdf5: corrupt fixed array chunk index")
+	}
+
+	c, err = f.read(data, 6+f.offSize+n*entrySize)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.signature("FADB"); err != nil {
+		return nil, err
+	}
+	c.skip(2 + f.offSize) // version, client, header address
+	entries := make([]chunk, n)
+	for i := range entries {
+		entries[i].addr = c.addr()
+		if client == 1 {
+			// filtered chunks
+			entries[i].size = int(c.uint(entrySize - f.offSize - 4))
+			entries[i].mask = c.u32()
+		}
+	}
+	return entries, c.err
+}
+
+// unfilter reverses the filters of the pipeline, except for those in the mask. Decompressing more
+// than max bytes is an error.
+func unfilter(b []byte, filters []filter, mask uint32, max int) ([]byte, error) {
+	for i := len(filters) - 1; i >= 0; i-- {
+		if mask&(1<<uint(i)) != 0 {
+			continue
+		}
+		switch fl := filters[i]; fl.id {
+		case 1: // deflate
+			r, err := zlib.NewReader(bytes.NewReader(b))
+			if err != nil {
+				return nil, errors.Wrap(err, "hdf5")
+			}
+			if b, err = io.ReadAll(io.LimitReader(r, int64(max)+1)); err != nil {
+				return nil, errors.Wrap(err, "hdf5")
+			}
+			if len(b) > max {
+				return nil, errors.New("hdf5: corrupt compressed chunk")
+			}
+		case 2: // shuffle
+			if len(fl.values) < 1 || fl.values[0] == 0 {
+				return nil, errors.New("hdf5: corrupt shuffle filter")
+			}
+			size := int(fl.values[0])
+			n := len(b) / size
+			out := make([]byte, len(b))
+			for j := 0; j < size; j++ {
+				for k := 0; k < n; k++ {
+					out[k*size+j] = b[j*n+k]
+				}
+			}
+			// the bytes left over by the shuffle
+			copy(out[n*size:], b[n*size:])
+			b = out
+		case 3: // Fletcher32
+			if len(b) < 4 {
+				return nil, errors.New("hdf5: corrupt Fletcher32 checksum")
+			}
+			b = b[:len(b)-4]
+		default:
+			return nil, errors.Errorf("hdf5: unsupported filter %d", fl.id)
+		}
+	}
+	return b, nil
+}
+
+// copyChunk copies the elements of the chunk of the dimensions chunkDims at the offset into data
+// of the dimensions dims. The parts of edge chunks outside data are skipped.
+func copyChunk(data []byte, dims []int, chunk []byte, chunkDims, offset []int, elemSize int) {
+	rank := len(dims)
+	if rank == 0 {
+		copy(data, chunk[:elemSize])
+		return
+	}
+	// copy the chunk row by row along the last dimension
+	last := rank - 1
+	rowLen := chunkDims[last]
+	if offset[last]+rowLen > dims[last] {
+		rowLen = dims[last] - offset[last]
+	}
+	if rowLen <= 0 {
+		return
+	}
+	idx := make([]int, last) // index of the row in the chunk
+	for {
+		src, dst := 0, 0
+		inside := true
+		for d := 0; d < rank; d++ {
+			i := 0
+			if d < last {
+				i = idx[d]
+				if offset[d]+i >= dims[d] {
+					inside = false
+				}
+			}
+			src = src*chunkDims[d] + i
+			dst = dst*dims[d] + offset[d] + i
+		}
+		if inside {
+			copy(data[dst*elemSize:(dst+rowLen)*elemSize], chunk[src*elemSize:])
+		}
+
+		d := last - 1
+		for ; d >= 0; d-- {
+			idx[d]++
+			if idx[d] < chunkDims[d] {
+				break
+			}
+			idx[d] = 0
+		}
+		if d < 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Package hdf5 implements a reader of the subset of HDF5 files used by SOFA files: groups,
+// datasets of numbers and attributes of numbers and strings.
+//
+// Both the original file format and the newer one (superblocks 0 to 3, object headers 1 and 2,
+// compact and dense groups and attributes) are supported. Datasets can be compact, contiguous or
+// chunked, with the deflate, shuffle and Fletcher32 filters. Checksums aren't verified.
+package hdf5
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"math"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotExist is returned when a group member or an attribute doesn't exist.
+var ErrNotExist = errors.New("hdf5: object doesn't exist")
+
+var signature = []byte("\x89HDF\r\n\x1a\n")
+
+// undefined is the undefined address.
+const undefined = ^uint64(0)
+
+// File is an HDF5 file.
+type File struct {
+	r       io.ReaderAt
+	size    int64 // of the file, which bounds every read
+	base    uint64
+	offSize int
+	lenSize int
+	root    uint64
+}
+
+// Open reads the superblock of an HDF5 file from r.
+func Open(r io.ReaderAt) (*File, error) {
+	// the superblock is at 0, 512, 1024, 2048...
+	var pos int64
+	for {
+		sig := make([]byte, len(signature))
+		if n, _ := r.ReadAt(sig, pos); n < len(sig) {
+			return nil, errors.New("hdf5: missing HDF5 signature")
+		}
+		if bytes.Equal(sig, signature) {
+			break
+		}
+		if pos == 0 {
+			pos = 512
+		} else {
+			pos *= 2
+		}
+	}
+
+	size, err := readerSize(r)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{r: r, size: size}
+	head := make([]byte, 16)
+	if n, _ := r.ReadAt(head, pos+8); n < len(head) {
+		return nil, errors.New("hdf5: truncated superblock")
+	}
+	switch version := head[0]; version {
+	case 0, 1:
+		f.offSize, f.lenSize = int(head[5]), int(head[6])
+		if err := f.checkSizes(); err != nil {
+			return nil, err
+		}
+		n := 24
+		if version == 1 {
+			n += 4
+		}
+		c, err := f.readAbs(pos+int64(n), 4*f.offSize+2*f.offSize+24)
+		if err != nil {
+			return nil, err
+		}
+		c.skip(4 * f.offSize) // base, free space, end of file and driver information addresses
+		c.skip(f.offSize)     // link name offset of the root group's symbol table entry
+		f.root = c.addr()
+		if c.err != nil {
+			return nil, c.err
+		}
+	case 2, 3:
+		f.offSize, f.lenSize = int(head[1]), int(head[2])
+		if err := f.checkSizes(); err != nil {
+			return nil, err
+		}
+		c, err := f.readAbs(pos+12, 4*f.offSize)
+		if err != nil {
+			return nil, err
+		}
+		c.skip(3 * f.offSize) // base, superblock extension and end of file addresses
+		f.root = c.addr()
+		if c.err != nil {
+			return nil, c.err
+		}
+	default:
+		return nil, errors.Errorf("hdf5: unsupported superblock version %d", version)
+	}
+	// like the HDF5 library, take the addresses as relative to the superblock, which follows the
+	// user block, if any
+	f.base = uint64(pos)
+	return f, nil
+}
+
+func (f *File) checkSizes() error {
+	for _, size := range []int{f.offSize, f.lenSize} {
+		if size != 2 && size != 4 && size != 8 {
+			return errors.Errorf("hdf5: unsupported size of offsets or lengths %d", size)
+		}
+	}
+	return nil
+}
+
+// Root returns the root group of the file.
+func (f *File) Root() (*Object, error) {
+	return f.object(f.root)
+}
+
+// read returns a cursor over n bytes at the address addr.
+func (f *File) read(addr uint64, n int) (*cursor, error) {
+	if addr == undefined {
+		return nil, errors.New("hdf5: reading undefined address")
+	}
+	return f.readAbs(int64(f.base+addr), n)
+}
+
+func (f *File) readAbs(pos int64, n int) (*cursor, error) {
+	// the sizes and the addresses come from the file, check them before allocating
+	if n < 0 || pos < 0 || pos > f.size || int64(n) > f.size-pos {
+		return nil, errors.New("hdf5: corrupt file")
+	}
+	b := make([]byte, n)
+	if k, err := f.r.ReadAt(b, pos); k < n {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, errors.Wrap(err, "hdf5")
+	}
+	return &cursor{f: f, b: b}, nil
+}
+
+// readerSize returns the size of the data of r, found by probing r unless it has a Size or a Stat
+// method.
+func readerSize(r io.ReaderAt) (int64, error) {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return r.Size(), nil
+	case interface{ Stat() (fs.FileInfo, error) }:
+		info, err := r.Stat()
+		if err != nil {
+			return 0, errors.Wrap(err, "hdf5")
+		}
+		return info.Size(), nil
+	}
+	// the size is the lowest position without a byte
+	b := make([]byte, 1)
+	has := func(pos int64) bool {
+		n, _ := r.ReadAt(b, pos)
+		return n == 1
+	}
+	lo, hi := int64(0), int64(1) // has(lo-1) and !has(hi) after the search
+	for has(hi - 1) {
+		lo = hi
+		if hi > math.MaxInt64/2 {
+			return 0, errors.New("hdf5: file too large")
+		}
+		hi *= 2
+	}
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if has(mid) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// maxRatio is the maximum compression ratio of deflate, which bounds the size of the data of a
+// dataset relative to the size of the file.
+const maxRatio = 1032
+
+// dataSize returns the size of the data of the elements of elemSize bytes of the dimensions dims.
+// It reports an error if the data couldn't fit into the file, even compressed.
+func (f *File) dataSize(dims []int, elemSize int) (int, error) {
+	limit := (f.size + 1) * maxRatio
+	if elemSize <= 0 || int64(elemSize) > limit {
+		return 0, errors.New("hdf5: corrupt datatype size")
+	}
+	size := int64(elemSize)
+	for _, d := range dims {
+		if d < 0 || d > 0 && size > limit/int64(d) {
+			return 0, errors.New("hdf5: corrupt dimensions")
+		}
+		size *= int64(d)
+	}
+	if size > math.MaxInt {
+		return 0, errors.New("hdf5: dataset too large")
+	}
+	return int(size), nil
+}
+
+// cursor decodes the little-endian fields of a block of the file. A read past the end of the
+// block sets err and returns zeros.
+type cursor struct {
+	f   *File
+	b   []byte
+	off int
+	err error
+}
+
+func (c *cursor) bytes(n int) []byte {
+	if c.err != nil || n < 0 || n > len(c.b)-c.off {
+		if c.err == nil {
+			c.err = errors.New("hdf5: corrupt file")
+		}
+		// zeros for the fixed size fields, the callers check err before using anything larger
+		if n < 0 || n > 8 {
+			n = 0
+		}
+		return make([]byte, n)
+	}
+	p := c.b[c.off : c.off+n]
+	c.off += n
+	return p
+}
+
+func (c *cursor) skip(n int) {
+	c.bytes(n)
+}
+
+func (c *cursor) left() int {
+	return len(c.b) - c.off
+}
+
+// uint decodes an unsigned integer of n bytes.
+func (c *cursor) uint(n int) uint64 {
+	var v uint64
+	for i, x := range c.bytes(n) {
+		v |= uint64(x) << (8 * uint(i))
+	}
+	return v
+}
+
+func (c *cursor) u8() uint8   { return uint8(c.uint(1)) }
+func (c *cursor) u16() uint16 { return uint16(c.uint(2)) }
+func (c *cursor) u32() uint32 { return binary.LittleEndian.Uint32(c.bytes(4)) }
+func (c *cursor) u64() uint64 { return binary.LittleEndian.Uint64(c.bytes(8)) }
+
+// addr decodes an address, undefined if all its bits are set.
+func (c *cursor) addr() uint64 {
+	v := c.uint(c.f.offSize)
+	if v == ^uint64(0)>>(64-8*uint(c.f.offSize)) {
+		return undefined
+	}
+	return v
+}
+
+// length decodes a length.
+func (c *cursor) length() uint64 {
+	return c.uint(c.f.lenSize)
+}
+
+// signature checks the signature of a block.
+func (c *cursor) signature(sig string) error {
+	if got := c.bytes(len(sig)); c.err == nil && string(got) != sig {
+		return errors.Errorf("hdf5: corrupt file, %q block expected", sig)
+	}
+	return c.err
+}
+
+// encSize returns the number of bytes needed to encode n.
+func encSize(n uint64) int {
+	return log2(n)/8 + 1
+}
+
+// log2 returns the base 2 logarithm of n rounded down, 0 for 0.
+func log2(n uint64) int {
+	if n == 0 {
+		return 0
+	}
+	return bits.Len64(n) - 1
+}
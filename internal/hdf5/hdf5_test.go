@@ -0,0 +1,615 @@
+package hdf5_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/brotholo/beep/internal/hdf5"
+)
+
+const undef = ^uint64(0)
+
+// enc encodes the values little-endian.
+func enc(vals ...interface{}) []byte {
+	var b []byte
+	for _, v := range vals {
+		switch v := v.(type) {
+		case uint8:
+			b = append(b, v)
+		case uint16:
+			b = binary.LittleEndian.AppendUint16(b, v)
+		case uint32:
+			b = binary.LittleEndian.AppendUint32(b, v)
+		case uint64:
+			b = binary.LittleEndian.AppendUint64(b, v)
+		case string:
+			b = append(b, v...)
+		case []byte:
+			b = append(b, v...)
+		default:
+			panic("unsupported value")
+		}
+	}
+	return b
+}
+
+func pad8(b []byte) []byte {
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// padTo pads b with zeros to n bytes.
+func padTo(b []byte, n int) []byte {
+	return append(b, make([]byte, n-len(b))...)
+}
+
+func float64s(order binary.AppendByteOrder, x ...float64) []byte {
+	var b []byte
+	for _, v := range x {
+		b = order.AppendUint64(b, math.Float64bits(v))
+	}
+	return b
+}
+
+func float32s(order binary.AppendByteOrder, x ...float64) []byte {
+	var b []byte
+	for _, v := range x {
+		b = order.AppendUint32(b, math.Float32bits(float32(v)))
+	}
+	return b
+}
+
+func deflate(b []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+func shuffle(b []byte, size int) []byte {
+	n := len(b) / size
+	out := make([]byte, len(b))
+	for k := 0; k < n; k++ {
+		for j := 0; j < size; j++ {
+			out[j*n+k] = b[k*size+j]
+		}
+	}
+	return out
+}
+
+// datatypes and dataspaces
+var (
+	float64LE  = enc(uint8(0x11), []byte{0x20, 0x3f, 0}, uint32(8), uint16(0), uint16(64), uint8(52), uint8(11), uint8(0), uint8(52), uint32(1023))
+	float32BE  = enc(uint8(0x11), []byte{0x21, 0x1f, 0}, uint32(4), uint16(0), uint16(32), uint8(23), uint8(8), uint8(0), uint8(23), uint32(127))
+	int16BE    = enc(uint8(0x10), []byte{0x09, 0, 0}, uint32(2), uint16(0), uint16(16))
+	vlenString = enc(uint8(0x19), []byte{0x01, 0, 0}, uint32(16), enc(uint8(0x10), []byte{0, 0, 0}, uint32(1), uint16(0), uint16(8)))
+)
+
+func stringType(n int) []byte {
+	return enc(uint8(0x13), []byte{0, 0, 0}, uint32(n))
+}
+
+func spaceV1(dims ...uint64) []byte {
+	b := enc(uint8(1), uint8(len(dims)), uint8(0), uint8(0), uint32(0))
+	for _, d := range dims {
+		b = enc(b, d)
+	}
+	return b
+}
+
+func spaceV2(dims ...uint64) []byte {
+	typ := uint8(1)
+	if len(dims) == 0 {
+		typ = 0
+	}
+	b := enc(uint8(2), uint8(len(dims)), uint8(0), typ)
+	for _, d := range dims {
+		b = enc(b, d)
+	}
+	return b
+}
+
+func attrV1(name string, dt, space, data []byte) []byte {
+	return enc(uint8(1), uint8(0), uint16(len(name)+1), uint16(len(dt)), uint16(len(space)),
+		pad8([]byte(name+"\x00")), pad8(dt), pad8(space), data)
+}
+
+func attrV3(name string, dt, space, data []byte) []byte {
+	return enc(uint8(3), uint8(0), uint16(len(name)+1), uint16(len(dt)), uint16(len(space)), uint8(0),
+		name+"\x00", dt, space, data)
+}
+
+type msg struct {
+	typ  int
+	data []byte
+}
+
+func msgsV1(msgs ...msg) []byte {
+	var b []byte
+	for _, m := range msgs {
+		data := pad8(m.data)
+		b = enc(b, uint16(m.typ), uint16(len(data)), uint8(0), []byte{0, 0, 0}, data)
+	}
+	return b
+}
+
+// msgsV2 encodes the messages of a version 2 object header, with creation orders if crt.
+func msgsV2(crt bool, msgs ...msg) []byte {
+	var b []byte
+	for i, m := range msgs {
+		b = enc(b, uint8(m.typ), uint16(len(m.data)), uint8(0))
+		if crt {
+			b = enc(b, uint16(i))
+		}
+		b = enc(b, m.data)
+	}
+	return b
+}
+
+type builder struct {
+	b    []byte
+	base uint64 // address of the superblock
+}
+
+// write appends the blocks aligned to 8 bytes and returns their address.
+func (w *builder) write(blocks ...[]byte) uint64 {
+	w.b = pad8(w.b)
+	addr := uint64(len(w.b)) - w.base
+	for _, b := range blocks {
+		w.b = append(w.b, b...)
+	}
+	return addr
+}
+
+// headerV1 writes a version 1 object header of n messages in total, including those in the
+// continuation blocks.
+func (w *builder) headerV1(n int, msgs ...msg) uint64 {
+	data := msgsV1(msgs...)
+	return w.write(enc(uint8(1), uint8(0), uint16(n), uint32(1), uint32(len(data)), uint32(0)), data)
+}
+
+// headerV2 writes a version 2 object header with the flags.
+func (w *builder) headerV2(flags uint8, msgs ...msg) uint64 {
+	b := enc("OHDR", uint8(2), flags)
+	if flags&0x20 != 0 {
+		b = enc(b, make([]byte, 16))
+	}
+	data := msgsV2(flags&0x04 != 0, msgs...)
+	switch flags & 3 {
+	case 0:
+		b = enc(b, uint8(len(data)))
+	case 1:
+		b = enc(b, uint16(len(data)))
+	}
+	return w.write(b, data, enc(uint32(0)))
+}
+
+// btree1 writes a version 1 B-tree leaf node of the type.
+func (w *builder) btree1(typ uint8, keys [][]byte, children []uint64) uint64 {
+	b := enc("TREE", typ, uint8(0), uint16(len(children)), undef, undef)
+	for i, child := range children {
+		b = enc(b, keys[i], child)
+	}
+	return w.write(enc(b, keys[len(children)]))
+}
+
+func chunkKey(size int, mask uint32, offsets ...uint64) []byte {
+	b := enc(uint32(size), mask)
+	for _, off := range offsets {
+		b = enc(b, off)
+	}
+	return enc(b, uint64(0))
+}
+
+// oldFile returns a file in the original format: superblock version 0, object headers version 1
+// and a symbol table group, after a user block of 512 bytes.
+func oldFile() []byte {
+	w := &builder{b: make([]byte, 512+96), base: 512}
+
+	// contiguous dataset with an attribute in a continuation block
+	ir := w.write(float64s(binary.LittleEndian, 1, 2, 3, 4, 5, 6))
+	units := msgsV1(msg{0x0c, attrV1("Units", stringType(5), spaceV1(), []byte("metre"))})
+	cont := w.write(units)
+	irHeader := w.headerV1(5,
+		msg{0x01, spaceV1(2, 3)},
+		msg{0x03, float64LE},
+		msg{0x08, enc(uint8(3), uint8(1), ir, uint64(48))},
+		msg{0x10, enc(cont, uint64(len(units)))},
+	)
+
+	// chunked dataset of 5x3 in chunks of 2x2, shuffled and deflated, in a B-tree of two levels
+	var keys [][]byte
+	var chunks []uint64
+	for ci := 0; ci < 3; ci++ {
+		for cj := 0; cj < 2; cj++ {
+			var x []float64
+			for i := 2 * ci; i < 2*ci+2; i++ {
+				for j := 2 * cj; j < 2*cj+2; j++ {
+					if i < 5 && j < 3 {
+						x = append(x, float64(10*i+j))
+					} else {
+						x = append(x, 0)
+					}
+				}
+			}
+			data := shuffle(float64s(binary.LittleEndian, x...), 8)
+			mask := uint32(0)
+			if ci == 1 {
+				// not deflated
+				mask = 2
+			} else {
+				data = deflate(data)
+			}
+			chunks = append(chunks, w.write(data))
+			keys = append(keys, chunkKey(len(data), mask, uint64(2*ci), uint64(2*cj)))
+		}
+	}
+	keys = append(keys, chunkKey(0, 0, 5, 3))
+	leaf1 := w.btree1(1, keys[:4], chunks[:3])
+	leaf2 := w.btree1(1, keys[3:], chunks[3:])
+	root := enc("TREE", uint8(1), uint8(1), uint16(2), undef, undef, keys[0], leaf1, keys[3], leaf2, keys[6])
+	btree := w.write(root)
+	filters := enc(uint8(1), uint8(2), make([]byte, 6),
+		uint16(2), uint16(0), uint16(0), uint16(1), uint32(8), uint32(0),
+		uint16(1), uint16(0), uint16(0), uint16(1), uint32(6), uint32(0))
+	chunkedHeader := w.headerV1(4,
+		msg{0x01, spaceV1(5, 3)},
+		msg{0x03, float64LE},
+		msg{0x08, enc(uint8(3), uint8(2), uint8(3), btree, uint32(2), uint32(2), uint32(8))},
+		msg{0x0b, filters},
+	)
+
+	// compact scalar
+	scalarHeader := w.headerV1(3,
+		msg{0x01, spaceV1()},
+		msg{0x03, int16BE},
+		msg{0x08, enc(uint8(3), uint8(0), uint16(2), []byte{0xff, 0xf9})},
+	)
+
+	// the root group
+	names := pad8([]byte("\x00IR\x00Chunked\x00Scalar\x00"))
+	heapData := w.write(names)
+	heap := w.write(enc("HEAP", uint8(0), []byte{0, 0, 0}, uint64(len(names)), undef, heapData))
+	entry := func(name uint64, addr uint64) []byte {
+		return enc(name, addr, uint32(0), uint32(0), make([]byte, 16))
+	}
+	snod := w.write(enc("SNOD", uint8(1), uint8(0), uint16(3),
+		entry(1, irHeader), entry(4, chunkedHeader), entry(12, scalarHeader)))
+	groupTree := w.btree1(0, [][]byte{enc(uint64(0)), enc(uint64(12))}, []uint64{snod})
+	rootHeader := w.headerV1(2,
+		msg{0x11, enc(groupTree, heap)},
+		msg{0x0c, attrV1("Conventions", stringType(4), spaceV1(), []byte("SOFA"))},
+	)
+
+	sb := enc("\x89HDF\r\n\x1a\n", []byte{0, 0, 0, 0, 0, 8, 8, 0}, uint16(4), uint16(16), uint32(0),
+		uint64(0), undef, uint64(len(w.b)-512), undef,
+		entry(0, rootHeader))
+	copy(w.b[512:], sb)
+	return w.b
+}
+
+// fractalHeap writes a fractal heap of the objects and returns its address and their heap IDs.
+// The objects are stored in direct blocks of 512 bytes, split before the indexes in splits. With
+// splits, the root of the heap is an indirect block.
+func (w *builder) fractalHeap(idLen int, objects [][]byte, splits ...int) (uint64, [][]byte) {
+	const blockSize = 512
+	const header = 17 // signature, version, heap header address and 4 bytes of block offset
+
+	var ids [][]byte
+	var blocks []uint64
+	splits = append(splits, len(objects))
+	start := 0
+	for b, end := range splits {
+		block := enc("FHDB", uint8(0), uint64(0), uint32(b*blockSize))
+		for _, obj := range objects[start:end] {
+			id := enc(uint8(0), uint32(b*blockSize+len(block)), uint16(len(obj)))
+			ids = append(ids, padTo(id, idLen))
+			block = enc(block, obj)
+		}
+		blocks = append(blocks, w.write(padTo(block, blockSize)))
+		start = end
+	}
+
+	root, rows := blocks[0], uint16(0)
+	if len(blocks) > 1 {
+		ib := enc("FHIB", uint8(0), uint64(0), uint32(0))
+		for _, b := range blocks {
+			ib = enc(ib, b)
+		}
+		root, rows = w.write(ib, enc(uint32(0))), 1
+	}
+	addr := w.write(enc("FRHP", uint8(0), uint16(idLen), uint16(0), uint8(0), uint32(4096),
+		uint64(0), undef, uint64(0), undef, make([]byte, 64),
+		uint16(len(blocks)), uint64(blockSize), uint64(blockSize), uint16(32), uint16(1),
+		root, rows, uint32(0)))
+	return addr, ids
+}
+
+// btree2 writes a version 2 B-tree of the type with a single leaf, or with two leaves if the
+// records are split.
+func (w *builder) btree2(typ uint8, records [][]byte, split int) uint64 {
+	const nodeSize = 512
+	node := func(sig string, recs [][]byte, ptrs ...[]byte) []byte {
+		b := enc(sig, uint8(0), typ)
+		for _, r := range recs {
+			b = enc(b, r)
+		}
+		for _, p := range ptrs {
+			b = enc(b, p)
+		}
+		return padTo(enc(b, uint32(0)), nodeSize)
+	}
+	depth, root, n := uint16(0), uint64(0), len(records)
+	if split > 0 {
+		leaf1 := w.write(node("BTLF", records[:split]))
+		leaf2 := w.write(node("BTLF", records[split+1:]))
+		depth, n = 1, 1
+		root = w.write(node("BTIN", records[split:split+1],
+			enc(leaf1, uint8(split)), enc(leaf2, uint8(len(records)-split-1))))
+	} else {
+		root = w.write(node("BTLF", records))
+	}
+	return w.write(enc("BTHD", uint8(0), typ, uint32(nodeSize), uint16(len(records[0])), depth,
+		uint8(100), uint8(40), root, uint16(n), uint64(len(records)), uint32(0)))
+}
+
+// newFile returns a file in the newer format: superblock version 2, object headers version 2 and
+// dense groups and attributes.
+func newFile() []byte {
+	w := &builder{b: make([]byte, 48)}
+
+	// dataset in chunks of a fixed array index, with a variable length string attribute
+	var chunks []uint64
+	for c := 0; c < 2; c++ {
+		chunks = append(chunks, w.write(float32s(binary.BigEndian,
+			float64(c), float64(c)+0.5, float64(c)+1, float64(c)+1.5, float64(c)+2, float64(c)+2.5)))
+	}
+	fadb := w.write(enc("FADB", uint8(0), uint8(0), uint64(0), chunks[0], chunks[1], uint32(0)))
+	fahd := w.write(enc("FAHD", uint8(0), uint8(0), uint8(8), uint8(10), uint64(2), fadb, uint32(0)))
+	str := "spherical"
+	gcol := w.write(enc("GCOL", uint8(1), []byte{0, 0, 0}, uint64(64),
+		uint16(1), uint16(1), uint32(0), uint64(len(str)), pad8([]byte(str)),
+		uint16(0), uint16(0), uint32(0), uint64(16)))
+	irHeader := w.headerV2(0x00,
+		msg{0x01, spaceV2(2, 2, 3)},
+		msg{0x03, float32BE},
+		msg{0x08, enc(uint8(4), uint8(2), uint8(0), uint8(4), uint8(1), []byte{1, 2, 3, 4}, uint8(3), uint8(10), fahd)},
+		msg{0x0c, attrV3("Type", vlenString, spaceV2(), enc(uint32(len(str)), gcol, uint32(1)))},
+		msg{0x0c, enc(uint8(2), uint8(0), uint16(6), uint16(len(stringType(5))), uint16(len(spaceV2(1))),
+			"Units\x00", stringType(5), spaceV2(1), "metre")},
+	)
+
+	// single deflated chunk with a checksum
+	data := deflate(float64s(binary.LittleEndian, 0.25, 0.5, 0.75, 1))
+	data = enc(data, uint32(0))
+	single := w.write(data)
+	filteredHeader := w.headerV2(0x20,
+		msg{0x01, spaceV2(4)},
+		msg{0x03, float64LE},
+		msg{0x0b, enc(uint8(2), uint8(2), uint16(1), uint16(0), uint16(1), uint32(6), uint16(3), uint16(0), uint16(0))},
+		msg{0x08, enc(uint8(4), uint8(2), uint8(2), uint8(2), uint8(1), []byte{4, 8}, uint8(1), uint64(len(data)), uint32(0), single)},
+	)
+
+	// implicit index of chunks of 2x2 of a dataset of 3x2
+	implicit := w.write(float64s(binary.LittleEndian, 1, 2, 3, 4, 5, 6, 0, 0))
+	implicitHeader := w.headerV2(0x01,
+		msg{0x01, spaceV2(3, 2)},
+		msg{0x03, float64LE},
+		msg{0x08, enc(uint8(4), uint8(2), uint8(0), uint8(3), uint8(1), []byte{2, 2, 8}, uint8(2), implicit)},
+	)
+
+	// group with a compact link
+	leafHeader := w.headerV2(0x00,
+		msg{0x01, spaceV2()},
+		msg{0x03, float64LE},
+		msg{0x08, enc(uint8(3), uint8(0), uint16(8), float64s(binary.LittleEndian, 42))},
+	)
+	subHeader := w.headerV2(0x00,
+		msg{0x02, enc(uint8(0), uint8(0), undef, undef)},
+		msg{0x06, enc(uint8(1), uint8(0x14), uint64(0), uint8(0), uint8(4), "Leaf", leafHeader)},
+	)
+
+	// the root group, with dense links and attributes in a continuation block
+	var links [][]byte
+	for _, l := range []struct {
+		name string
+		addr uint64
+	}{{"Data.IR", irHeader}, {"Filtered", filteredHeader}, {"Implicit", implicitHeader}, {"Sub", subHeader}} {
+		links = append(links, enc(uint8(1), uint8(0), uint8(len(l.name)), l.name, l.addr))
+	}
+	linkHeap, linkIDs := w.fractalHeap(7, links, 2)
+	var linkRecs [][]byte
+	for _, id := range linkIDs {
+		linkRecs = append(linkRecs, enc(uint32(0), id))
+	}
+	linkIndex := w.btree2(5, linkRecs, 1)
+
+	attrs := [][]byte{
+		attrV3("Conventions", stringType(4), spaceV2(), []byte("SOFA")),
+		attrV3("DataType", stringType(3), spaceV2(), []byte("FIR")),
+	}
+	attrHeap, attrIDs := w.fractalHeap(8, attrs)
+	var attrRecs [][]byte
+	for i, id := range attrIDs {
+		attrRecs = append(attrRecs, enc(id, uint8(0), uint32(i), uint32(0)))
+	}
+	attrIndex := w.btree2(8, attrRecs, 0)
+
+	ochk := enc("OCHK", msgsV2(true, msg{0x15, enc(uint8(0), uint8(0), attrHeap, attrIndex)}), uint32(0))
+	cont := w.write(ochk)
+	rootHeader := w.headerV2(0x25,
+		msg{0x02, enc(uint8(0), uint8(1), uint64(4), linkHeap, linkIndex)},
+		msg{0x10, enc(cont, uint64(len(ochk)))},
+	)
+
+	copy(w.b, enc("\x89HDF\r\n\x1a\n", uint8(2), uint8(8), uint8(8), uint8(0),
+		uint64(0), undef, uint64(len(w.b)), rootHeader, uint32(0)))
+	return w.b
+}
+
+func open(t *testing.T, data []byte) *hdf5.Object {
+	t.Helper()
+	f, err := hdf5.Open(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := f.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func floats(t *testing.T, o *hdf5.Object, name string) ([]float64, []int) {
+	t.Helper()
+	child, err := o.Child(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := child.Value()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	x, err := v.Floats()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return x, v.Dims
+}
+
+func attr(t *testing.T, o *hdf5.Object, name string) string {
+	t.Helper()
+	v, err := o.Attr(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := v.Strings()
+	if err != nil || len(s) != 1 {
+		t.Fatalf("attribute %s: %v %v", name, s, err)
+	}
+	return s[0]
+}
+
+func TestOldFormat(t *testing.T) {
+	root := open(t, oldFile())
+
+	names, err := root.Children()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"IR", "Chunked", "Scalar"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("children %v, want %v", names, want)
+	}
+	if got := attr(t, root, "Conventions"); got != "SOFA" {
+		t.Errorf("Conventions is %q", got)
+	}
+
+	x, dims := floats(t, root, "IR")
+	if !reflect.DeepEqual(x, []float64{1, 2, 3, 4, 5, 6}) || !reflect.DeepEqual(dims, []int{2, 3}) {
+		t.Errorf("IR is %v of %v", x, dims)
+	}
+	ir, _ := root.Child("IR")
+	if got := attr(t, ir, "Units"); got != "metre" {
+		t.Errorf("Units is %q", got)
+	}
+
+	x, dims = floats(t, root, "Chunked")
+	var want []float64
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 3; j++ {
+			want = append(want, float64(10*i+j))
+		}
+	}
+	if !reflect.DeepEqual(x, want) || !reflect.DeepEqual(dims, []int{5, 3}) {
+		t.Errorf("Chunked is %v of %v, want %v", x, dims, want)
+	}
+
+	x, dims = floats(t, root, "Scalar")
+	if !reflect.DeepEqual(x, []float64{-7}) || len(dims) != 0 {
+		t.Errorf("Scalar is %v of %v", x, dims)
+	}
+
+	if _, err := root.Child("Missing"); !errors.Is(err, hdf5.ErrNotExist) {
+		t.Errorf("Child of a missing name returned %v", err)
+	}
+}
+
+func TestNewFormat(t *testing.T) {
+	root := open(t, newFile())
+
+	names, err := root.Children()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Data.IR", "Filtered", "Implicit", "Sub"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("children %v, want %v", names, want)
+	}
+	if got := attr(t, root, "DataType"); got != "FIR" {
+		t.Errorf("DataType is %q", got)
+	}
+	if _, err := root.Attr("Missing"); !errors.Is(err, hdf5.ErrNotExist) {
+		t.Errorf("Attr of a missing name returned %v", err)
+	}
+
+	x, dims := floats(t, root, "Data.IR")
+	want := []float64{0, 0.5, 1, 1.5, 2, 2.5, 1, 1.5, 2, 2.5, 3, 3.5}
+	if !reflect.DeepEqual(x, want) || !reflect.DeepEqual(dims, []int{2, 2, 3}) {
+		t.Errorf("Data.IR is %v of %v", x, dims)
+	}
+	ir, _ := root.Child("Data.IR")
+	if got := attr(t, ir, "Type"); got != "spherical" {
+		t.Errorf("Type is %q", got)
+	}
+	if got := attr(t, ir, "Units"); got != "metre" {
+		t.Errorf("Units is %q", got)
+	}
+
+	if x, _ := floats(t, root, "Filtered"); !reflect.DeepEqual(x, []float64{0.25, 0.5, 0.75, 1}) {
+		t.Errorf("Filtered is %v", x)
+	}
+	if x, _ := floats(t, root, "Implicit"); !reflect.DeepEqual(x, []float64{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("Implicit is %v", x)
+	}
+
+	sub, err := root.Child("Sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := floats(t, sub, "Leaf"); !reflect.DeepEqual(x, []float64{42}) {
+		t.Errorf("Sub/Leaf is %v", x)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	if _, err := hdf5.Open(bytes.NewReader(make([]byte, 2048))); err == nil {
+		t.Error("opened a file without the signature")
+	}
+	data := newFile()
+	if _, err := hdf5.Open(bytes.NewReader(data[:40])); err == nil {
+		t.Error("opened a truncated file")
+	}
+}
+
+func TestOpenUnsized(t *testing.T) {
+	// a ReaderAt without a Size method, whose size is probed
+	f, err := hdf5.Open(struct{ io.ReaderAt }{bytes.NewReader(oldFile())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := f.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := floats(t, root, "IR"); !reflect.DeepEqual(x, []float64{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("IR is %v", x)
+	}
+}
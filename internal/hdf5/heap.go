@@ -0,0 +1,218 @@
+package hdf5
+
+import (
+	"github.com/pkg/errors"
+)
+
+// localHeap returns the data segment of the local heap at addr.
+func (f *File) localHeap(addr uint64) ([]byte, error) {
+	c, err := f.read(addr, 8+2*f.lenSize+f.offSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.signature("HEAP"); err != nil {
+		return nil, err
+	}
+	c.skip(4) // version, reserved
+	size := c.length()
+	c.skip(f.lenSize) // free list
+	data := c.addr()
+	if c.err != nil {
+		return nil, c.err
+	}
+	c, err = f.read(data, int(size))
+	if err != nil {
+		return nil, err
+	}
+	return c.b, nil
+}
+
+// globalHeap returns the object of the global heap collection at addr with the index.
+func (f *File) globalHeap(addr uint64, index uint32) ([]byte, error) {
+	c, err := f.read(addr, 8+f.lenSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.signature("GCOL"); err != nil {
+		return nil, err
+	}
+	c.skip(4) // version, reserved
+	size := c.length()
+	c, err = f.read(addr, int(size))
+	if err != nil {
+		return nil, err
+	}
+	c.skip(8 + f.lenSize)
+	for c.left() >= 8+f.lenSize {
+		i := c.u16()
+		c.skip(6) // reference count, reserved
+		n := int(c.length())
+		if i == 0 {
+			// free space
+			break
+		}
+		data := c.bytes(n)
+		c.skip((8 - n%8) % 8)
+		if c.err != nil {
+			return nil, c.err
+		}
+		if uint32(i) == index {
+			return data, nil
+		}
+	}
+	return nil, errors.New("hdf5: missing global heap object")
+}
+
+// fractalHeap is a heap of the objects of dense groups and attributes.
+type fractalHeap struct {
+	f             *File
+	width         int
+	start         uint64 // starting block size
+	maxDirectRows int
+	offSize       int // size of the offsets into the heap
+	lenSize       int // size of the lengths of the managed objects
+	idLen         int
+	root          uint64
+	rootRows      int
+}
+
+func (f *File) fractalHeap(addr uint64) (*fractalHeap, error) {
+	c, err := f.read(addr, 22+12*f.lenSize+3*f.offSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.signature("FRHP"); err != nil {
+		return nil, err
+	}
+	c.skip(1) // version
+	h := &fractalHeap{f: f, idLen: int(c.u16())}
+	if filters := c.u16(); filters != 0 {
+		return nil, errors.New("hdf5: filtered fractal heaps aren't supported")
+	}
+	c.skip(1) // flags
+	maxManaged := uint64(c.u32())
+	c.skip(f.lenSize + f.offSize) // huge objects
+	c.skip(f.lenSize + f.offSize) // free space
+	c.skip(8 * f.lenSize)         // statistics
+	h.width = int(c.u16())
+	h.start = c.length()
+	maxDirect := c.length()
+	maxHeapBits := int(c.u16())
+	c.skip(2) // starting number of rows of the root indirect block
+	h.root = c.addr()
+	h.rootRows = int(c.u16())
+	if c.err != nil {
+		return nil, c.err
+	}
+	if h.width == 0 || h.start == 0 || maxDirect < h.start {
+		return nil, errors.New("hdf5: corrupt fractal heap")
+	}
+
+	h.maxDirectRows = log2(maxDirect) - log2(h.start) + 2
+	h.offSize = (maxHeapBits + 7) / 8
+	h.lenSize = (log2(maxDirect) + 7) / 8
+	if n := encSize(maxManaged); n < h.lenSize {
+		h.lenSize = n
+	}
+	return h, nil
+}
+
+// get returns the object of the heap ID.
+func (h *fractalHeap) get(id []byte) ([]byte, error) {
+	if len(id) < 1 {
+		return nil, errors.New("hdf5: corrupt heap ID")
+	}
+	switch typ := id[0] >> 4 & 3; typ {
+	case 0:
+		c := &cursor{f: h.f, b: id[1:]}
+		off := c.uint(h.offSize)
+		n := c.uint(h.lenSize)
+		if c.err != nil {
+			return nil, c.err
+		}
+		addr, start, err := h.locate(off)
+		if err != nil {
+			return nil, err
+		}
+		c, err = h.f.read(addr+off-start, int(n))
+		if err != nil {
+			return nil, err
+		}
+		return c.b, nil
+	case 2:
+		// tiny object in the ID
+		n, data := int(id[0]&0x0f)+1, id[1:]
+		if h.idLen > 18 {
+			if len(id) < 2 {
+				return nil, errors.New("hdf5: corrupt heap ID")
+			}
+			n, data = int(id[0]&0x0f)<<8+int(id[1])+1, id[2:]
+		}
+		if n > len(data) {
+			return nil, errors.New("hdf5: corrupt heap ID")
+		}
+		return data[:n], nil
+	default:
+		return nil, errors.New("hdf5: huge fractal heap objects aren't supported")
+	}
+}
+
+// locate returns the address and the heap offset of the direct block with the heap offset off.
+func (h *fractalHeap) locate(off uint64) (addr, start uint64, err error) {
+	if h.rootRows == 0 {
+		return h.root, 0, nil
+	}
+	return h.locateIn(h.root, h.rootRows, off)
+}
+
+// rowSize returns the size of the blocks in the row of an indirect block.
+func (h *fractalHeap) rowSize(row int) uint64 {
+	if row < 2 {
+		return h.start
+	}
+	return h.start << uint(row-1)
+}
+
+// locateIn locates the heap offset off in the indirect block at addr with rows rows.
+func (h *fractalHeap) locateIn(addr uint64, rows int, off uint64) (uint64, uint64, error) {
+	direct := rows
+	if direct > h.maxDirectRows {
+		direct = h.maxDirectRows
+	}
+	n := 5 + h.f.offSize + h.offSize + rows*h.width*h.f.offSize
+	c, err := h.f.read(addr, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := c.signature("FHIB"); err != nil {
+		return 0, 0, err
+	}
+	c.skip(1 + h.f.offSize) // version, heap header address
+	start := c.uint(h.offSize)
+	for row := 0; row < rows; row++ {
+		size := h.rowSize(row)
+		for i := 0; i < h.width; i++ {
+			child := c.addr()
+			if c.err != nil {
+				return 0, 0, c.err
+			}
+			if off < start || off >= start+size {
+				start += size
+				continue
+			}
+			if child == undefined {
+				return 0, 0, errors.New("hdf5: corrupt fractal heap offset")
+			}
+			if row < direct {
+				return child, start, nil
+			}
+			// the child has fewer rows, which ends the recursion of corrupt heaps
+			childRows := log2(size) - log2(h.start*uint64(h.width)) + 1
+			if childRows <= 0 || childRows >= rows {
+				return 0, 0, errors.New("hdf5: corrupt fractal heap")
+			}
+			return h.locateIn(child, childRows, off)
+		}
+	}
+	return 0, 0, errors.New("hdf5: corrupt fractal heap offset")
+}
@@ -0,0 +1,393 @@
+package hdf5
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// header message types
+const (
+	msgDataspace     = 0x01
+	msgLinkInfo      = 0x02
+	msgDatatype      = 0x03
+	msgLink          = 0x06
+	msgLayout        = 0x08
+	msgFilters       = 0x0b
+	msgAttribute     = 0x0c
+	msgContinuation  = 0x10
+	msgSymbolTable   = 0x11
+	msgAttributeInfo = 0x15
+)
+
+type message struct {
+	typ  int
+	data []byte
+}
+
+// Object is a group or a dataset of an HDF5 file.
+type Object struct {
+	f    *File
+	msgs []message
+}
+
+func (f *File) object(addr uint64) (*Object, error) {
+	c, err := f.read(addr, 4)
+	if err != nil {
+		return nil, err
+	}
+	o := &Object{f: f}
+	if string(c.b) == "OHDR" {
+		err = o.readV2(addr)
+	} else {
+		err = o.readV1(addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// block is a block of header messages.
+type block struct {
+	addr, size uint64
+}
+
+// readV1 reads an object header of version 1.
+func (o *Object) readV1(addr uint64) error {
+	c, err := o.f.read(addr, 16)
+	if err != nil {
+		return err
+	}
+	if version := c.u8(); version != 1 {
+		return errors.Errorf("hdf5: unsupported object header version %d", version)
+	}
+	c.skip(1)
+	n := int(c.u16())
+	c.skip(4) // reference count
+	blocks := []block{{addr + 16, uint64(c.u32())}}
+
+	seen := make(map[uint64]bool)
+	for read := 0; len(blocks) > 0 && read < n; {
+		b := blocks[0]
+		blocks = blocks[1:]
+		if seen[b.addr] {
+			return errors.New("hdf5: corrupt object header")
+		}
+		seen[b.addr] = true
+		c, err := o.f.read(b.addr, int(b.size))
+		if err != nil {
+			return err
+		}
+		for ; c.left() >= 8 && read < n; read++ {
+			typ := int(c.u16())
+			size := int(c.u16())
+			c.skip(4) // flags, reserved
+			data := c.bytes(size)
+			if c.err != nil {
+				return c.err
+			}
+			blocks = o.add(typ, data, blocks)
+		}
+	}
+	return nil
+}
+
+// readV2 reads an object header of version 2.
+func (o *Object) readV2(addr uint64) error {
+	c, err := o.f.read(addr, 6)
+	if err != nil {
+		return err
+	}
+	c.skip(4)
+	if version := c.u8(); version != 2 {
+		return errors.Errorf("hdf5: unsupported object header version %d", version)
+	}
+	flags := c.u8()
+	prefix := 6
+	if flags&0x20 != 0 {
+		prefix += 16 // times
+	}
+	if flags&0x10 != 0 {
+		prefix += 4 // attribute phase change values
+	}
+	sizeLen := 1 << (flags & 3)
+	c, err = o.f.read(addr+uint64(prefix), sizeLen)
+	if err != nil {
+		return err
+	}
+	blocks := []block{{addr + uint64(prefix+sizeLen), c.uint(sizeLen)}}
+
+	headLen := 4
+	if flags&0x04 != 0 {
+		headLen += 2 // creation order
+	}
+	first := true
+	seen := make(map[uint64]bool)
+	for len(blocks) > 0 {
+		b := blocks[0]
+		blocks = blocks[1:]
+		if seen[b.addr] {
+			return errors.New("hdf5: corrupt object header")
+		}
+		seen[b.addr] = true
+		c, err := o.f.read(b.addr, int(b.size))
+		if err != nil {
+			return err
+		}
+		if !first {
+			// continuation blocks have a signature and a checksum
+			if err := c.signature("OCHK"); err != nil {
+				return err
+			}
+			c.b = c.b[:len(c.b)-4]
+		}
+		first = false
+		for c.left() >= headLen {
+			typ := int(c.u8())
+			size := int(c.u16())
+			c.skip(headLen - 3)
+			data := c.bytes(size)
+			if c.err != nil {
+				return c.err
+			}
+			blocks = o.add(typ, data, blocks)
+		}
+	}
+	return nil
+}
+
+// add adds a message to the object, or a continuation block to blocks.
+func (o *Object) add(typ int, data []byte, blocks []block) []block {
+	switch typ {
+	case 0:
+	case msgContinuation:
+		c := &cursor{f: o.f, b: data}
+		b := block{c.addr(), c.length()}
+		if c.err == nil {
+			blocks = append(blocks, b)
+		}
+	default:
+		o.msgs = append(o.msgs, message{typ, data})
+	}
+	return blocks
+}
+
+// message returns a cursor over the first message of the type, nil if there's none.
+func (o *Object) message(typ int) *cursor {
+	for _, m := range o.msgs {
+		if m.typ == typ {
+			return &cursor{f: o.f, b: m.data}
+		}
+	}
+	return nil
+}
+
+// Child returns the member of the group with the name.
+func (o *Object) Child(name string) (*Object, error) {
+	addr := undefined
+	err := o.links(func(n string, a uint64) bool {
+		if n == name {
+			addr = a
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if addr == undefined {
+		return nil, errors.Wrap(ErrNotExist, name)
+	}
+	return o.f.object(addr)
+}
+
+// Children returns the names of the members of the group.
+func (o *Object) Children() ([]string, error) {
+	var names []string
+	err := o.links(func(name string, _ uint64) bool {
+		names = append(names, name)
+		return true
+	})
+	return names, err
+}
+
+// links calls fn with the name and the address of the members of the group, until fn returns
+// false.
+func (o *Object) links(fn func(name string, addr uint64) bool) error {
+	if c := o.message(msgSymbolTable); c != nil {
+		return o.f.symbolTable(c.addr(), c.addr(), fn)
+	}
+
+	more := true
+	if c := o.message(msgLinkInfo); c != nil {
+		c.skip(1) // version
+		if flags := c.u8(); flags&1 != 0 {
+			c.skip(8) // maximum creation index
+		}
+		heapAddr, nameIndex := c.addr(), c.addr()
+		if c.err != nil {
+			return c.err
+		}
+		if heapAddr != undefined {
+			heap, err := o.f.fractalHeap(heapAddr)
+			if err != nil {
+				return err
+			}
+			err = o.f.btree2(nameIndex, func(rec []byte) (bool, error) {
+				if len(rec) < 5 {
+					return false, errors.New("hdf5: corrupt link name index")
+				}
+				link, err := heap.get(rec[4:])
+				if err != nil {
+					return false, err
+				}
+				name, addr, err := o.f.link(link)
+				if err != nil {
+					return false, err
+				}
+				more = fn(name, addr)
+				return more, nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, m := range o.msgs {
+		if m.typ != msgLink || !more {
+			continue
+		}
+		name, addr, err := o.f.link(m.data)
+		if err != nil {
+			return err
+		}
+		more = fn(name, addr)
+	}
+	return nil
+}
+
+// link decodes a link message. Soft and external links have an undefined address.
+func (f *File) link(data []byte) (name string, addr uint64, err error) {
+	c := &cursor{f: f, b: data}
+	if version := c.u8(); version != 1 {
+		return "", 0, errors.Errorf("hdf5: unsupported link message version %d", version)
+	}
+	flags := c.u8()
+	var typ uint8
+	if flags&0x08 != 0 {
+		typ = c.u8()
+	}
+	if flags&0x04 != 0 {
+		c.skip(8) // creation order
+	}
+	if flags&0x10 != 0 {
+		c.skip(1) // character set
+	}
+	name = string(c.bytes(int(c.uint(1 << (flags & 3)))))
+	addr = undefined
+	if typ == 0 {
+		addr = c.addr()
+	}
+	return name, addr, c.err
+}
+
+// Attr returns the value of the attribute of the object with the name.
+func (o *Object) Attr(name string) (*Value, error) {
+	for _, m := range o.msgs {
+		if m.typ != msgAttribute {
+			continue
+		}
+		n, v, err := o.f.attribute(m.data)
+		if err != nil {
+			return nil, err
+		}
+		if n == name {
+			return v, nil
+		}
+	}
+
+	if c := o.message(msgAttributeInfo); c != nil {
+		c.skip(1) // version
+		if flags := c.u8(); flags&1 != 0 {
+			c.skip(2) // maximum creation index
+		}
+		heapAddr, nameIndex := c.addr(), c.addr()
+		if c.err != nil {
+			return nil, c.err
+		}
+		if heapAddr != undefined {
+			heap, err := o.f.fractalHeap(heapAddr)
+			if err != nil {
+				return nil, err
+			}
+			var found *Value
+			err = o.f.btree2(nameIndex, func(rec []byte) (bool, error) {
+				if len(rec) < 8 {
+					return false, errors.New("hdf5: corrupt attribute name index")
+				}
+				data, err := heap.get(rec[:8])
+				if err != nil {
+					return false, err
+				}
+				n, v, err := o.f.attribute(data)
+				if err != nil {
+					return false, err
+				}
+				if n == name {
+					found = v
+					return false, nil
+				}
+				return true, nil
+			})
+			if err != nil || found != nil {
+				return found, err
+			}
+		}
+	}
+	return nil, errors.Wrap(ErrNotExist, name)
+}
+
+// attribute decodes an attribute message.
+func (f *File) attribute(data []byte) (name string, v *Value, err error) {
+	c := &cursor{f: f, b: data}
+	version := c.u8()
+	if version < 1 || version > 3 {
+		return "", nil, errors.Errorf("hdf5: unsupported attribute message version %d", version)
+	}
+	if flags := c.u8(); version > 1 && flags&3 != 0 {
+		return "", nil, errors.New("hdf5: shared attribute datatypes aren't supported")
+	}
+	nameSize, typeSize, spaceSize := int(c.u16()), int(c.u16()), int(c.u16())
+	if version == 3 {
+		c.skip(1) // character set of the name
+	}
+	pad := func(n int) int {
+		if version == 1 {
+			return (n + 7) &^ 7
+		}
+		return n
+	}
+	name = strings.TrimRight(string(c.bytes(pad(nameSize))), "\x00")
+	dt, err := f.datatype(c.bytes(pad(typeSize)))
+	if err != nil {
+		return "", nil, err
+	}
+	dims, err := dataspace(c.bytes(pad(spaceSize)), f)
+	if err != nil {
+		return "", nil, err
+	}
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	v = &Value{Dims: dims, f: f, dt: dt}
+	size, err := f.dataSize(dims, dt.size)
+	if err != nil {
+		return "", nil, err
+	}
+	if c.left() < size {
+		return "", nil, errors.New("hdf5: corrupt attribute")
+	}
+	v.data = c.bytes(size)
+	return name, v, nil
+}
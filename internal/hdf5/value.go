@@ -0,0 +1,163 @@
+package hdf5
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// datatype classes
+const (
+	classFixed  = 0
+	classFloat  = 1
+	classString = 3
+	classVLen   = 9
+)
+
+type datatype struct {
+	class     int
+	size      int
+	bigEndian bool
+	signed    bool
+	vlenStr   bool // variable length string
+}
+
+func (f *File) datatype(data []byte) (*datatype, error) {
+	c := &cursor{f: f, b: data}
+	b := c.u8()
+	bits := c.bytes(3)
+	dt := &datatype{class: int(b & 0x0f), size: int(c.u32())}
+	switch dt.class {
+	case classFixed:
+		dt.bigEndian = bits[0]&1 != 0
+		dt.signed = bits[0]&0x08 != 0
+	case classFloat:
+		dt.bigEndian = bits[0]&1 != 0
+		if bits[0]&0x40 != 0 {
+			return nil, errors.New("hdf5: VAX floating point numbers aren't supported")
+		}
+	case classVLen:
+		dt.vlenStr = bits[0]&0x0f == 1
+	}
+	return dt, c.err
+}
+
+// dataspace decodes a dataspace message into the dimensions.
+func dataspace(data []byte, f *File) ([]int, error) {
+	c := &cursor{f: f, b: data}
+	version := c.u8()
+	rank := int(c.u8())
+	c.skip(1) // flags
+	switch version {
+	case 1:
+		c.skip(5)
+	case 2:
+		if typ := c.u8(); typ == 2 {
+			// null dataspace
+			return []int{0}, c.err
+		}
+	default:
+		return nil, errors.Errorf("hdf5: unsupported dataspace message version %d", version)
+	}
+	dims := make([]int, rank)
+	for i := range dims {
+		dims[i] = int(c.length())
+		if dims[i] < 0 {
+			return nil, errors.New("hdf5: corrupt dataspace")
+		}
+	}
+	return dims, c.err
+}
+
+// Value is the value of a dataset or an attribute.
+type Value struct {
+	// Dims are the dimensions of the value, none for a scalar. The elements are in row-major
+	// order.
+	Dims []int
+
+	f    *File
+	dt   *datatype
+	data []byte
+}
+
+// Len returns the number of the elements of the value.
+func (v *Value) Len() int {
+	n := 1
+	for _, d := range v.Dims {
+		n *= d
+	}
+	return n
+}
+
+// Floats returns the elements of a numeric value.
+func (v *Value) Floats() ([]float64, error) {
+	n, size := v.Len(), v.dt.size
+	var order binary.ByteOrder = binary.LittleEndian
+	if v.dt.bigEndian {
+		order = binary.BigEndian
+	}
+	x := make([]float64, n)
+	for i := range x {
+		p := v.data[i*size : (i+1)*size]
+		switch {
+		case v.dt.class == classFloat && size == 4:
+			x[i] = float64(math.Float32frombits(order.Uint32(p)))
+		case v.dt.class == classFloat && size == 8:
+			x[i] = math.Float64frombits(order.Uint64(p))
+		case v.dt.class == classFixed && size > 0 && size <= 8:
+			var u uint64
+			for j := range p {
+				k := j
+				if v.dt.bigEndian {
+					k = size - 1 - j
+				}
+				u |= uint64(p[k]) << (8 * uint(j))
+			}
+			if shift := uint(64 - 8*size); v.dt.signed {
+				x[i] = float64(int64(u<<shift) >> shift)
+			} else {
+				x[i] = float64(u)
+			}
+		default:
+			return nil, errors.Errorf("hdf5: datatype class %d of size %d isn't a supported number", v.dt.class, size)
+		}
+	}
+	return x, nil
+}
+
+// Strings returns the elements of a string value.
+func (v *Value) Strings() ([]string, error) {
+	n, size := v.Len(), v.dt.size
+	s := make([]string, n)
+	for i := range s {
+		p := v.data[i*size : (i+1)*size]
+		switch {
+		case v.dt.class == classString:
+			s[i] = strings.TrimRight(strings.SplitN(string(p), "\x00", 2)[0], " ")
+		case v.dt.class == classVLen && v.dt.vlenStr:
+			c := &cursor{f: v.f, b: p}
+			length := int(c.u32())
+			addr := c.addr()
+			index := c.u32()
+			if c.err != nil {
+				return nil, c.err
+			}
+			if length == 0 {
+				continue
+			}
+			obj, err := v.f.globalHeap(addr, index)
+			if err != nil {
+				return nil, err
+			}
+			if length > len(obj) {
+				return nil, errors.New("hdf5: corrupt variable length string")
+			}
+			s[i] = string(obj[:length])
+		default:
+			return nil, errors.Errorf("hdf5: datatype class %d isn't a string", v.dt.class)
+		}
+	}
+	return s, nil
+}
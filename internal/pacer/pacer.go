@@ -0,0 +1,42 @@
+// Package pacer keeps producers and consumers of audio in real time, as an audio device would.
+package pacer
+
+import (
+	"time"
+
+	"github.com/brotholo/beep"
+)
+
+// Pacer blocks the producer or consumer of audio data to keep it in real time.
+type Pacer struct {
+	sampleRate beep.SampleRate
+	ahead      int // number of samples the caller may be ahead of real time
+	start      time.Time
+	done       int
+}
+
+// New returns a Pacer which lets the caller be at most bufferSize samples ahead of real time.
+func New(sampleRate beep.SampleRate, bufferSize int) Pacer {
+	return Pacer{sampleRate: sampleRate, ahead: bufferSize}
+}
+
+// Wait accounts for n newly processed samples and blocks until the caller is at most one buffer
+// ahead of real time.
+func (p *Pacer) Wait(n int) {
+	now := time.Now()
+	if p.start.IsZero() {
+		p.start = now
+	}
+	due := p.start.Add(p.sampleRate.D(p.done - p.ahead))
+	if now.Sub(due) > p.sampleRate.D(p.ahead)*4 {
+		// we fell far behind (the process was suspended or the caller was too slow), start over
+		// instead of catching up with a burst
+		p.start = now
+		p.done = 0
+	}
+	p.done += n
+	due = p.start.Add(p.sampleRate.D(p.done - p.ahead))
+	if d := time.Until(due); d > 0 {
+		time.Sleep(d)
+	}
+}
@@ -0,0 +1,104 @@
+// Package sampleconv converts single sample values between float64 and their integer and IEEE 754
+// representations, shared by beep.Format and the codecs.
+package sampleconv
+
+import (
+	"fmt"
+	"math"
+)
+
+// FloatToSigned returns the two's complement integer of precision bytes representing x, which
+// must be within [-1, +1].
+func FloatToSigned(precision int, x float64) uint64 {
+	if x < 0 {
+		compl := uint64(-x * (math.Exp2(float64(precision)*8-1) - 1))
+		return uint64(1<<uint(precision*8)) - compl
+	}
+	return uint64(x * (math.Exp2(float64(precision)*8-1) - 1))
+}
+
+// FloatToUnsigned returns the offset binary integer of precision bytes representing x, which must
+// be within [-1, +1].
+func FloatToUnsigned(precision int, x float64) uint64 {
+	return uint64((x + 1) / 2 * (math.Exp2(float64(precision)*8) - 1))
+}
+
+// FloatToIEEE returns the bits of x as a float32 if precision is 4, or a float64 if it's 8. It
+// panics on other precisions.
+func FloatToIEEE(precision int, x float64) uint64 {
+	switch precision {
+	case 4:
+		return uint64(math.Float32bits(float32(x)))
+	case 8:
+		return math.Float64bits(x)
+	default:
+		panic(fmt.Errorf("format: invalid float precision: %d", precision))
+	}
+}
+
+// SignedToFloat is the inverse of FloatToSigned.
+func SignedToFloat(precision int, xUint64 uint64) float64 {
+	if xUint64 >= 1<<uint(precision*8-1) {
+		compl := 1<<uint(precision*8) - xUint64
+		return -float64(int64(compl)) / (math.Exp2(float64(precision)*8-1) - 1)
+	}
+	return float64(int64(xUint64)) / (math.Exp2(float64(precision)*8-1) - 1)
+}
+
+// UnsignedToFloat is the inverse of FloatToUnsigned.
+func UnsignedToFloat(precision int, xUint64 uint64) float64 {
+	return float64(xUint64)/(math.Exp2(float64(precision)*8)-1)*2 - 1
+}
+
+// IEEEToFloat is the inverse of FloatToIEEE.
+func IEEEToFloat(precision int, xUint64 uint64) float64 {
+	switch precision {
+	case 4:
+		return float64(math.Float32frombits(uint32(xUint64)))
+	case 8:
+		return math.Float64frombits(xUint64)
+	default:
+		panic(fmt.Errorf("format: invalid float precision: %d", precision))
+	}
+}
+
+// PutUint stores the lowest len(p) bytes of x in p, in the little-endian or big-endian byte order.
+func PutUint(p []byte, x uint64, bigEndian bool) {
+	if bigEndian {
+		for i := len(p) - 1; i >= 0; i-- {
+			p[i] = byte(x)
+			x >>= 8
+		}
+		return
+	}
+	for i := range p {
+		p[i] = byte(x)
+		x >>= 8
+	}
+}
+
+// Uint is the inverse of PutUint.
+func Uint(p []byte, bigEndian bool) uint64 {
+	var x uint64
+	if bigEndian {
+		for _, b := range p {
+			x = x<<8 | uint64(b)
+		}
+		return x
+	}
+	for i := len(p) - 1; i >= 0; i-- {
+		x = x<<8 | uint64(p[i])
+	}
+	return x
+}
+
+// Norm clips x to [-1, +1].
+func Norm(x float64) float64 {
+	if x < -1 {
+		return -1
+	}
+	if x > +1 {
+		return +1
+	}
+	return x
+}
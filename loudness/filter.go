@@ -0,0 +1,141 @@
+package loudness
+
+import (
+	"math"
+
+	"github.com/brotholo/beep"
+)
+
+// biquad is a biquad filter in the transposed direct form II, with coefficients normalized by a0.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting is the K-weighting filter of BS.1770: a high shelf modeling the acoustic effect of
+// the head, followed by the RLB high-pass. The coefficients are derived for any sample rate from
+// the analog prototypes of the filters given for 48 kHz.
+type kWeighting struct {
+	shelf, highpass biquad
+}
+
+func newKWeighting(sr beep.SampleRate) kWeighting {
+	fs := float64(sr)
+
+	f0, g, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highpass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return kWeighting{shelf: shelf, highpass: highpass}
+}
+
+func (k *kWeighting) process(x float64) float64 {
+	return k.highpass.process(k.shelf.process(x))
+}
+
+const (
+	truePeakTaps = 12 // per phase
+	truePeakHalf = truePeakTaps / 2
+)
+
+// truePeak measures the true peak by oversampling the signal with a windowed sinc interpolator,
+// 4 times below 96 kHz and 2 times below 192 kHz, as recommended by BS.1770.
+type truePeak struct {
+	phases  [][truePeakTaps]float64
+	history [2][2 * truePeakTaps]float64 // twice, so the last taps are contiguous
+	pos     int
+	max     float64
+}
+
+func newTruePeak(sr beep.SampleRate) truePeak {
+	factor := 1
+	switch {
+	case sr < 96000:
+		factor = 4
+	case sr < 192000:
+		factor = 2
+	}
+	tp := truePeak{phases: make([][truePeakTaps]float64, factor)}
+	for p := range tp.phases {
+		for k := range tp.phases[p] {
+			// tap of the sample k samples back for the point p/factor samples after the sample
+			// truePeakHalf samples back
+			t := float64(k-truePeakHalf) + float64(p)/float64(factor)
+			w := 0.5 * (1 + math.Cos(math.Pi*t/truePeakHalf))
+			tp.phases[p][k] = sinc(t) * w
+		}
+	}
+	return tp
+}
+
+func (tp *truePeak) write(samples [][2]float64) {
+	for _, x := range samples {
+		tp.push(x)
+		for c := range x {
+			tp.max = math.Max(tp.max, math.Max(math.Abs(x[c]), tp.between(c)))
+		}
+	}
+}
+
+// push adds a sample to the history.
+func (tp *truePeak) push(x [2]float64) {
+	for c := range x {
+		tp.history[c][tp.pos] = x[c]
+		tp.history[c][tp.pos+truePeakTaps] = x[c]
+	}
+	tp.pos = (tp.pos + 1) % truePeakTaps
+}
+
+// delayed returns the sample of the channel c truePeakHalf samples back.
+func (tp *truePeak) delayed(c int) float64 {
+	return tp.history[c][tp.pos+truePeakTaps-1-truePeakHalf]
+}
+
+// between returns the highest absolute value of the points interpolated in the channel c between
+// the samples truePeakHalf and truePeakHalf-1 samples back.
+func (tp *truePeak) between(c int) float64 {
+	recent := tp.history[c][tp.pos : tp.pos+truePeakTaps] // the newest sample last
+	var peak float64
+	for p := 1; p < len(tp.phases); p++ {
+		var y float64
+		for k, h := range tp.phases[p] {
+			y += h * recent[truePeakTaps-1-k]
+		}
+		peak = math.Max(peak, math.Abs(y))
+	}
+	return peak
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
@@ -0,0 +1,142 @@
+package loudness_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/loudness"
+)
+
+// sine returns a sine wave of the frequency and the amplitude in dBFS in both channels.
+func sine(sr beep.SampleRate, freq, db, phase float64, seconds float64) [][2]float64 {
+	amp := math.Pow(10, db/20)
+	data := make([][2]float64, int(seconds*float64(sr)))
+	for i := range data {
+		v := amp * math.Sin(2*math.Pi*freq*float64(i)/float64(sr)+phase)
+		data[i] = [2]float64{v, v}
+	}
+	return data
+}
+
+func TestAnalyze(t *testing.T) {
+	for _, sr := range []beep.SampleRate{44100, 48000} {
+		// a 1 kHz sine in both channels at -23 dBFS is -23 LUFS, see EBU Tech 3341
+		r, err := loudness.Analyze(beeptest.Samples(sine(sr, 1000, -23, 0, 10)), sr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, v := range map[string]float64{
+			"integrated":     r.Integrated,
+			"max momentary":  r.MaxMomentary,
+			"max short-term": r.MaxShortTerm,
+			"true peak":      r.TruePeak,
+		} {
+			if math.Abs(v+23) > 0.1 {
+				t.Errorf("%v Hz: %s is %.2f, want -23", sr, name, v)
+			}
+		}
+		if r.Range > 0.1 {
+			t.Errorf("%v Hz: loudness range of a steady sine is %.2f LU", sr, r.Range)
+		}
+	}
+}
+
+func TestMeterGating(t *testing.T) {
+	const sr = beep.SampleRate(48000)
+	m := loudness.NewMeter(sr)
+	if got := m.Integrated(); !math.IsInf(got, -1) {
+		t.Errorf("integrated loudness of nothing is %v", got)
+	}
+
+	m.Write(sine(sr, 1000, -30, 0, 10))
+	m.Write(sine(sr, 1000, -20, 0, 10))
+	if got := m.ShortTerm(); math.Abs(got+20) > 0.1 {
+		t.Errorf("short-term loudness is %.2f, want -20", got)
+	}
+	if got := m.Range(); math.Abs(got-10) > 0.5 {
+		t.Errorf("loudness range is %.2f, want 10", got)
+	}
+
+	// silence is gated out of the integrated loudness
+	before := m.Integrated()
+	m.Write(make([][2]float64, 10*int(sr)))
+	if got := m.Integrated(); math.Abs(got-before) > 0.05 {
+		t.Errorf("integrated loudness changed from %.2f to %.2f by silence", before, got)
+	}
+	if got := m.Momentary(); !math.IsInf(got, -1) {
+		t.Errorf("momentary loudness of silence is %v", got)
+	}
+}
+
+func TestTruePeak(t *testing.T) {
+	const sr = beep.SampleRate(48000)
+	// a quarter of the sample rate with the samples 45° off the peaks
+	data := sine(sr, float64(sr)/4, -6, math.Pi/4, 1)
+	m := loudness.NewMeter(sr)
+	m.Write(data)
+	if got := m.TruePeak(); math.Abs(got+6) > 0.2 {
+		t.Errorf("true peak is %.2f dBTP, want -6", got)
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	const sr = beep.SampleRate(48000)
+	data := sine(sr, 1000, -30, 0, 5)
+
+	n := loudness.Normalization{Target: loudness.EBUR128}
+	r, _ := loudness.Analyze(beeptest.Samples(data), sr)
+	gain := n.Gain(r.Integrated, r.TruePeak)
+	if math.Abs(gain-7) > 0.1 {
+		t.Errorf("gain is %.2f dB, want 7 dB", gain)
+	}
+	normalized, _ := loudness.Analyze(n.Apply(beeptest.Samples(data), sr, gain), sr)
+	if math.Abs(normalized.Integrated-loudness.EBUR128) > 0.1 {
+		t.Errorf("normalized loudness is %.2f, want %v", normalized.Integrated, loudness.EBUR128)
+	}
+
+	// the peak of the sine would exceed the ceiling
+	n = loudness.Normalization{Target: 0, Ceiling: -1, Peaks: loudness.ReduceGain}
+	if gain := n.Gain(r.Integrated, r.TruePeak); math.Abs(gain-29) > 0.1 {
+		t.Errorf("reduced gain is %.2f dB, want 29 dB", gain)
+	}
+	n.Peaks = loudness.Limit
+	limited, _ := loudness.Analyze(n.Apply(beeptest.Samples(data), sr, n.Gain(r.Integrated, r.TruePeak)), sr)
+	if limited.TruePeak > -0.9 {
+		t.Errorf("limited true peak is %.2f dBTP, want at most -1", limited.TruePeak)
+	}
+
+	// the true peaks of a quarter of the sample rate are 3 dB above the samples 45° off the peaks
+	data = sine(sr, float64(sr)/4, -20, math.Pi/4, 1)
+	limited, _ = loudness.Analyze(n.Apply(beeptest.Samples(data), sr, 20), sr)
+	if limited.TruePeak > -0.9 || limited.TruePeak < -1.5 {
+		t.Errorf("limited true peak between the samples is %.2f dBTP, want -1", limited.TruePeak)
+	}
+
+	if gain := n.Gain(loudness.Silence, loudness.Silence); gain != 0 {
+		t.Errorf("gain of silence is %v", gain)
+	}
+}
+
+func TestParseReplayGain(t *testing.T) {
+	rg, ok := loudness.ParseReplayGain([][2]string{
+		{"TITLE", "Song"},
+		{"replaygain_track_gain", "-6.50 dB"},
+		{"REPLAYGAIN_TRACK_PEAK", "0.5"},
+		{"REPLAYGAIN_ALBUM_GAIN", "+1.25 dB"},
+	})
+	if !ok || !rg.HasTrack || !rg.HasAlbum || rg.TrackGain != -6.5 || rg.TrackPeak != 0.5 || rg.AlbumGain != 1.25 {
+		t.Fatalf("parsed %+v, %v", rg, ok)
+	}
+	if l, p := rg.Loudness(false); l != -11.5 || math.Abs(p+6.02) > 0.01 {
+		t.Errorf("track loudness is %v LUFS with peak %v dB", l, p)
+	}
+	if l, p := rg.Loudness(true); l != -19.25 || p != 0 {
+		t.Errorf("album loudness is %v LUFS with peak %v dB", l, p)
+	}
+
+	if _, ok := loudness.ReadReplayGain(beep.Silence(1)); ok {
+		t.Error("read ReplayGain from a Streamer without tags")
+	}
+}
@@ -0,0 +1,240 @@
+// Package loudness measures the loudness of audio per ITU-R BS.1770 and EBU R128, and normalizes
+// it to a target loudness.
+package loudness
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/brotholo/beep"
+	"github.com/pkg/errors"
+)
+
+// Silence is the loudness in LUFS reported for silence or when there isn't enough audio to
+// measure.
+var Silence = math.Inf(-1)
+
+const (
+	absoluteGate  = -70 // LUFS
+	relativeGate  = -10 // LU, integrated loudness
+	rangeGate     = -20 // LU, loudness range
+	momentaryLen  = 4   // blocks of 100ms
+	shortTermLen  = 30  // blocks of 100ms
+	rangeLowPerc  = 0.10
+	rangeHighPerc = 0.95
+)
+
+// Result is the loudness of a whole program.
+type Result struct {
+	// Integrated is the gated loudness of the whole program in LUFS.
+	Integrated float64
+
+	// Range is the loudness range (LRA) in LU, the spread of the short-term loudness.
+	Range float64
+
+	// MaxMomentary and MaxShortTerm are the highest momentary and short-term loudness in LUFS.
+	MaxMomentary float64
+	MaxShortTerm float64
+
+	// TruePeak is the highest peak of the reconstructed signal in dBTP, including the peaks
+	// between the samples.
+	TruePeak float64
+}
+
+// Meter measures the loudness of the audio written to it per ITU-R BS.1770-4 and EBU Tech 3341
+// and 3342. Both channels are weighted equally.
+//
+// The momentary and short-term loudness are measured over the last 400ms and 3s. The integrated
+// loudness and the loudness range are measured over all of the audio written so far.
+type Meter struct {
+	sr        beep.SampleRate
+	blockSize int // samples in a block of 100ms
+
+	filters [2]kWeighting
+	peak    truePeak
+
+	sum     float64   // sum of the squares of the current block
+	filled  int       // samples in the current block
+	blocks  []float64 // mean squares of the recent blocks, ring of shortTermLen
+	nblocks int       // number of complete blocks
+
+	momentary []float64 // energies of all momentary windows, for the integrated loudness
+	shortTerm []float64 // energies of all short-term windows, for the loudness range
+}
+
+// NewMeter returns a Meter of audio at the sample rate sr.
+func NewMeter(sr beep.SampleRate) *Meter {
+	blockSize := sr.N(100 * time.Millisecond)
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	return &Meter{
+		sr:        sr,
+		blockSize: blockSize,
+		filters:   [2]kWeighting{newKWeighting(sr), newKWeighting(sr)},
+		peak:      newTruePeak(sr),
+		blocks:    make([]float64, shortTermLen),
+	}
+}
+
+// Write measures the samples.
+func (m *Meter) Write(samples [][2]float64) {
+	m.peak.write(samples)
+	for _, x := range samples {
+		for c := range x {
+			y := m.filters[c].process(x[c])
+			m.sum += y * y
+		}
+		m.filled++
+		if m.filled == m.blockSize {
+			m.blocks[m.nblocks%shortTermLen] = m.sum / float64(m.blockSize)
+			m.nblocks++
+			m.sum, m.filled = 0, 0
+			if e, ok := m.window(momentaryLen); ok {
+				m.momentary = append(m.momentary, e)
+			}
+			if e, ok := m.window(shortTermLen); ok {
+				m.shortTerm = append(m.shortTerm, e)
+			}
+		}
+	}
+}
+
+// window returns the energy of the last n blocks, or false if there aren't enough blocks yet.
+func (m *Meter) window(n int) (float64, bool) {
+	if m.nblocks < n {
+		return 0, false
+	}
+	var e float64
+	for i := m.nblocks - n; i < m.nblocks; i++ {
+		e += m.blocks[i%shortTermLen]
+	}
+	return e / float64(n), true
+}
+
+// Momentary returns the loudness of the last 400ms in LUFS.
+func (m *Meter) Momentary() float64 {
+	e, _ := m.window(momentaryLen)
+	return toLUFS(e)
+}
+
+// ShortTerm returns the loudness of the last 3s in LUFS.
+func (m *Meter) ShortTerm() float64 {
+	e, _ := m.window(shortTermLen)
+	return toLUFS(e)
+}
+
+// Integrated returns the gated loudness of all the audio written so far in LUFS.
+func (m *Meter) Integrated() float64 {
+	gated := gate(m.momentary, relativeGate)
+	return toLUFS(mean(gated))
+}
+
+// Range returns the loudness range (LRA) of all the audio written so far in LU.
+func (m *Meter) Range() float64 {
+	gated := gate(m.shortTerm, rangeGate)
+	if len(gated) == 0 {
+		return 0
+	}
+	loudness := make([]float64, len(gated))
+	for i, e := range gated {
+		loudness[i] = toLUFS(e)
+	}
+	sort.Float64s(loudness)
+	return percentile(loudness, rangeHighPerc) - percentile(loudness, rangeLowPerc)
+}
+
+// TruePeak returns the highest true peak of all the audio written so far in dBTP.
+func (m *Meter) TruePeak() float64 {
+	return 20 * math.Log10(m.peak.max)
+}
+
+// Result returns the measurements of all the audio written so far.
+func (m *Meter) Result() Result {
+	r := Result{
+		Integrated:   m.Integrated(),
+		Range:        m.Range(),
+		MaxMomentary: Silence,
+		MaxShortTerm: Silence,
+		TruePeak:     m.TruePeak(),
+	}
+	for _, e := range m.momentary {
+		r.MaxMomentary = math.Max(r.MaxMomentary, toLUFS(e))
+	}
+	for _, e := range m.shortTerm {
+		r.MaxShortTerm = math.Max(r.MaxShortTerm, toLUFS(e))
+	}
+	return r
+}
+
+// Analyze streams s to the end and returns its loudness. The sample rate sr must match that of
+// the Streamer.
+func Analyze(s beep.Streamer, sr beep.SampleRate) (Result, error) {
+	m := NewMeter(sr)
+	buf := make([][2]float64, 512)
+	for {
+		n, ok := s.Stream(buf)
+		m.Write(buf[:n])
+		if !ok {
+			break
+		}
+	}
+	if err := s.Err(); err != nil {
+		return Result{}, errors.Wrap(err, "loudness")
+	}
+	return m.Result(), nil
+}
+
+// gate returns the energies above the absolute gate and above the gate relative to the mean of
+// those.
+func gate(energies []float64, relative float64) []float64 {
+	var abs []float64
+	for _, e := range energies {
+		if toLUFS(e) > absoluteGate {
+			abs = append(abs, e)
+		}
+	}
+	if len(abs) == 0 {
+		return nil
+	}
+	threshold := toLUFS(mean(abs)) + relative
+	var rel []float64
+	for _, e := range abs {
+		if toLUFS(e) > threshold {
+			rel = append(rel, e)
+		}
+	}
+	return rel
+}
+
+func mean(x []float64) float64 {
+	if len(x) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range x {
+		sum += v
+	}
+	return sum / float64(len(x))
+}
+
+// percentile returns the p-th percentile of the sorted values, interpolated linearly.
+func percentile(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	frac := pos - float64(i)
+	return sorted[i]*(1-frac) + sorted[i+1]*frac
+}
+
+// toLUFS converts the energy of a block, the sum of the mean squares of the K-weighted channels,
+// to loudness.
+func toLUFS(e float64) float64 {
+	if e <= 0 {
+		return Silence
+	}
+	return -0.691 + 10*math.Log10(e)
+}
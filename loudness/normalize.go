@@ -0,0 +1,156 @@
+package loudness
+
+import (
+	"math"
+	"time"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/effects"
+)
+
+// Common target loudness in LUFS.
+const (
+	// EBUR128 is the target of EBU R128 for broadcast.
+	EBUR128 = -23.0
+
+	// ReplayGainReference is the reference loudness of ReplayGain 2.0.
+	ReplayGainReference = -18.0
+)
+
+// PeakControl is how a Normalization keeps the peaks within its ceiling.
+type PeakControl int
+
+const (
+	// NoPeakControl applies the gain regardless of the peaks, which may clip.
+	NoPeakControl PeakControl = iota
+
+	// ReduceGain reduces the gain so that the true peak stays within the ceiling. The audio may
+	// end up quieter than the target.
+	ReduceGain
+
+	// Limit applies the full gain and limits the true peaks above the ceiling by a look-ahead
+	// limiter.
+	Limit
+)
+
+// limiterLookahead and limiterRelease are the times of the limiter of Normalization.
+const (
+	limiterLookahead = 2 * time.Millisecond
+	limiterRelease   = 100 * time.Millisecond
+)
+
+// Normalization normalizes audio to a target loudness.
+//
+//	r, err := loudness.Analyze(s, format.SampleRate)
+//	// ...
+//	n := loudness.Normalization{Target: loudness.EBUR128, Ceiling: -1, Peaks: loudness.Limit}
+//	normalized := n.Apply(s, format.SampleRate, n.Gain(r.Integrated, r.TruePeak))
+type Normalization struct {
+	// Target is the loudness to normalize to in LUFS.
+	Target float64
+
+	// Ceiling is the highest allowed peak in dBTP, for example -1.
+	Ceiling float64
+
+	// Peaks is how the peaks are kept within the Ceiling.
+	Peaks PeakControl
+}
+
+// Gain returns the gain in dB which normalizes audio of the loudness in LUFS and the true peak in
+// dBTP to the target. The gain is 0 for silent audio.
+func (n Normalization) Gain(loudness, truePeak float64) float64 {
+	if math.IsInf(loudness, -1) || math.IsNaN(loudness) {
+		return 0
+	}
+	gain := n.Target - loudness
+	if n.Peaks == ReduceGain && truePeak+gain > n.Ceiling {
+		gain = n.Ceiling - truePeak
+	}
+	return gain
+}
+
+// Apply returns s amplified by gain in dB, limited to the Ceiling if Peaks is Limit. The limiter
+// detects the true peaks like Meter, so that the peaks between the samples stay within the Ceiling
+// too. It delays the audio by a few milliseconds.
+func (n Normalization) Apply(s beep.Streamer, sr beep.SampleRate, gain float64) beep.Streamer {
+	s = &effects.Gain{Streamer: s, Gain: math.Pow(10, gain/20) - 1}
+	if n.Peaks == Limit {
+		d := &truePeakDetector{s: s, tp: newTruePeak(sr), tail: truePeakHalf}
+		s = effects.NewLookaheadLimiter(d, sr, effects.Dynamics{
+			Threshold: n.Ceiling,
+			Attack:    limiterLookahead,
+			Release:   limiterRelease,
+			Sidechain: beep.StreamerFunc(d.streamPeaks),
+		})
+	}
+	return s
+}
+
+// truePeakDetector delays s by truePeakHalf samples and detects the true peak around each delayed
+// sample, so that the limiter can be driven by the true peaks through its sidechain.
+type truePeakDetector struct {
+	s       beep.Streamer
+	tp      truePeak
+	prev    [2]float64   // peaks between the last delayed sample and the one before
+	peaks   [][2]float64 // peaks of the last streamed samples
+	read    int          // peaks already streamed by streamPeaks
+	drained bool
+	tail    int // delayed samples left to stream after s is drained
+}
+
+func (d *truePeakDetector) Stream(samples [][2]float64) (n int, ok bool) {
+	if len(samples) == 0 {
+		if d.tail > 0 {
+			return 0, true
+		}
+		if d.drained {
+			return 0, false
+		}
+		return d.s.Stream(samples)
+	}
+
+	if !d.drained {
+		n, _ = d.s.Stream(samples)
+		if n < len(samples) {
+			d.drained = true
+			if d.s.Err() != nil {
+				d.tail = 0
+			}
+		}
+	}
+	// flush the delayed samples after s is drained
+	m := len(samples) - n
+	if m > d.tail {
+		m = d.tail
+	}
+	for i := n; i < n+m; i++ {
+		samples[i] = [2]float64{}
+	}
+	d.tail -= m
+	n += m
+
+	d.peaks, d.read = d.peaks[:0], 0
+	for i := range samples[:n] {
+		d.tp.push(samples[i])
+		var peak [2]float64
+		for c := range peak {
+			x, next := d.tp.delayed(c), d.tp.between(c)
+			peak[c] = math.Max(math.Abs(x), math.Max(d.prev[c], next))
+			d.prev[c] = next
+			samples[i][c] = x
+		}
+		d.peaks = append(d.peaks, peak)
+	}
+	return n, n > 0
+}
+
+func (d *truePeakDetector) Err() error {
+	return d.s.Err()
+}
+
+// streamPeaks streams the peaks detected by the last call to Stream.
+func (d *truePeakDetector) streamPeaks(samples [][2]float64) (n int, ok bool) {
+	n = copy(samples, d.peaks[d.read:])
+	d.read += n
+	return n, n > 0
+}
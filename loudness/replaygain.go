@@ -0,0 +1,71 @@
+package loudness
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/brotholo/beep"
+)
+
+// Tagger is implemented by decoded Streamers which expose the tags of the file as name/value
+// pairs, such as those of the vorbis and flac packages.
+type Tagger interface {
+	Tags() [][2]string
+}
+
+// ReplayGain are the ReplayGain tags of a file. The gains are in dB relative to
+// ReplayGainReference, the peaks are linear sample peaks, 1 is full scale.
+type ReplayGain struct {
+	TrackGain, TrackPeak float64
+	AlbumGain, AlbumPeak float64
+
+	HasTrack, HasAlbum bool
+}
+
+// ReadReplayGain reads the ReplayGain tags of a decoded Streamer. It reports false if s doesn't
+// expose its tags or has no ReplayGain tags.
+func ReadReplayGain(s beep.Streamer) (ReplayGain, bool) {
+	t, ok := s.(Tagger)
+	if !ok {
+		return ReplayGain{}, false
+	}
+	return ParseReplayGain(t.Tags())
+}
+
+// ParseReplayGain parses the ReplayGain tags REPLAYGAIN_TRACK_GAIN, REPLAYGAIN_TRACK_PEAK,
+// REPLAYGAIN_ALBUM_GAIN and REPLAYGAIN_ALBUM_PEAK. The names are case insensitive. It reports false
+// if neither a track nor an album gain is found.
+func ParseReplayGain(tags [][2]string) (ReplayGain, bool) {
+	rg := ReplayGain{TrackPeak: 1, AlbumPeak: 1}
+	for _, tag := range tags {
+		value := strings.TrimSpace(tag[1])
+		value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(value, "dB"), "DB"))
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		switch strings.ToUpper(tag[0]) {
+		case "REPLAYGAIN_TRACK_GAIN":
+			rg.TrackGain, rg.HasTrack = v, true
+		case "REPLAYGAIN_TRACK_PEAK":
+			rg.TrackPeak = v
+		case "REPLAYGAIN_ALBUM_GAIN":
+			rg.AlbumGain, rg.HasAlbum = v, true
+		case "REPLAYGAIN_ALBUM_PEAK":
+			rg.AlbumPeak = v
+		}
+	}
+	return rg, rg.HasTrack || rg.HasAlbum
+}
+
+// Loudness returns the loudness in LUFS and the peak in dB implied by the track or the album
+// tags, which can be passed to Normalization.Gain. It falls back to the other one if the
+// requested tags are missing.
+func (rg ReplayGain) Loudness(album bool) (loudness, peak float64) {
+	gain, p := rg.TrackGain, rg.TrackPeak
+	if album && rg.HasAlbum || !rg.HasTrack {
+		gain, p = rg.AlbumGain, rg.AlbumPeak
+	}
+	return ReplayGainReference - gain, 20 * math.Log10(p)
+}
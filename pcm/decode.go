@@ -0,0 +1,150 @@
+package pcm
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/brotholo/beep"
+	"github.com/pkg/errors"
+)
+
+// Decode takes a Reader containing raw PCM audio data laid out according to format and enc and
+// returns a StreamSeekCloser, which streams that audio.
+//
+// The data starts at the current position of r, so any header preceding it can be skipped before
+// calling Decode. If r is an io.Seeker, the length of the data is determined by seeking to its end
+// and Seek is supported. Otherwise, Len returns -1 until the end of the data is reached and Seek
+// returns an error.
+//
+// Audio with more than two channels is mixed down to stereo according to format.ChannelMask, see
+// beep.Format.Downmix. Use DecodeDownmix to specify a different Downmix. A trailing incomplete
+// frame is ignored.
+//
+// Do not close the supplied Reader, instead, use the Close method of the returned
+// StreamSeekCloser when you want to release the resources.
+func Decode(r io.Reader, format beep.Format, enc Encoding) (s beep.StreamSeekCloser, err error) {
+	return DecodeDownmix(r, format, enc, nil)
+}
+
+// DecodeDownmix is like Decode, but audio with more than two channels is mixed down to stereo
+// using the provided Downmix. The Downmix must have a row for each channel, otherwise an error is
+// returned. If downmix is nil, format.Downmix is used. Audio with one or two channels isn't mixed,
+// downmix is ignored for it.
+func DecodeDownmix(r io.Reader, format beep.Format, enc Encoding, downmix beep.Downmix) (s beep.StreamSeekCloser, err error) {
+	if err := enc.validate(format); err != nil {
+		return nil, err
+	}
+	d := &decoder{r: r, f: format, enc: enc, len: -1}
+	if format.NumChannels > 2 {
+		if downmix == nil {
+			downmix = format.Downmix()
+		}
+		if len(downmix) != format.NumChannels {
+			return nil, fmt.Errorf("pcm: downmix has %d rows, but the audio has %d channels", len(downmix), format.NumChannels)
+		}
+		d.downmix = downmix
+		d.frame = make([]float64, format.NumChannels)
+	}
+	if seeker, ok := r.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, errors.Wrap(err, "pcm")
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, errors.Wrap(err, "pcm")
+		}
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, errors.Wrap(err, "pcm")
+		}
+		d.seeker = seeker
+		d.start = start
+		d.len = int((end - start) / int64(format.Width()))
+	}
+	return d, nil
+}
+
+type decoder struct {
+	r       io.Reader
+	seeker  io.Seeker
+	f       beep.Format
+	enc     Encoding
+	downmix beep.Downmix
+	frame   []float64
+	buf     []byte
+	start   int64
+	len     int
+	pos     int
+	err     error
+}
+
+func (d *decoder) Stream(samples [][2]float64) (n int, ok bool) {
+	if d.err != nil || (d.len >= 0 && d.pos >= d.len) {
+		return 0, false
+	}
+	if len(samples) == 0 {
+		return 0, true
+	}
+	width := d.f.Width()
+	if d.len >= 0 && len(samples) > d.len-d.pos {
+		samples = samples[:d.len-d.pos]
+	}
+	if cap(d.buf) < len(samples)*width {
+		d.buf = make([]byte, len(samples)*width)
+	}
+	p := d.buf[:len(samples)*width]
+	nb, err := io.ReadFull(d.r, p)
+	n = nb / width
+	for i := range samples[:n] {
+		samples[i] = d.enc.decodeFrame(d.f, d.downmix, d.frame, p[i*width:])
+	}
+	d.pos += n
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		// the data ended, next call reports drained
+		if d.len < 0 {
+			d.len = d.pos
+		}
+	case err != nil:
+		d.err = errors.Wrap(err, "pcm")
+		return 0, false
+	}
+	return n, n > 0
+}
+
+func (d *decoder) Err() error {
+	return d.err
+}
+
+func (d *decoder) Len() int {
+	return d.len
+}
+
+func (d *decoder) Position() int {
+	return d.pos
+}
+
+func (d *decoder) Seek(p int) error {
+	if d.seeker == nil {
+		return errors.New("pcm: seek: resource is not io.Seeker")
+	}
+	if p < 0 || d.len < p {
+		return fmt.Errorf("pcm: seek position %v out of range [%v, %v]", p, 0, d.len)
+	}
+	_, err := d.seeker.Seek(d.start+int64(p)*int64(d.f.Width()), io.SeekStart)
+	if err != nil {
+		return errors.Wrap(err, "pcm: seek error")
+	}
+	d.pos = p
+	return nil
+}
+
+func (d *decoder) Close() error {
+	if closer, ok := d.r.(io.Closer); ok {
+		err := closer.Close()
+		if err != nil {
+			return errors.Wrap(err, "pcm")
+		}
+	}
+	return nil
+}
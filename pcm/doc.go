@@ -0,0 +1,6 @@
+// Package pcm implements decoding and encoding of raw, headerless PCM audio data.
+//
+// Raw PCM carries no information about its own layout, so the caller supplies a beep.Format along
+// with an Encoding describing the byte order and the sample type. This is the format produced, for
+// example, by `arecord -t raw` or `ffmpeg -f s16le`.
+package pcm
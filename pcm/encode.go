@@ -0,0 +1,49 @@
+package pcm
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/brotholo/beep"
+	"github.com/pkg/errors"
+)
+
+// Encode writes all audio streamed from s to w as raw PCM data laid out according to format and
+// enc. No header is written.
+//
+// If format.NumChannels is 1, both channels are mixed down to mono. Channels beyond the second
+// are filled with silence. Integer samples outside of [-1, +1] are clipped.
+func Encode(w io.Writer, s beep.Streamer, format beep.Format, enc Encoding) (err error) {
+	if err := enc.validate(format); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Wrap(err, "pcm")
+		}
+	}()
+
+	var (
+		bw      = bufio.NewWriter(w)
+		samples = make([][2]float64, 512)
+		buffer  = make([]byte, len(samples)*format.Width())
+		width   = format.Width()
+	)
+	for {
+		n, ok := s.Stream(samples)
+		if !ok {
+			break
+		}
+		for i, sample := range samples[:n] {
+			enc.encodeFrame(format, buffer[i*width:], sample)
+		}
+		if _, err := bw.Write(buffer[:n*width]); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,148 @@
+package pcm
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/internal/sampleconv"
+)
+
+// SampleType is the numeric representation of a single sample value.
+type SampleType int
+
+const (
+	// Signed samples are two's complement integers.
+	Signed SampleType = iota
+
+	// Unsigned samples are offset binary integers, the silence is at the middle of the range.
+	Unsigned
+
+	// Float samples are IEEE 754 floating point numbers in the range [-1, +1]. Only precisions 4
+	// (float32) and 8 (float64) are supported.
+	Float
+)
+
+// String returns the name of the sample type as used by tools such as ffmpeg.
+func (t SampleType) String() string {
+	switch t {
+	case Signed:
+		return "s"
+	case Unsigned:
+		return "u"
+	case Float:
+		return "f"
+	default:
+		return fmt.Sprintf("SampleType(%d)", int(t))
+	}
+}
+
+// Encoding describes how samples are stored in raw PCM data.
+type Encoding struct {
+	// ByteOrder is the byte order of a single sample value. It must be binary.LittleEndian or
+	// binary.BigEndian. A nil ByteOrder means binary.LittleEndian.
+	ByteOrder binary.ByteOrder
+
+	// Type is the numeric representation of the samples.
+	Type SampleType
+}
+
+// Common encodings, named after the corresponding ffmpeg formats. The precision (s16, s24, ...)
+// is given by beep.Format.Precision.
+var (
+	SignedLE   = Encoding{ByteOrder: binary.LittleEndian, Type: Signed}
+	SignedBE   = Encoding{ByteOrder: binary.BigEndian, Type: Signed}
+	UnsignedLE = Encoding{ByteOrder: binary.LittleEndian, Type: Unsigned}
+	UnsignedBE = Encoding{ByteOrder: binary.BigEndian, Type: Unsigned}
+	FloatLE    = Encoding{ByteOrder: binary.LittleEndian, Type: Float}
+	FloatBE    = Encoding{ByteOrder: binary.BigEndian, Type: Float}
+)
+
+func (e Encoding) bigEndian() bool {
+	return e.ByteOrder == binary.BigEndian
+}
+
+// validate checks that the combination of e and format is supported.
+func (e Encoding) validate(format beep.Format) error {
+	if format.NumChannels <= 0 {
+		return fmt.Errorf("pcm: invalid number of channels: %d", format.NumChannels)
+	}
+	if e.ByteOrder != nil && e.ByteOrder != binary.LittleEndian && e.ByteOrder != binary.BigEndian {
+		return fmt.Errorf("pcm: unsupported byte order: %v", e.ByteOrder)
+	}
+	switch e.Type {
+	case Signed, Unsigned:
+		if format.Precision < 1 || format.Precision > 4 {
+			return fmt.Errorf("pcm: unsupported precision for integer samples: %d", format.Precision)
+		}
+	case Float:
+		if format.Precision != 4 && format.Precision != 8 {
+			return fmt.Errorf("pcm: unsupported precision for float samples: %d", format.Precision)
+		}
+	default:
+		return fmt.Errorf("pcm: unsupported sample type: %v", e.Type)
+	}
+	return nil
+}
+
+// decodeValue decodes a single channel value stored in p[:precision].
+func (e Encoding) decodeValue(precision int, p []byte) float64 {
+	x := sampleconv.Uint(p[:precision], e.bigEndian())
+	switch e.Type {
+	case Float:
+		return sampleconv.IEEEToFloat(precision, x)
+	case Unsigned:
+		return sampleconv.UnsignedToFloat(precision, x)
+	default:
+		return sampleconv.SignedToFloat(precision, x)
+	}
+}
+
+// encodeValue encodes a single channel value into p[:precision]. Integer values are clipped to
+// [-1, +1].
+func (e Encoding) encodeValue(precision int, p []byte, v float64) {
+	var x uint64
+	switch e.Type {
+	case Float:
+		x = sampleconv.FloatToIEEE(precision, v)
+	case Unsigned:
+		x = sampleconv.FloatToUnsigned(precision, sampleconv.Norm(v))
+	default:
+		x = sampleconv.FloatToSigned(precision, sampleconv.Norm(v))
+	}
+	sampleconv.PutUint(p[:precision], x, e.bigEndian())
+}
+
+// decodeFrame decodes a single frame of format.Width() bytes from p. Frames with more than two
+// channels are decoded into frame and mixed down by downmix.
+func (e Encoding) decodeFrame(format beep.Format, downmix beep.Downmix, frame []float64, p []byte) (sample [2]float64) {
+	switch format.NumChannels {
+	case 1:
+		x := e.decodeValue(format.Precision, p)
+		return [2]float64{x, x}
+	case 2:
+		sample[0] = e.decodeValue(format.Precision, p)
+		sample[1] = e.decodeValue(format.Precision, p[format.Precision:])
+		return sample
+	default:
+		for c := range frame {
+			frame[c] = e.decodeValue(format.Precision, p[c*format.Precision:])
+		}
+		return downmix.Apply(frame)
+	}
+}
+
+// encodeFrame encodes a single frame of format.Width() bytes into p.
+func (e Encoding) encodeFrame(format beep.Format, p []byte, sample [2]float64) {
+	if format.NumChannels == 1 {
+		e.encodeValue(format.Precision, p, (sample[0]+sample[1])/2)
+		return
+	}
+	for c := 0; c < format.NumChannels; c++ {
+		var x float64
+		if c < len(sample) {
+			x = sample[c]
+		}
+		e.encodeValue(format.Precision, p[c*format.Precision:], x)
+	}
+}
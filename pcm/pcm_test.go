@@ -0,0 +1,166 @@
+package pcm_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/pcm"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, enc := range []pcm.Encoding{pcm.SignedLE, pcm.SignedBE, pcm.UnsignedLE, pcm.UnsignedBE, pcm.FloatLE, pcm.FloatBE} {
+		precisions := []int{1, 2, 3, 4}
+		if enc.Type == pcm.Float {
+			precisions = []int{4, 8}
+		}
+		for _, precision := range precisions {
+			for _, numChannels := range []int{1, 2, 3} {
+				format := beep.Format{SampleRate: 44100, NumChannels: numChannels, Precision: precision}
+
+				// the channels differ, so a wrong channel mapping shows
+				var data, want [][2]float64
+				for i := 0; i < 1000; i++ {
+					x := float64(i) / 1000
+					sample := [2]float64{x, 0.5 - x/4}
+					data = append(data, sample)
+					switch {
+					case numChannels == 1:
+						mono := (sample[0] + sample[1]) / 2
+						sample = [2]float64{mono, mono}
+					case numChannels > 2:
+						// the other channels are encoded silent
+						sample = format.Downmix().Apply([]float64{sample[0], sample[1], 0})
+					}
+					want = append(want, sample)
+				}
+
+				var buf bytes.Buffer
+				if err := pcm.Encode(&buf, beeptest.Samples(data), format, enc); err != nil {
+					t.Fatal(err)
+				}
+				if buf.Len() != len(want)*format.Width() {
+					t.Fatalf("%v%d: encoded %d bytes, want %d", enc.Type, precision*8, buf.Len(), len(want)*format.Width())
+				}
+
+				s, err := pcm.Decode(bytes.NewReader(buf.Bytes()), format, enc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tolerance := 2 / (math.Exp2(float64(precision)*8) - 2)
+				if enc.Type == pcm.Float {
+					tolerance = 1e-7
+				}
+				if numChannels > 2 {
+					// the quantization errors of all channels add up in the downmix
+					gains := 0.0
+					for _, row := range format.Downmix() {
+						gains += math.Max(math.Abs(row[0]), math.Abs(row[1]))
+					}
+					tolerance *= gains
+				}
+				beeptest.AssertStreamer(t, s, want, tolerance)
+			}
+		}
+	}
+}
+
+func TestDecodeKnownData(t *testing.T) {
+	format := beep.Format{SampleRate: 8000, NumChannels: 2, Precision: 2}
+	data := []int16{0, 0, math.MaxInt16, -math.MaxInt16, -math.MaxInt16, math.MaxInt16}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		var buf bytes.Buffer
+		binary.Write(&buf, order, data)
+		s, err := pcm.Decode(&buf, format, pcm.Encoding{ByteOrder: order, Type: pcm.Signed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Len() != -1 {
+			t.Errorf("Len of non-seekable data returned %d, want -1", s.Len())
+		}
+		beeptest.AssertStreamer(t, s, [][2]float64{{0, 0}, {1, -1}, {-1, 1}}, 0)
+		if s.Len() != 3 {
+			t.Errorf("Len after draining returned %d, want 3", s.Len())
+		}
+		if err := s.Seek(0); err == nil {
+			t.Error("Seek on non-seekable data succeeded")
+		}
+	}
+}
+
+func TestDecodeConformance(t *testing.T) {
+	format := beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 3}
+	var buf bytes.Buffer
+	if err := pcm.Encode(&buf, beeptest.Ramp(10000), format, pcm.SignedBE); err != nil {
+		t.Fatal(err)
+	}
+	// a header which is skipped before decoding
+	data := append([]byte("HEADER"), buf.Bytes()...)
+
+	beeptest.TestStreamSeeker(t, func() beep.StreamSeeker {
+		r := bytes.NewReader(data)
+		r.Seek(6, io.SeekStart)
+		s, err := pcm.Decode(r, format, pcm.SignedBE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestInvalidEncoding(t *testing.T) {
+	tests := []struct {
+		format beep.Format
+		enc    pcm.Encoding
+	}{
+		{beep.Format{SampleRate: 44100, NumChannels: 0, Precision: 2}, pcm.SignedLE},
+		{beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 5}, pcm.SignedLE},
+		{beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 2}, pcm.FloatLE},
+		{beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 2}, pcm.Encoding{Type: 7}},
+	}
+	for _, test := range tests {
+		if _, err := pcm.Decode(bytes.NewReader(nil), test.format, test.enc); err == nil {
+			t.Errorf("Decode succeeded with %+v %+v", test.format, test.enc)
+		}
+		if err := pcm.Encode(io.Discard, beep.Silence(10), test.format, test.enc); err == nil {
+			t.Errorf("Encode succeeded with %+v %+v", test.format, test.enc)
+		}
+	}
+}
+
+func TestDecodeDownmix(t *testing.T) {
+	format := beep.Format{SampleRate: 48000, NumChannels: 6, Precision: 4}
+	channels := []float64{1, 1, 1, 1, 1, 1}
+	var buf bytes.Buffer
+	for _, x := range channels {
+		binary.Write(&buf, binary.LittleEndian, float32(x))
+	}
+
+	s, err := pcm.Decode(bytes.NewReader(buf.Bytes()), format, pcm.FloatLE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	beeptest.AssertStreamer(t, s, [][2]float64{beep.DownmixITU51().Apply(channels)}, 1e-9)
+
+	// full scale 5.1 doesn't clip in a normalized downmix
+	s, err = pcm.DecodeDownmix(bytes.NewReader(buf.Bytes()), format, pcm.FloatLE, format.Downmix().Normalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	beeptest.AssertStreamer(t, s, [][2]float64{{1, 1}}, 1e-9)
+
+	s, err = pcm.DecodeDownmix(bytes.NewReader(buf.Bytes()), format, pcm.FloatLE, beep.SelectChannels(6, 2, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	beeptest.AssertStreamer(t, s, [][2]float64{{1, 1}}, 0)
+
+	if _, err := pcm.DecodeDownmix(bytes.NewReader(buf.Bytes()), format, pcm.FloatLE, beep.DownmixQuad()); err == nil {
+		t.Error("DecodeDownmix accepted a Downmix with the wrong number of rows")
+	}
+}
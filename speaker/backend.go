@@ -0,0 +1,199 @@
+package speaker
+
+import (
+	"os"
+	"sync"
+
+	"github.com/brotholo/beep/internal/pacer"
+	"github.com/brotholo/beep/wav"
+	"github.com/pkg/errors"
+)
+
+// Backend is an audio output the speaker sends the mixed audio to.
+//
+// The speaker calls Open once, then Write repeatedly from a single goroutine and finally Close.
+// When the speaker is reinitialized by Init, it closes the Backend and opens it again.
+type Backend interface {
+	// Open prepares the Backend for playback of audio in the provided format. The audio is written
+	// in chunks of bufferSize samples encoded as described by Format. Open returns an error if the
+	// Backend doesn't support the format.
+	Open(format Format, bufferSize int) error
+
+	// Write plays the encoded audio data in p. Write blocks until the Backend is ready to accept
+	// more data, which paces the speaker.
+	Write(p []byte) (n int, err error)
+
+	// Close stops the playback and releases all resources held by the Backend.
+	Close() error
+}
+
+// Suspender is implemented by Backends which can release the audio device while the speaker is
+// idle, see WithIdleTimeout. The speaker calls Suspend when it stops writing and Resume before it
+// writes again.
+type Suspender interface {
+	Suspend() error
+	Resume() error
+}
+
+// Null returns a Backend which discards all audio. It consumes the audio in real time, so
+// everything else behaves exactly as with a real audio device. It's useful in environments without
+// audio devices, such as servers and CI.
+func Null() Backend {
+	return &nullBackend{}
+}
+
+type nullBackend struct {
+	width int
+	pacer pacer.Pacer
+}
+
+func (nb *nullBackend) Open(format Format, bufferSize int) error {
+	nb.width = format.Width()
+	nb.pacer = pacer.New(format.SampleRate, bufferSize)
+	return nil
+}
+
+func (nb *nullBackend) Write(p []byte) (n int, err error) {
+	nb.pacer.Wait(len(p) / nb.width)
+	return len(p), nil
+}
+
+func (nb *nullBackend) Close() error {
+	return nil
+}
+
+// File returns a Backend which writes all audio to a WAVE file at path, in real time. The file is
+// created when the speaker is initialized and finalized when the speaker is closed. It supports
+// integer samples with precision 1, 2 or 3.
+func File(path string) Backend {
+	return &fileBackend{path: path}
+}
+
+type fileBackend struct {
+	path  string
+	f     *os.File
+	w     *wav.Writer
+	width int
+	pacer pacer.Pacer
+}
+
+func (fb *fileBackend) Open(format Format, bufferSize int) error {
+	if format.Float {
+		return errors.New("speaker: file backend: float samples are not supported")
+	}
+	f, err := os.Create(fb.path)
+	if err != nil {
+		return errors.Wrap(err, "speaker: file backend")
+	}
+	w, err := wav.NewWriter(f, format.Format)
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "speaker: file backend")
+	}
+	fb.f, fb.w = f, w
+	fb.width = format.Width()
+	fb.pacer = pacer.New(format.SampleRate, bufferSize)
+	return nil
+}
+
+func (fb *fileBackend) Write(p []byte) (n int, err error) {
+	n, err = fb.w.Write(p)
+	fb.pacer.Wait(n / fb.width)
+	return n, err
+}
+
+func (fb *fileBackend) Close() error {
+	if fb.f == nil {
+		return nil
+	}
+	err := fb.w.Close()
+	if cerr := fb.f.Close(); err == nil {
+		err = cerr
+	}
+	fb.f, fb.w = nil, nil
+	if err != nil {
+		return errors.Wrap(err, "speaker: file backend")
+	}
+	return nil
+}
+
+// Capture is a Backend which records all audio in memory, in real time. It's intended for tests
+// which check the audio produced by an application.
+//
+//	capture := speaker.NewCapture()
+//	speaker.Init(sr, sr.N(time.Second/100), speaker.WithBackend(capture))
+//	// play something ...
+//	samples := capture.Samples()
+type Capture struct {
+	mu     sync.Mutex
+	format Format
+	data   []byte
+	pacer  pacer.Pacer
+}
+
+// NewCapture returns a new empty Capture.
+func NewCapture() *Capture {
+	return &Capture{}
+}
+
+// Open resets the Capture and prepares it for recording in the provided format.
+func (c *Capture) Open(format Format, bufferSize int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.format = format
+	c.data = nil
+	c.pacer = pacer.New(format.SampleRate, bufferSize)
+	return nil
+}
+
+// Write records the audio data in p.
+func (c *Capture) Write(p []byte) (n int, err error) {
+	c.mu.Lock()
+	c.data = append(c.data, p...)
+	c.mu.Unlock()
+	c.pacer.Wait(len(p) / c.format.Width())
+	return len(p), nil
+}
+
+// Close does nothing, the recorded audio remains available.
+func (c *Capture) Close() error {
+	return nil
+}
+
+// Format returns the format of the recorded audio.
+func (c *Capture) Format() Format {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.format
+}
+
+// Len returns the number of samples recorded so far.
+func (c *Capture) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.format.Width() == 0 {
+		return 0
+	}
+	return len(c.data) / c.format.Width()
+}
+
+// Samples returns all samples recorded so far, decoded to stereo.
+func (c *Capture) Samples() [][2]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.format.Width() == 0 {
+		return nil
+	}
+	samples := make([][2]float64, len(c.data)/c.format.Width())
+	for i := range samples {
+		samples[i], _ = c.format.Decode(c.data[i*c.format.Width():])
+	}
+	return samples
+}
+
+// Reset discards all samples recorded so far.
+func (c *Capture) Reset() {
+	c.mu.Lock()
+	c.data = nil
+	c.mu.Unlock()
+}
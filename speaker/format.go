@@ -0,0 +1,58 @@
+package speaker
+
+import (
+	"github.com/brotholo/beep"
+	"github.com/pkg/errors"
+)
+
+// Format is the format of the audio the speaker sends to a Backend.
+//
+// Samples are interleaved and little-endian. Integer samples are signed, except for 8-bit ones,
+// which are unsigned as in WAVE files and most audio APIs. If Float is set, samples are IEEE 754
+// floating point numbers and Precision is 4 or 8.
+//
+// The stereo output of the speaker goes to the front left and right channels, the remaining
+// channels are silent. Mono output is the average of both channels.
+type Format struct {
+	beep.Format
+
+	// Float means the samples are floating point numbers instead of integers.
+	Float bool
+}
+
+// Encode encodes a single sample in f.Width() bytes to p.
+func (f Format) Encode(p []byte, sample [2]float64) (n int) {
+	switch {
+	case f.Float:
+		return f.EncodeFloat(p, sample)
+	case f.Precision == 1:
+		return f.EncodeUnsigned(p, sample)
+	default:
+		return f.EncodeSigned(p, sample)
+	}
+}
+
+// Decode decodes a single sample encoded in f.Width() bytes from p.
+func (f Format) Decode(p []byte) (sample [2]float64, n int) {
+	switch {
+	case f.Float:
+		return f.DecodeFloat(p)
+	case f.Precision == 1:
+		return f.DecodeUnsigned(p)
+	default:
+		return f.DecodeSigned(p)
+	}
+}
+
+func (f Format) validate() error {
+	if f.NumChannels < 1 {
+		return errors.New("speaker: invalid number of channels (less than 1)")
+	}
+	if f.Float && f.Precision != 4 && f.Precision != 8 {
+		return errors.New("speaker: unsupported float precision, 4 or 8 is supported")
+	}
+	if !f.Float && (f.Precision < 1 || f.Precision > 4) {
+		return errors.New("speaker: unsupported precision, 1 to 4 is supported")
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package speaker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/brotholo/beep"
+	"github.com/pkg/errors"
+)
+
+// ErrStopped is the error of a Handle whose Streamers were stopped before they drained, either by
+// Handle.Stop or by clearing the speaker.
+var ErrStopped = errors.New("speaker: playback stopped")
+
+// Handle tracks the Streamers started by a single call to Play. It's done when all of them are
+// drained, one of them errors, or they are stopped.
+//
+//	h := speaker.Play(streamer)
+//	// ...
+//	if err := h.Wait(ctx); err != nil {
+//		// the streamer failed or was stopped
+//	}
+type Handle struct {
+	mu      sync.Mutex
+	pending int // number of Streamers still playing
+	stopped bool
+	err     error
+	done    chan struct{}
+}
+
+func newHandle(pending int) *Handle {
+	h := &Handle{pending: pending, done: make(chan struct{})}
+	if pending == 0 {
+		close(h.done)
+	}
+	return h
+}
+
+// Done returns a channel which is closed when the Handle is done.
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Err returns nil if all Streamers of the Handle drained, the error of the first Streamer which
+// errored, or ErrStopped if they were stopped. It returns nil while the Handle is not done.
+func (h *Handle) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// Wait blocks until the Handle is done and returns its Err, or until ctx is done and returns
+// ctx.Err(). The Streamers keep playing if ctx is done first.
+func (h *Handle) Wait(ctx context.Context) error {
+	select {
+	case <-h.done:
+		return h.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop stops the Streamers of the Handle without affecting other Streamers playing in the
+// speaker. The Handle is done immediately with ErrStopped, unless it was done already.
+//
+// Stop doesn't lock the speaker, so it's safe to call from within a Streamer, for example from a
+// beep.Callback.
+func (h *Handle) Stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopped = true
+	h.finish(ErrStopped)
+}
+
+// finish makes the Handle done with err if it isn't done yet. h.mu must be held.
+func (h *Handle) finish(err error) {
+	select {
+	case <-h.done:
+		return
+	default:
+	}
+	h.err = err
+	close(h.done)
+}
+
+// handleStreamer is a Streamer played through a Handle. It's streamed only by the mixer of sp, so
+// sp.mu is always held in Stream.
+type handleStreamer struct {
+	sp *Speaker
+	h  *Handle
+	s  beep.Streamer
+}
+
+func (hs *handleStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	hs.h.mu.Lock()
+	stopped := hs.h.stopped
+	hs.h.mu.Unlock()
+	if stopped {
+		delete(hs.sp.handles, hs.h)
+		return 0, false
+	}
+
+	n, ok = hs.s.Stream(samples)
+	if ok {
+		return n, true
+	}
+
+	hs.h.mu.Lock()
+	if err := hs.s.Err(); err != nil {
+		hs.h.finish(err)
+	}
+	hs.h.pending--
+	if hs.h.pending == 0 {
+		hs.h.finish(nil)
+		delete(hs.sp.handles, hs.h)
+	}
+	hs.h.mu.Unlock()
+	return n, false
+}
+
+func (hs *handleStreamer) Err() error {
+	return hs.s.Err()
+}
@@ -0,0 +1,150 @@
+package speaker
+
+import (
+	"time"
+
+	"github.com/brotholo/beep"
+)
+
+// Option configures the speaker in Init.
+type Option func(*config)
+
+type config struct {
+	backend         Backend
+	statsInterval   time.Duration
+	statsCallback   func(Stats)
+	format          Format
+	idleTimeout     time.Duration
+	resampleQuality int
+	bus             []func(beep.Streamer) beep.Streamer
+	newBus          bool // bus set by the options being applied
+	limit           bool
+	ceiling         float64
+}
+
+func newConfig(opts []Option) config {
+	c := config{
+		format:          Format{Format: beep.Format{NumChannels: 2, Precision: 2}},
+		resampleQuality: 4,
+	}
+	return c.with(opts)
+}
+
+// with returns the config changed by the options.
+func (c config) with(opts []Option) config {
+	c.newBus = false
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.backend == nil {
+		c.backend = Oto()
+	}
+	return c
+}
+
+// WithBackend makes the speaker send the audio to the provided Backend instead of the default audio
+// device.
+//
+//	speaker.Init(sr, sr.N(time.Second/10), speaker.WithBackend(speaker.Null()))
+func WithBackend(b Backend) Option {
+	return func(c *config) {
+		c.backend = b
+	}
+}
+
+// WithPrecision sets the number of bytes of the integer samples sent to the Backend. The default is
+// 2 (16-bit), 3 and 4 give higher resolution where the Backend supports it.
+func WithPrecision(precision int) Option {
+	return func(c *config) {
+		c.format.Precision = precision
+		c.format.Float = false
+	}
+}
+
+// WithFloat makes the speaker send 32-bit floating point samples to the Backend, if it supports
+// them.
+func WithFloat() Option {
+	return func(c *config) {
+		c.format.Precision = 4
+		c.format.Float = true
+	}
+}
+
+// WithChannelLayout sets the channels of the audio sent to the Backend, for example
+// beep.LayoutMono or beep.Layout5_1. The default is stereo. The speaker plays to the front left
+// and right channels, see Format.
+func WithChannelLayout(mask beep.ChannelMask) Option {
+	return func(c *config) {
+		c.format.NumChannels = mask.NumChannels()
+		c.format.ChannelMask = mask
+	}
+}
+
+// WithIdleTimeout makes the speaker suspend when no Streamers have been playing for timeout. While
+// suspended, the speaker doesn't pull or write any audio and Backends which support it release the
+// audio device, see Suspender. Play resumes the speaker.
+//
+// By default, the speaker never suspends.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.idleTimeout = timeout
+	}
+}
+
+// WithResampleQuality sets the quality of resampling the playing Streamers when the sample rate
+// changes by calling Init again, see beep.Resample. The default is 4.
+func WithResampleQuality(quality int) Option {
+	return func(c *config) {
+		c.resampleQuality = quality
+	}
+}
+
+// WithStatsCallback makes the speaker call f with its current Stats every interval. The callback
+// runs on its own goroutine, so it doesn't disturb the playback, and stops when the speaker is
+// closed.
+func WithStatsCallback(interval time.Duration, f func(Stats)) Option {
+	return func(c *config) {
+		c.statsInterval = interval
+		c.statsCallback = f
+	}
+}
+
+// WithMasterBus routes the mixed audio of all playing Streamers through a chain of effects before
+// it is sent to the Backend. Each function wraps the output of the previous one, the first one
+// wraps the mix.
+//
+//	volume := &effects.Volume{Base: 2}
+//	speaker.Init(sr, bufferSize, speaker.WithMasterBus(func(s beep.Streamer) beep.Streamer {
+//		volume.Streamer = s
+//		return volume
+//	}))
+//
+// Lock the speaker when changing the effects while playing. When the speaker is reinitialized by
+// Init, WithMasterBus replaces the previous chain, WithMasterBus() without effects removes it.
+func WithMasterBus(chain ...func(beep.Streamer) beep.Streamer) Option {
+	return func(c *config) {
+		if !c.newBus {
+			c.bus, c.newBus = nil, true
+		}
+		c.bus = append(c.bus, chain...)
+	}
+}
+
+// WithLimiter puts a look-ahead brickwall limiter at the end of the master bus, so that loud
+// overlapping sounds are turned down smoothly instead of being clipped harshly. The ceiling is a
+// linear amplitude, 1 is full scale. The limiter delays the audio by LimiterLookahead.
+//
+// Without the limiter, the audio is clipped to full scale. The gain reduction of the limiter is
+// reported in Stats. A ceiling of 0 removes the limiter when the speaker is reinitialized by Init.
+func WithLimiter(ceiling float64) Option {
+	return func(c *config) {
+		c.limit = ceiling > 0
+		c.ceiling = ceiling
+	}
+}
+
+// Timing of the limiter enabled by WithLimiter.
+const (
+	LimiterLookahead = 5 * time.Millisecond
+	LimiterRelease   = 100 * time.Millisecond
+)
@@ -0,0 +1,76 @@
+package speaker
+
+import (
+	"time"
+
+	"github.com/hajimehoshi/oto"
+	"github.com/pkg/errors"
+)
+
+// Oto returns a Backend which plays audio through the default audio device using the Oto library.
+// This is the Backend used when no other is specified.
+//
+// Oto supports only one open device per process, so only one speaker may use this Backend at a
+// time. It supports 8-bit and 16-bit integer samples.
+//
+// While the speaker is idle, the Backend releases the audio device, see WithIdleTimeout.
+func Oto() Backend {
+	return &otoBackend{}
+}
+
+type otoBackend struct {
+	format     Format
+	bufferSize int
+	context    *oto.Context
+	player     *oto.Player
+}
+
+func (ob *otoBackend) Open(format Format, bufferSize int) error {
+	if format.Float || format.Precision > 2 {
+		return errors.New("speaker: oto backend supports only 8-bit and 16-bit integer samples")
+	}
+	ob.format, ob.bufferSize = format, bufferSize
+	return ob.open()
+}
+
+func (ob *otoBackend) open() error {
+	var err error
+	ob.context, err = oto.NewContext(int(ob.format.SampleRate), ob.format.NumChannels, ob.format.Precision, ob.bufferSize*ob.format.Width())
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize speaker")
+	}
+	ob.player = ob.context.NewPlayer()
+	return nil
+}
+
+func (ob *otoBackend) Write(p []byte) (n int, err error) {
+	if ob.player == nil {
+		// reopening the device after a suspension failed, try again
+		if err := ob.open(); err != nil {
+			time.Sleep(ob.format.SampleRate.D(len(p) / ob.format.Width()))
+			return 0, err
+		}
+	}
+	return ob.player.Write(p)
+}
+
+func (ob *otoBackend) Suspend() error {
+	return ob.Close()
+}
+
+func (ob *otoBackend) Resume() error {
+	if ob.player != nil {
+		return nil
+	}
+	return ob.open()
+}
+
+func (ob *otoBackend) Close() error {
+	if ob.player == nil {
+		return nil
+	}
+	ob.player.Close()
+	err := ob.context.Close()
+	ob.player, ob.context = nil, nil
+	return err
+}
@@ -1,78 +1,386 @@
-// Package speaker implements playback of beep.Streamer values through physical speakers.
+// Package speaker implements playback of beep.Streamer values through physical speakers or other
+// outputs, see Backend.
+//
+// The package level functions control a default Speaker, which is enough for most applications.
+// Use New to create additional independent Speakers, for example to play through multiple
+// Backends or with different sample rates or buffer sizes at the same time.
 package speaker
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/brotholo/beep"
-	"github.com/hajimehoshi/oto"
-	"github.com/pkg/errors"
+	"github.com/brotholo/beep/effects"
 )
 
-var (
-	mu      sync.Mutex
-	mixer   beep.Mixer
-	samples [][2]float64
-	buf     []byte
-	context *oto.Context
-	player  *oto.Player
-	done    chan struct{}
-)
+// Speaker mixes the Streamers played through it and sends the result to a Backend.
+//
+// All methods of Speaker are safe for concurrent use.
+type Speaker struct {
+	mu       sync.Mutex // locked while pulling data from the playing Streamers
+	mixer    beep.Mixer
+	out      beep.Streamer    // end of the master bus
+	limiter  *effects.Limiter // nil if disabled
+	lockedAt time.Time
+	handles  map[*Handle]struct{} // Handles with Streamers in the mixer
 
-// Init initializes audio playback through speaker. Must be called before using this package.
+	suspended bool          // the update goroutine waits for wake
+	wake      chan struct{} // signals the suspended update goroutine to resume
+
+	stats statsRecorder
+
+	tapMu sync.Mutex
+	taps  map[*Tap]struct{}
+
+	ctl     sync.Mutex // serializes starting and stopping
+	config  config     // options of the last start, kept until Close
+	format  Format
+	backend Backend
+	done    chan struct{} // closed to stop the update goroutine
+	stopped chan struct{} // closed when the update goroutine returns
+}
+
+// New creates a Speaker and starts playback through it.
 //
 // The bufferSize argument specifies the number of samples of the speaker's buffer. Bigger
 // bufferSize means lower CPU usage and more reliable playback. Lower bufferSize means better
 // responsiveness and less delay.
-func Init(sampleRate beep.SampleRate, bufferSize int) error {
-	mu.Lock()
-	defer mu.Unlock()
+//
+// By default, the audio is played through the default audio device. Pass WithBackend to send it
+// elsewhere.
+func New(sampleRate beep.SampleRate, bufferSize int, opts ...Option) (*Speaker, error) {
+	s := &Speaker{}
+	if err := s.start(sampleRate, bufferSize, newConfig(opts)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
 
-	Close()
+// start opens the backend and starts the update goroutine. s.ctl must be held or s must not be
+// shared yet.
+func (s *Speaker) start(sampleRate beep.SampleRate, bufferSize int, c config) error {
+	format := c.format
+	format.SampleRate = sampleRate
+	if err := format.validate(); err != nil {
+		return err
+	}
+	if err := c.backend.Open(format, bufferSize); err != nil {
+		return err
+	}
+	prevSampleRate := s.format.SampleRate
+	s.config = c
+	s.format = format
+	s.backend = c.backend
 
-	mixer = beep.Mixer{}
+	if prevSampleRate != sampleRate {
+		s.closeTaps()
+	}
 
-	numBytes := bufferSize * 4
-	samples = make([][2]float64, bufferSize)
-	buf = make([]byte, numBytes)
+	s.mu.Lock()
+	if prevSampleRate != 0 && prevSampleRate != sampleRate && s.mixer.Len() > 0 {
+		// carry the playing Streamers over to the new sample rate
+		carried := &drainingMixer{m: s.mixer}
+		s.mixer = beep.Mixer{}
+		s.mixer.Add(beep.Resample(c.resampleQuality, prevSampleRate, sampleRate, carried))
+	}
+	s.out = &s.mixer
+	for _, effect := range c.bus {
+		s.out = effect(s.out)
+	}
+	s.limiter = nil
+	if c.limit {
+		s.limiter = effects.NewLimiter(s.out, sampleRate, c.ceiling, LimiterLookahead, LimiterRelease)
+		s.out = s.limiter
+	}
+	s.suspended = false
+	s.wake = make(chan struct{}, 1)
+	s.mu.Unlock()
 
-	var err error
-	context, err = oto.NewContext(int(sampleRate), 2, 2, numBytes)
-	if err != nil {
-		return errors.Wrap(err, "failed to initialize speaker")
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	s.stats.reset(sampleRate.D(bufferSize))
+
+	go s.run(s.backend, format, bufferSize, c.idleTimeout, s.done, s.stopped)
+	if c.statsCallback != nil {
+		go s.reportStats(c.statsInterval, c.statsCallback, s.done)
 	}
-	player = context.NewPlayer()
 
-	done = make(chan struct{})
+	return nil
+}
 
-	go func() {
-		for {
-			select {
-			default:
-				update()
-			case <-done:
-				return
-			}
-		}
-	}()
+// Init reinitializes the Speaker with a new sample rate, buffer size and options, for example to
+// switch to a lower latency. The Streamers playing in the Speaker keep playing. If the sample rate
+// changes, they are resampled to the new one, see WithResampleQuality.
+//
+// The options which aren't passed keep their previous values, including the Backend, which is
+// closed and opened again with the new format. After Close, Init starts with the default options,
+// like New.
+//
+// Init must not be called while the Speaker is locked.
+func (s *Speaker) Init(sampleRate beep.SampleRate, bufferSize int, opts ...Option) error {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+	c := newConfig(opts)
+	if s.backend != nil {
+		c = s.config.with(opts)
+	}
+	s.stop()
+	return s.start(sampleRate, bufferSize, c)
+}
+
+// drainingMixer streams a Mixer until it gets empty, then it drains.
+type drainingMixer struct {
+	m beep.Mixer
+}
+
+func (dm *drainingMixer) Stream(samples [][2]float64) (n int, ok bool) {
+	if dm.m.Len() == 0 {
+		return 0, false
+	}
+	return dm.m.Stream(samples)
+}
 
+func (dm *drainingMixer) Err() error {
 	return nil
 }
 
+// stop stops the update goroutine and closes the backend. s.ctl must be held.
+//
+// The update goroutine is waited for without holding s.mu, so it can always finish its current
+// update.
+func (s *Speaker) stop() error {
+	if s.backend == nil {
+		return nil
+	}
+	close(s.done)
+	<-s.stopped
+	err := s.backend.Close()
+	s.backend = nil
+	return err
+}
+
+// run calls update until done is closed. If idleTimeout is positive, it suspends when no Streamers
+// have been playing for idleTimeout.
+func (s *Speaker) run(backend Backend, format Format, bufferSize int, idleTimeout time.Duration, done, stopped chan struct{}) {
+	defer close(stopped)
+
+	samples := make([][2]float64, bufferSize)
+	buf := make([]byte, bufferSize*format.Width())
+
+	var idleSince time.Time
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if idle := s.update(backend, format, samples, buf); !idle || idleTimeout <= 0 {
+			idleSince = time.Time{}
+			continue
+		}
+		if idleSince.IsZero() {
+			idleSince = time.Now()
+		}
+		if time.Since(idleSince) < idleTimeout || !s.suspend() {
+			continue
+		}
+
+		if sb, ok := backend.(Suspender); ok {
+			sb.Suspend()
+		}
+		select {
+		case <-s.wake:
+		case <-done:
+			return
+		}
+		if sb, ok := backend.(Suspender); ok {
+			sb.Resume()
+		}
+		idleSince = time.Time{}
+	}
+}
+
+// suspend marks the Speaker as suspended unless a Streamer has been added in the meantime. It
+// reports whether the Speaker got suspended.
+func (s *Speaker) suspend() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mixer.Len() > 0 {
+		return false
+	}
+	// drop a stale signal, only Play from now on wakes the update goroutine
+	select {
+	case <-s.wake:
+	default:
+	}
+	s.suspended = true
+	s.stats.suspend()
+	return true
+}
+
+// Close closes the playback and the Backend. The Speaker plays nothing after Close, but the
+// Streamers added to it are kept. The Taps of the Speaker are closed.
+//
+// Close must not be called while the Speaker is locked.
+func (s *Speaker) Close() error {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+	err := s.stop()
+	s.closeTaps()
+	return err
+}
+
+// SampleRate returns the sample rate of the Speaker. It returns 0 if the Speaker is closed.
+func (s *Speaker) SampleRate() beep.SampleRate {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+	if s.backend == nil {
+		return 0
+	}
+	return s.format.SampleRate
+}
+
+// Format returns the format of the audio the Speaker sends to its Backend. It returns the zero
+// Format if the Speaker is closed.
+func (s *Speaker) Format() Format {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+	if s.backend == nil {
+		return Format{}
+	}
+	return s.format
+}
+
+// Lock locks the Speaker. While locked, the Speaker won't pull new data from the playing Streamers.
+// Lock if you want to modify any currently playing Streamers to avoid race conditions.
+//
+// Always lock the Speaker for as little time as possible, to avoid playback glitches.
+func (s *Speaker) Lock() {
+	s.mu.Lock()
+	s.lockedAt = time.Now()
+}
+
+// Unlock unlocks the Speaker. Call after modifying any currently playing Streamer.
+func (s *Speaker) Unlock() {
+	held := time.Since(s.lockedAt)
+	s.mu.Unlock()
+	s.stats.recordLockHold(held)
+}
+
+// Play starts playing all provided Streamers through the Speaker. The returned Handle tracks the
+// playback of the Streamers and stops them.
+//
+// If the Speaker is suspended, see WithIdleTimeout, Play resumes it.
+func (s *Speaker) Play(st ...beep.Streamer) *Handle {
+	h := newHandle(len(st))
+	tracked := make([]beep.Streamer, len(st))
+	for i := range st {
+		tracked[i] = &handleStreamer{sp: s, h: h, s: st[i]}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(st) > 0 {
+		if s.handles == nil {
+			s.handles = make(map[*Handle]struct{})
+		}
+		s.handles[h] = struct{}{}
+	}
+	s.mixer.Add(tracked...)
+	if s.suspended {
+		s.suspended = false
+		s.wake <- struct{}{}
+	}
+	return h
+}
+
+// PlayAndWait plays the provided Streamers through the Speaker and waits until all of them are
+// drained. It returns the error of the first Streamer which errored, or ErrStopped if the
+// Streamers were stopped. If ctx is done first, PlayAndWait stops the Streamers and returns
+// ctx.Err().
+func (s *Speaker) PlayAndWait(ctx context.Context, st ...beep.Streamer) error {
+	h := s.Play(st...)
+	err := h.Wait(ctx)
+	if err == ctx.Err() && err != nil {
+		h.Stop()
+	}
+	return err
+}
+
+// Clear removes all currently playing Streamers from the Speaker. Their Handles are done with
+// ErrStopped.
+func (s *Speaker) Clear() {
+	s.mu.Lock()
+	for h := range s.handles {
+		h.Stop()
+	}
+	s.handles = nil
+	s.mixer.Clear()
+	s.mu.Unlock()
+}
+
+// update pulls new data from the playing Streamers and sends it to the backend. Blocks until the
+// data is sent and started playing. It reports whether no Streamers are left playing.
+func (s *Speaker) update(backend Backend, format Format, samples [][2]float64, buf []byte) (idle bool) {
+	start := time.Now()
+	s.mu.Lock()
+	n, _ := s.out.Stream(samples)
+	idle = s.mixer.Len() == 0
+	gainReduction := 0.0
+	if s.limiter != nil {
+		gainReduction = s.limiter.GainReduction()
+	}
+	s.mu.Unlock()
+	streamTime := time.Since(start)
+
+	// an effect on the master bus may end the audio
+	for i := range samples[n:] {
+		samples[n+i] = [2]float64{}
+	}
+
+	s.writeTaps(samples)
+
+	p := buf
+	for _, sample := range samples {
+		p = p[format.Encode(p, sample):]
+	}
+
+	s.stats.recordBlock(start, streamTime, time.Since(start), gainReduction)
+	backend.Write(buf)
+	s.stats.recordWrite()
+	return idle
+}
+
+// std is the default Speaker controlled by the package level functions.
+var std Speaker
+
+// Init initializes audio playback through speaker. Must be called before using this package.
+//
+// The bufferSize argument specifies the number of samples of the speaker's buffer. Bigger
+// bufferSize means lower CPU usage and more reliable playback. Lower bufferSize means better
+// responsiveness and less delay.
+//
+// By default, the audio is played through the default audio device. Pass WithBackend to send it
+// elsewhere, for example to Null in environments without audio devices.
+//
+// Init may be called again to change the sample rate, buffer size or options while playing. The
+// playing Streamers keep playing, resampled to the new sample rate if it changes. The options which
+// aren't passed keep their previous values until Close. Init must not be called while the speaker
+// is locked.
+func Init(sampleRate beep.SampleRate, bufferSize int, opts ...Option) error {
+	return std.Init(sampleRate, bufferSize, opts...)
+}
+
 // Close closes the playback and the driver. In most cases, there is certainly no need to call Close
 // even when the program doesn't play anymore, because in properly set systems, the default mixer
 // handles multiple concurrent processes. It's only when the default device is not a virtual but hardware
-// device, that you'll probably want to manually manage the device from your application.
+// device, that you'll probably want to manually manage the device from your application, or let
+// the speaker release it while idle, see WithIdleTimeout.
 func Close() {
-	if player != nil {
-		if done != nil {
-			done <- struct{}{}
-			done = nil
-		}
-		player.Close()
-		context.Close()
-		player = nil
-	}
+	std.Close()
 }
 
 // Lock locks the speaker. While locked, speaker won't pull new data from the playing Streamers. Lock
@@ -80,51 +388,33 @@ func Close() {
 //
 // Always lock speaker for as little time as possible, to avoid playback glitches.
 func Lock() {
-	mu.Lock()
+	std.Lock()
 }
 
 // Unlock unlocks the speaker. Call after modifying any currently playing Streamer.
 func Unlock() {
-	mu.Unlock()
+	std.Unlock()
 }
 
-// Play starts playing all provided Streamers through the speaker.
-func Play(s ...beep.Streamer) {
-	mu.Lock()
-	mixer.Add(s...)
-	mu.Unlock()
+// Play starts playing all provided Streamers through the speaker. The returned Handle tracks the
+// playback of the Streamers and stops them. If the speaker is suspended, see WithIdleTimeout, Play
+// resumes it.
+func Play(s ...beep.Streamer) *Handle {
+	return std.Play(s...)
 }
 
-// Clear removes all currently playing Streamers from the speaker.
-func Clear() {
-	mu.Lock()
-	mixer.Clear()
-	mu.Unlock()
-}
-
-// update pulls new data from the playing Streamers and sends it to the speaker. Blocks until the
-// data is sent and started playing.
-func update() {
-	mu.Lock()
-	mixer.Stream(samples)
-	mu.Unlock()
-
-	for i := range samples {
-		for c := range samples[i] {
-			val := samples[i][c]
-			if val < -1 {
-				val = -1
-			}
-			if val > +1 {
-				val = +1
-			}
-			valInt16 := int16(val * (1<<15 - 1))
-			low := byte(valInt16)
-			high := byte(valInt16 >> 8)
-			buf[i*4+c*2+0] = low
-			buf[i*4+c*2+1] = high
-		}
-	}
+// PlayAndWait plays the provided Streamers through the speaker and waits until all of them are
+// drained, see Speaker.PlayAndWait.
+//
+//	if err := speaker.PlayAndWait(context.Background(), streamer); err != nil {
+//		log.Fatal(err)
+//	}
+func PlayAndWait(ctx context.Context, s ...beep.Streamer) error {
+	return std.PlayAndWait(ctx, s...)
+}
 
-	player.Write(buf)
+// Clear removes all currently playing Streamers from the speaker. Their Handles are done with
+// ErrStopped.
+func Clear() {
+	std.Clear()
 }
@@ -0,0 +1,626 @@
+package speaker_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/beeptest"
+	"github.com/brotholo/beep/effects"
+	"github.com/brotholo/beep/speaker"
+	"github.com/brotholo/beep/wav"
+)
+
+const sampleRate = beep.SampleRate(8000)
+
+// playAndWait plays s through the package level speaker and waits until it is drained.
+func playAndWait(t *testing.T, s beep.Streamer) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := speaker.PlayAndWait(ctx, s); err != nil {
+		t.Fatalf("playback did not finish: %v", err)
+	}
+}
+
+func TestCaptureBackend(t *testing.T) {
+	capture := speaker.NewCapture()
+	if err := speaker.Init(sampleRate, 80, speaker.WithBackend(capture)); err != nil {
+		t.Fatal(err)
+	}
+	defer speaker.Close()
+
+	start := time.Now()
+	playAndWait(t, beeptest.Constant(400, [2]float64{0.5, -0.25}))
+	if elapsed := time.Since(start); elapsed < sampleRate.D(400)/2 {
+		t.Errorf("playback of %v took only %v, the backend is not paced", sampleRate.D(400), elapsed)
+	}
+	speaker.Close()
+
+	var played int
+	for _, sample := range capture.Samples() {
+		if sample[0] > 0.49 && sample[0] < 0.51 && sample[1] < -0.24 && sample[1] > -0.26 {
+			played++
+		}
+	}
+	if played != 400 {
+		t.Errorf("captured %d samples of the played Streamer, want 400", played)
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	if err := speaker.Init(sampleRate, 80, speaker.WithBackend(speaker.File(path))); err != nil {
+		t.Fatal(err)
+	}
+	playAndWait(t, beeptest.Constant(200, [2]float64{0.5, 0.5}))
+	speaker.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, format, err := wav.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if format.SampleRate != sampleRate || format.NumChannels != 2 {
+		t.Errorf("unexpected format %+v", format)
+	}
+	if s.Len() < 200 {
+		t.Errorf("file contains %d samples, want at least 200", s.Len())
+	}
+}
+
+func TestNullBackend(t *testing.T) {
+	if err := speaker.Init(sampleRate, 80, speaker.WithBackend(speaker.Null())); err != nil {
+		t.Fatal(err)
+	}
+	defer speaker.Close()
+	playAndWait(t, beeptest.Ramp(160))
+}
+
+func TestMultipleSpeakers(t *testing.T) {
+	fast, slow := speaker.NewCapture(), speaker.NewCapture()
+	a, err := speaker.New(16000, 16, speaker.WithBackend(fast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := speaker.New(8000, 800, speaker.WithBackend(slow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if a.SampleRate() != 16000 || b.SampleRate() != 8000 {
+		t.Errorf("sample rates are %v and %v, want 16000 and 8000", a.SampleRate(), b.SampleRate())
+	}
+
+	done := make(chan struct{}, 2)
+	a.Play(beep.Seq(beeptest.Constant(320, [2]float64{0.5, 0.5}), beep.Callback(func() { done <- struct{}{} })))
+	b.Play(beep.Seq(beeptest.Constant(160, [2]float64{-0.5, -0.5}), beep.Callback(func() { done <- struct{}{} })))
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("playback did not finish")
+		}
+	}
+	a.Close()
+	b.Close()
+
+	for _, sample := range fast.Samples() {
+		if sample[0] < -0.1 {
+			t.Fatal("audio played through one speaker leaked into the other")
+		}
+	}
+	for _, sample := range slow.Samples() {
+		if sample[0] > 0.1 {
+			t.Fatal("audio played through one speaker leaked into the other")
+		}
+	}
+	if a.SampleRate() != 0 {
+		t.Errorf("closed speaker reports sample rate %v", a.SampleRate())
+	}
+}
+
+// slowStreamer is silent and takes delay to produce each block.
+type slowStreamer struct {
+	delay time.Duration
+	n     int
+}
+
+func (s *slowStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	if s.n <= 0 {
+		return 0, false
+	}
+	time.Sleep(s.delay)
+	n = len(samples)
+	if n > s.n {
+		n = s.n
+	}
+	for i := range samples[:n] {
+		samples[i] = [2]float64{}
+	}
+	s.n -= n
+	return n, true
+}
+
+func (s *slowStreamer) Err() error {
+	return nil
+}
+
+func TestStats(t *testing.T) {
+	reports := make(chan speaker.Stats, 100)
+	sp, err := speaker.New(sampleRate, 80,
+		speaker.WithBackend(speaker.Null()),
+		speaker.WithStatsCallback(20*time.Millisecond, func(st speaker.Stats) {
+			select {
+			case reports <- st:
+			default:
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+
+	if latency := sp.Stats().Latency; latency != sampleRate.D(80) {
+		t.Errorf("latency is %v, want %v", latency, sampleRate.D(80))
+	}
+
+	sp.Lock()
+	time.Sleep(30 * time.Millisecond)
+	sp.Unlock()
+	lockHold := sp.Stats().MaxLockHold
+
+	// 80 samples at 8000Hz is a 10ms block, the streamer takes 20ms per block
+	done := make(chan struct{})
+	sp.Play(beep.Seq(&slowStreamer{delay: 20 * time.Millisecond, n: 240}, beep.Callback(func() {
+		close(done)
+	})))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("playback did not finish")
+	}
+
+	st := sp.Stats()
+	if st.Blocks == 0 {
+		t.Error("no blocks recorded")
+	}
+	if st.MaxLockHold < 30*time.Millisecond {
+		t.Errorf("max lock hold is %v, want at least 30ms", st.MaxLockHold)
+	}
+	if st.MaxLockHold != lockHold {
+		t.Errorf("max lock hold changed from %v to %v while streaming", lockHold, st.MaxLockHold)
+	}
+	if st.MaxStreamTime < 20*time.Millisecond {
+		t.Errorf("max stream time is %v, want at least 20ms", st.MaxStreamTime)
+	}
+	if st.LateWrites < 3 {
+		t.Errorf("recorded %d late writes, want at least 3", st.LateWrites)
+	}
+	if st.AvgStreamTime > st.MaxStreamTime {
+		t.Errorf("average stream time %v exceeds the maximum %v", st.AvgStreamTime, st.MaxStreamTime)
+	}
+
+	select {
+	case report := <-reports:
+		if report.Latency != sampleRate.D(80) {
+			t.Errorf("reported latency is %v, want %v", report.Latency, sampleRate.D(80))
+		}
+	case <-time.After(time.Second):
+		t.Error("stats callback was not called")
+	}
+}
+
+func TestMasterBus(t *testing.T) {
+	capture := speaker.NewCapture()
+	err := speaker.Init(sampleRate, 80, speaker.WithBackend(capture), speaker.WithMasterBus(effects.Swap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	playAndWait(t, beeptest.Constant(160, [2]float64{0.5, -0.25}))
+	speaker.Close()
+
+	var swapped int
+	for _, sample := range capture.Samples() {
+		if sample[0] < -0.24 && sample[0] > -0.26 && sample[1] > 0.49 && sample[1] < 0.51 {
+			swapped++
+		}
+	}
+	if swapped != 160 {
+		t.Errorf("captured %d samples with swapped channels, want 160", swapped)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	capture := speaker.NewCapture()
+	err := speaker.Init(sampleRate, 80, speaker.WithBackend(capture), speaker.WithLimiter(0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer speaker.Close()
+
+	done := make(chan struct{})
+	speaker.Play(
+		beeptest.Constant(400, [2]float64{0.4, 0.4}),
+		beep.Seq(beeptest.Constant(400, [2]float64{0.4, 0.4}), beep.Callback(func() {
+			close(done)
+		})),
+	)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("playback did not finish")
+	}
+	st := speaker.ReadStats()
+	speaker.Close()
+
+	for _, sample := range capture.Samples() {
+		if sample[0] > 0.5+1e-4 || sample[1] > 0.5+1e-4 {
+			t.Fatalf("sample %v exceeds the limiter ceiling", sample)
+		}
+	}
+	if want := 20 * math.Log10(0.8/0.5); math.Abs(st.MaxGainReduction-want) > 0.01 {
+		t.Errorf("max gain reduction is %vdB, want %vdB", st.MaxGainReduction, want)
+	}
+}
+
+func TestOutputFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []speaker.Option
+		want speaker.Format
+	}{
+		{"float", []speaker.Option{speaker.WithFloat()},
+			speaker.Format{Format: beep.Format{SampleRate: sampleRate, NumChannels: 2, Precision: 4}, Float: true}},
+		{"24-bit 5.1", []speaker.Option{speaker.WithPrecision(3), speaker.WithChannelLayout(beep.Layout5_1)},
+			speaker.Format{Format: beep.Format{SampleRate: sampleRate, NumChannels: 6, Precision: 3, ChannelMask: beep.Layout5_1}}},
+		{"8-bit mono", []speaker.Option{speaker.WithPrecision(1), speaker.WithChannelLayout(beep.LayoutMono)},
+			speaker.Format{Format: beep.Format{SampleRate: sampleRate, NumChannels: 1, Precision: 1, ChannelMask: beep.LayoutMono}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			capture := speaker.NewCapture()
+			sp, err := speaker.New(sampleRate, 80, append(test.opts, speaker.WithBackend(capture))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sp.Format() != test.want {
+				t.Errorf("speaker format is %+v, want %+v", sp.Format(), test.want)
+			}
+			done := make(chan struct{})
+			sp.Play(beep.Seq(beeptest.Constant(160, [2]float64{0.5, 0.5}), beep.Callback(func() {
+				close(done)
+			})))
+			<-done
+			sp.Close()
+
+			if capture.Format() != test.want {
+				t.Errorf("backend format is %+v, want %+v", capture.Format(), test.want)
+			}
+			var played int
+			for _, sample := range capture.Samples() {
+				if math.Abs(sample[0]-0.5) < 0.01 && math.Abs(sample[1]-0.5) < 0.01 {
+					played++
+				}
+			}
+			if played != 160 {
+				t.Errorf("captured %d samples of the played Streamer, want 160", played)
+			}
+		})
+	}
+
+	if _, err := speaker.New(sampleRate, 80, speaker.WithBackend(speaker.Null()), speaker.WithPrecision(7)); err == nil {
+		t.Error("expected an error for an unsupported precision")
+	}
+}
+
+// suspendingCapture is a Capture which counts suspensions.
+type suspendingCapture struct {
+	*speaker.Capture
+	suspends, resumes chan struct{}
+}
+
+func (sc *suspendingCapture) Suspend() error {
+	sc.suspends <- struct{}{}
+	return nil
+}
+
+func (sc *suspendingCapture) Resume() error {
+	sc.resumes <- struct{}{}
+	return nil
+}
+
+func TestIdleTimeout(t *testing.T) {
+	backend := &suspendingCapture{
+		Capture:  speaker.NewCapture(),
+		suspends: make(chan struct{}, 10),
+		resumes:  make(chan struct{}, 10),
+	}
+	sp, err := speaker.New(sampleRate, 80, speaker.WithBackend(backend), speaker.WithIdleTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+
+	select {
+	case <-backend.suspends:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle speaker did not suspend")
+	}
+	if st := sp.Stats(); !st.Suspended || st.Suspensions != 1 {
+		t.Errorf("stats report suspended %v after %d suspensions, want true after 1", st.Suspended, st.Suspensions)
+	}
+	recorded := backend.Len()
+	time.Sleep(50 * time.Millisecond)
+	if backend.Len() != recorded {
+		t.Error("suspended speaker keeps writing")
+	}
+
+	done := make(chan struct{})
+	sp.Play(beep.Seq(beeptest.Constant(160, [2]float64{0.5, 0.5}), beep.Callback(func() {
+		close(done)
+	})))
+	select {
+	case <-backend.resumes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("speaker did not resume on Play")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("playback did not finish")
+	}
+	if st := sp.Stats(); st.Suspended {
+		t.Error("stats report the playing speaker as suspended")
+	}
+
+	var played int
+	for _, sample := range backend.Samples()[recorded:] {
+		if math.Abs(sample[0]-0.5) < 0.01 {
+			played++
+		}
+	}
+	if played != 160 {
+		t.Errorf("captured %d samples after resuming, want 160", played)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	sp, err := speaker.New(sampleRate, 80, speaker.WithBackend(speaker.Null()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// stopping one Handle doesn't affect the others
+	long := sp.Play(beep.Silence(-1))
+	short := sp.Play(beeptest.Constant(160, [2]float64{}), beeptest.Constant(80, [2]float64{}))
+	long.Stop()
+	if err := long.Wait(ctx); err != speaker.ErrStopped {
+		t.Errorf("stopped handle returned %v, want ErrStopped", err)
+	}
+	if err := short.Wait(ctx); err != nil {
+		t.Errorf("drained handle returned %v, want nil", err)
+	}
+
+	// errors of the Streamers are reported
+	errFailed := errors.New("failed")
+	if err := sp.PlayAndWait(ctx, beeptest.Erroring(80, errFailed, beeptest.Ramp(160))); err != errFailed {
+		t.Errorf("erroring streamer returned %v, want %v", err, errFailed)
+	}
+
+	// clearing stops all Handles
+	h := sp.Play(beep.Silence(-1))
+	sp.Clear()
+	select {
+	case <-h.Done():
+	case <-ctx.Done():
+		t.Fatal("cleared handle is not done")
+	}
+	if h.Err() != speaker.ErrStopped {
+		t.Errorf("cleared handle returned %v, want ErrStopped", h.Err())
+	}
+
+	// cancelling PlayAndWait stops the Streamers
+	cctx, ccancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer ccancel()
+	if err := sp.PlayAndWait(cctx, beep.Silence(-1)); err != context.DeadlineExceeded {
+		t.Errorf("cancelled playback returned %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if err := sp.Play().Wait(ctx); err != nil {
+		t.Errorf("empty handle returned %v, want nil", err)
+	}
+}
+
+func TestReinit(t *testing.T) {
+	capture := speaker.NewCapture()
+	sp, err := speaker.New(8000, 80, speaker.WithBackend(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+
+	// 0.4s, which is at least 3200 samples at 16000Hz after the switch
+	h := sp.Play(beeptest.Constant(4800, [2]float64{0.5, 0.5}))
+	time.Sleep(100 * time.Millisecond)
+	if err := sp.Init(16000, 160, speaker.WithBackend(capture)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Wait(ctx); err != nil {
+		t.Fatalf("carried over playback did not finish: %v", err)
+	}
+	sp.Close()
+
+	if sr := capture.Format().SampleRate; sr != 16000 {
+		t.Fatalf("backend sample rate is %v, want 16000", sr)
+	}
+	var played int
+	for _, sample := range capture.Samples() {
+		if math.Abs(sample[0]-0.5) < 0.01 {
+			played++
+		}
+	}
+	if played < 3200 {
+		t.Errorf("captured %d resampled samples after reinitialization, want at least 3200", played)
+	}
+}
+
+func TestReinitKeepsOptions(t *testing.T) {
+	capture := speaker.NewCapture()
+	sp, err := speaker.New(8000, 80, speaker.WithBackend(capture), speaker.WithMasterBus(effects.Swap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+
+	if err := sp.Init(16000, 160); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sp.PlayAndWait(ctx, beeptest.Constant(160, [2]float64{0.5, -0.25})); err != nil {
+		t.Fatalf("playback did not finish: %v", err)
+	}
+	sp.Close()
+
+	if sr := capture.Format().SampleRate; sr != 16000 {
+		t.Fatalf("backend sample rate is %v, want 16000", sr)
+	}
+	var swapped int
+	for _, sample := range capture.Samples() {
+		if math.Abs(sample[0]+0.25) < 0.01 && math.Abs(sample[1]-0.5) < 0.01 {
+			swapped++
+		}
+	}
+	if swapped != 160 {
+		t.Errorf("captured %d samples with swapped channels after reinitialization, want 160", swapped)
+	}
+}
+
+func TestReinitDeadlock(t *testing.T) {
+	sp, err := speaker.New(8000, 80, speaker.WithBackend(speaker.Null()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			sp.Lock()
+			sp.Unlock()
+			sp.Play(beep.Silence(100))
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			sr := beep.SampleRate(8000 + 8000*(i%2))
+			if err := sp.Init(sr, 80, speaker.WithBackend(speaker.Null())); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		sp.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("reinitialization deadlocked")
+	}
+	close(stop)
+	<-finished
+}
+
+func TestTap(t *testing.T) {
+	sp, err := speaker.New(sampleRate, 80, speaker.WithBackend(speaker.Null()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+
+	tap := sp.NewTap(64)
+	if tap.SampleRate() != sampleRate {
+		t.Errorf("tap sample rate is %v, want %v", tap.SampleRate(), sampleRate)
+	}
+	buffer := beep.NewBuffer(beep.Format{SampleRate: tap.SampleRate(), NumChannels: 2, Precision: 2})
+	recorded := make(chan struct{})
+	go func() {
+		buffer.Append(tap)
+		close(recorded)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = sp.PlayAndWait(ctx, beeptest.Constant(160, [2]float64{0.5, 0.5}), beeptest.Constant(80, [2]float64{1.5, -1.5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tap.Close()
+	select {
+	case <-recorded:
+	case <-ctx.Done():
+		t.Fatal("closed tap did not drain")
+	}
+
+	var played, clipped int
+	for _, sample := range beeptest.Collect(buffer.Streamer(0, buffer.Len()), 0) {
+		switch {
+		case math.Abs(sample[0]-0.5) < 0.01:
+			played++
+		case sample[0] > 0.99 && sample[1] < -0.99:
+			clipped++
+		case sample[0] > 1 || sample[1] < -1:
+			t.Fatalf("tapped sample %v is not clipped", sample)
+		}
+	}
+	if played != 80 || clipped != 80 {
+		t.Errorf("tapped %d samples at 0.5 and %d clipped samples, want 80 and 80", played, clipped)
+	}
+	if tap.Dropped() != 0 {
+		t.Errorf("tap dropped %d blocks, want 0", tap.Dropped())
+	}
+}
+
+func TestTapDropped(t *testing.T) {
+	sp, err := speaker.New(sampleRate, 80, speaker.WithBackend(speaker.Null()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tap := sp.NewTap(2)
+	time.Sleep(100 * time.Millisecond)
+	sp.Close()
+
+	if tap.Dropped() == 0 {
+		t.Error("tap did not drop any blocks while nobody consumed them")
+	}
+	if got := len(beeptest.Collect(tap, 0)); got != 160 {
+		t.Errorf("streamed %d queued samples after closing, want 160", got)
+	}
+}
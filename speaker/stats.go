@@ -0,0 +1,156 @@
+package speaker
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats contains timing statistics of a Speaker, useful for finding the cause of playback glitches.
+//
+// If LateWrites grows, the playing Streamers are too slow to produce the audio in real time. If
+// Underruns grows while LateWrites doesn't, the buffer is too small, or the Speaker is locked for
+// too long (see MaxLockHold).
+type Stats struct {
+	// Blocks is the number of blocks of bufferSize samples sent to the Backend.
+	Blocks int
+
+	// StreamTime is the time spent pulling data from the playing Streamers for the last block.
+	// MaxStreamTime and AvgStreamTime are the maximum and the average over all blocks.
+	StreamTime    time.Duration
+	MaxStreamTime time.Duration
+	AvgStreamTime time.Duration
+
+	// LateWrites is the number of blocks whose mixing took longer than the duration of the block.
+	LateWrites int
+
+	// Underruns is the number of blocks written to the Backend more than the duration of a block
+	// after the previous one. The Backend most likely ran out of audio data, causing an audible
+	// glitch.
+	Underruns int
+
+	// Latency is the delay caused by the Speaker's buffer, that is, the duration of bufferSize
+	// samples. The Backend may add its own latency.
+	Latency time.Duration
+
+	// MaxLockHold is the longest time the Speaker was held locked between Lock and Unlock. The time
+	// spent pulling data from the playing Streamers isn't included, see MaxStreamTime.
+	MaxLockHold time.Duration
+
+	// GainReduction is the gain reduction of the limiter on the master bus in decibels at the end
+	// of the last block, MaxGainReduction is the maximum over all blocks. Both are 0 if the limiter
+	// is disabled, see WithLimiter.
+	GainReduction    float64
+	MaxGainReduction float64
+
+	// Suspended reports whether the Speaker is suspended because it is idle, Suspensions counts how
+	// many times it suspended. See WithIdleTimeout.
+	Suspended   bool
+	Suspensions int
+}
+
+// statsRecorder collects Stats. It has its own mutex, so that reading Stats never blocks the
+// update goroutine for long.
+type statsRecorder struct {
+	mu          sync.Mutex
+	stats       Stats
+	totalStream time.Duration
+	block       time.Duration // duration of a single block
+	lastWrite   time.Time     // when the last write to the Backend returned
+}
+
+// reset clears the statistics for a Speaker with the given block duration.
+func (r *statsRecorder) reset(block time.Duration) {
+	r.mu.Lock()
+	r.stats = Stats{Latency: block}
+	r.totalStream = 0
+	r.block = block
+	r.lastWrite = time.Time{}
+	r.mu.Unlock()
+}
+
+// recordBlock records a block which started to be mixed at start, spent streamTime in the
+// Streamers and was ready to be written after mixTime, with the limiter reducing the gain by
+// gainReduction decibels.
+func (r *statsRecorder) recordBlock(start time.Time, streamTime, mixTime time.Duration, gainReduction float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats.Blocks++
+	r.stats.Suspended = false
+	r.stats.StreamTime = streamTime
+	if streamTime > r.stats.MaxStreamTime {
+		r.stats.MaxStreamTime = streamTime
+	}
+	r.totalStream += streamTime
+	r.stats.AvgStreamTime = r.totalStream / time.Duration(r.stats.Blocks)
+
+	r.stats.GainReduction = gainReduction
+	if gainReduction > r.stats.MaxGainReduction {
+		r.stats.MaxGainReduction = gainReduction
+	}
+
+	if mixTime > r.block {
+		r.stats.LateWrites++
+	}
+	if !r.lastWrite.IsZero() && start.Add(mixTime).Sub(r.lastWrite) > r.block {
+		r.stats.Underruns++
+	}
+}
+
+// recordWrite records that a write to the Backend has just returned.
+func (r *statsRecorder) recordWrite() {
+	r.mu.Lock()
+	r.lastWrite = time.Now()
+	r.mu.Unlock()
+}
+
+// suspend records that the Speaker suspended. The pause before the next write is not an underrun.
+func (r *statsRecorder) suspend() {
+	r.mu.Lock()
+	r.stats.Suspended = true
+	r.stats.Suspensions++
+	r.lastWrite = time.Time{}
+	r.mu.Unlock()
+}
+
+// recordLockHold records that the Speaker was locked for d.
+func (r *statsRecorder) recordLockHold(d time.Duration) {
+	r.mu.Lock()
+	if d > r.stats.MaxLockHold {
+		r.stats.MaxLockHold = d
+	}
+	r.mu.Unlock()
+}
+
+// snapshot returns a copy of the current statistics.
+func (r *statsRecorder) snapshot() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// Stats returns the current timing statistics of the Speaker. The statistics are reset when the
+// Speaker is initialized.
+func (s *Speaker) Stats() Stats {
+	return s.stats.snapshot()
+}
+
+// ReadStats returns the current timing statistics of the speaker. The statistics are reset by
+// Init.
+func ReadStats() Stats {
+	return std.Stats()
+}
+
+// reportStats calls f with the current Stats every interval until done is closed.
+func (s *Speaker) reportStats(interval time.Duration, f func(Stats), done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f(s.Stats())
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package speaker
+
+import (
+	"sync"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/internal/sampleconv"
+)
+
+// Tap records the audio played by a Speaker, exactly as it is sent to the Backend after the master
+// bus, limiting and clipping. Tap is a Streamer, so the recording can be consumed like any other
+// audio, for example appended to a beep.Buffer or encoded to a WAVE file:
+//
+//	tap := speaker.NewTap(64)
+//	go wav.Encode(f, tap, beep.Format{SampleRate: tap.SampleRate(), NumChannels: 2, Precision: 2})
+//	// play something ...
+//	tap.Close()
+//
+// The Speaker never waits for the consumer of a Tap. The recorded blocks are queued and when the
+// queue is full, new blocks are dropped and counted, see Dropped.
+type Tap struct {
+	s          *Speaker
+	sampleRate beep.SampleRate
+
+	queue chan [][2]float64
+	free  chan [][2]float64 // consumed blocks for reuse
+
+	mu      sync.Mutex
+	dropped int
+	closed  bool
+	done    chan struct{} // closed by Close
+
+	full  [][2]float64 // block being consumed
+	block [][2]float64 // rest of full
+}
+
+// NewTap starts recording the audio played by the Speaker. At most queueLen blocks of bufferSize
+// samples are queued for the consumer.
+//
+// The recording stops when the Tap or the Speaker is closed, or when the Speaker is reinitialized
+// with a different sample rate.
+func (s *Speaker) NewTap(queueLen int) *Tap {
+	if queueLen < 1 {
+		queueLen = 1
+	}
+	t := &Tap{
+		s:     s,
+		queue: make(chan [][2]float64, queueLen),
+		free:  make(chan [][2]float64, queueLen),
+		done:  make(chan struct{}),
+	}
+
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+	t.sampleRate = s.format.SampleRate
+	if s.backend == nil {
+		t.close()
+		return t
+	}
+	s.tapMu.Lock()
+	if s.taps == nil {
+		s.taps = make(map[*Tap]struct{})
+	}
+	s.taps[t] = struct{}{}
+	s.tapMu.Unlock()
+	return t
+}
+
+// NewTap starts recording the audio played by the speaker, see Speaker.NewTap.
+func NewTap(queueLen int) *Tap {
+	return std.NewTap(queueLen)
+}
+
+// SampleRate returns the sample rate of the recorded audio.
+func (t *Tap) SampleRate() beep.SampleRate {
+	return t.sampleRate
+}
+
+// Dropped returns the number of blocks dropped so far because the consumer fell behind.
+func (t *Tap) Dropped() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dropped
+}
+
+// Stream streams the recorded audio. It blocks until enough audio is played. After the Tap is
+// closed, it streams the remaining queued audio and drains.
+func (t *Tap) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) {
+		if len(t.block) == 0 && !t.next() {
+			break
+		}
+		c := copy(samples[n:], t.block)
+		t.block = t.block[c:]
+		n += c
+	}
+	return n, n > 0 || len(samples) == 0 && !t.isClosed()
+}
+
+// Err always returns nil.
+func (t *Tap) Err() error {
+	return nil
+}
+
+// Close stops the recording. The audio recorded up to that moment can still be streamed.
+func (t *Tap) Close() {
+	t.s.tapMu.Lock()
+	delete(t.s.taps, t)
+	t.s.tapMu.Unlock()
+	t.close()
+}
+
+// next waits for the next recorded block. It reports false if the Tap is closed and no more
+// blocks are queued.
+func (t *Tap) next() bool {
+	if t.full != nil {
+		t.recycle(t.full)
+		t.full = nil
+	}
+	select {
+	case t.full = <-t.queue:
+	case <-t.done:
+		// closed, but blocks queued before closing are still to be streamed
+		select {
+		case t.full = <-t.queue:
+		default:
+			return false
+		}
+	}
+	t.block = t.full
+	return true
+}
+
+func (t *Tap) isClosed() bool {
+	select {
+	case <-t.done:
+		return len(t.queue) == 0 && len(t.block) == 0
+	default:
+		return false
+	}
+}
+
+// write queues a copy of samples without blocking.
+func (t *Tap) write(samples [][2]float64) {
+	var block [][2]float64
+	select {
+	case block = <-t.free:
+	default:
+	}
+	if cap(block) < len(samples) {
+		block = make([][2]float64, len(samples))
+	}
+	block = block[:len(samples)]
+	for i, sample := range samples {
+		block[i] = [2]float64{sampleconv.Norm(sample[0]), sampleconv.Norm(sample[1])}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		t.recycle(block)
+		return
+	}
+	select {
+	case t.queue <- block:
+	default:
+		t.dropped++
+		t.recycle(block)
+	}
+}
+
+// recycle returns a block to the free list, unless it's full.
+func (t *Tap) recycle(block [][2]float64) {
+	select {
+	case t.free <- block:
+	default:
+	}
+}
+
+func (t *Tap) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.done)
+	}
+}
+
+// writeTaps sends the samples to all Taps of the Speaker.
+func (s *Speaker) writeTaps(samples [][2]float64) {
+	s.tapMu.Lock()
+	defer s.tapMu.Unlock()
+	for t := range s.taps {
+		t.write(samples)
+	}
+}
+
+// closeTaps stops all Taps of the Speaker.
+func (s *Speaker) closeTaps() {
+	s.tapMu.Lock()
+	defer s.tapMu.Unlock()
+	for t := range s.taps {
+		t.close()
+	}
+	s.taps = nil
+}
@@ -3,6 +3,7 @@ package vorbis
 
 import (
 	"io"
+	"strings"
 
 	"github.com/brotholo/beep"
 	"github.com/jfreymuth/oggvorbis"
@@ -19,6 +20,9 @@ const (
 //
 // Do not close the supplied ReadSeekCloser, instead, use the Close method of the returned
 // StreamSeekCloser when you want to release the resources.
+//
+// The returned StreamSeekCloser also has a Tags method, which returns the comments of the stream
+// as name/value pairs, see loudness.ReadReplayGain.
 func Decode(rc io.ReadCloser) (s beep.StreamSeekCloser, format beep.Format, err error) {
 	defer func() {
 		if err != nil {
@@ -94,3 +98,15 @@ func (d *decoder) Close() error {
 	}
 	return nil
 }
+
+// Tags returns the comments of the stream, such as TITLE or REPLAYGAIN_TRACK_GAIN, as name/value
+// pairs.
+func (d *decoder) Tags() [][2]string {
+	var tags [][2]string
+	for _, c := range d.d.CommentHeader().Comments {
+		if i := strings.IndexByte(c, '='); i >= 0 {
+			tags = append(tags, [2]string{c[:i], c[i+1:]})
+		}
+	}
+	return tags
+}
@@ -16,8 +16,18 @@ import (
 //
 // Do not close the supplied Reader, instead, use the Close method of the returned
 // StreamSeekCloser when you want to release the resources.
-
+//
+// Audio with more than two channels is mixed down to stereo according to the channel mask of the
+// file, see beep.Format.Downmix. Use DecodeDownmix to specify a different Downmix.
 func Decode(r io.Reader) (s beep.StreamSeekCloser, format beep.Format, err error) {
+	return DecodeDownmix(r, nil)
+}
+
+// DecodeDownmix is like Decode, but audio with more than two channels is mixed down to stereo
+// using the provided Downmix. The Downmix must have a row for each channel in the file, otherwise
+// an error is returned. If downmix is nil, the Downmix of the returned format is used. Audio with
+// one or two channels isn't mixed, downmix is ignored for it.
+func DecodeDownmix(r io.Reader, downmix beep.Downmix) (s beep.StreamSeekCloser, format beep.Format, err error) {
 	d := decoder{r: r}
 	defer func() { // hacky way to always close r if an error occurred
 		if closer, ok := d.r.(io.Closer); ok {
@@ -78,6 +88,7 @@ func Decode(r io.Reader) (s beep.StreamSeekCloser, format beep.Format, err error
 				d.h.ByteRate = fmtchunk.ByteRate
 				d.h.BytesPerFrame = fmtchunk.BytesPerFrame
 				d.h.BitsPerSample = fmtchunk.BitsPerSample
+				d.mask = beep.ChannelMask(fmtchunk.ChannelMask)
 
 				// SubFormat is represented by GUID. Plain PCM is KSDATAFORMAT_SUBTYPE_PCM GUID.
 				// See https://docs.microsoft.com/en-us/windows-hardware/drivers/ddi/content/ksmedia/ns-ksmedia-waveformatextensible
@@ -153,6 +164,19 @@ func Decode(r io.Reader) (s beep.StreamSeekCloser, format beep.Format, err error
 		NumChannels: int(d.h.NumChans),
 		Precision:   int(d.h.BitsPerSample / 8),
 	}
+	if d.mask.NumChannels() == format.NumChannels {
+		format.ChannelMask = d.mask
+	}
+	if downmix == nil {
+		downmix = format.Downmix()
+	}
+	if format.NumChannels > 2 {
+		if len(downmix) != format.NumChannels {
+			return nil, beep.Format{}, fmt.Errorf("wav: downmix has %d rows, but the audio has %d channels", len(downmix), format.NumChannels)
+		}
+		d.downmix = downmix
+		d.frame = make([]float64, format.NumChannels)
+	}
 	return &d, format, nil
 }
 
@@ -196,11 +220,14 @@ type header struct {
 }
 
 type decoder struct {
-	r   io.Reader
-	h   header
-	hsz int32
-	pos int32
-	err error
+	r       io.Reader
+	h       header
+	hsz     int32
+	pos     int32
+	err     error
+	mask    beep.ChannelMask
+	downmix beep.Downmix
+	frame   []float64
 }
 
 func (d *decoder) Stream(samples [][2]float64) (n int, ok bool) {
@@ -224,7 +251,7 @@ func (d *decoder) Stream(samples [][2]float64) (n int, ok bool) {
 			samples[j][0] = val
 			samples[j][1] = val
 		}
-	case d.h.BitsPerSample == 8 && d.h.NumChans >= 2:
+	case d.h.BitsPerSample == 8 && d.h.NumChans == 2:
 		for i, j := 0, 0; i <= n-bytesPerFrame; i, j = i+bytesPerFrame, j+1 {
 			samples[j][0] = float64(p[i+0])/(1<<8-1)*2 - 1
 			samples[j][1] = float64(p[i+1])/(1<<8-1)*2 - 1
@@ -235,7 +262,7 @@ func (d *decoder) Stream(samples [][2]float64) (n int, ok bool) {
 			samples[j][0] = val
 			samples[j][1] = val
 		}
-	case d.h.BitsPerSample == 16 && d.h.NumChans >= 2:
+	case d.h.BitsPerSample == 16 && d.h.NumChans == 2:
 		for i, j := 0, 0; i <= n-bytesPerFrame; i, j = i+bytesPerFrame, j+1 {
 			samples[j][0] = float64(int16(p[i+0])+int16(p[i+1])*(1<<8)) / (1<<16 - 1)
 			samples[j][1] = float64(int16(p[i+2])+int16(p[i+3])*(1<<8)) / (1<<16 - 1)
@@ -246,16 +273,36 @@ func (d *decoder) Stream(samples [][2]float64) (n int, ok bool) {
 			samples[j][0] = val
 			samples[j][1] = val
 		}
-	case d.h.BitsPerSample == 24 && d.h.NumChans >= 2:
+	case d.h.BitsPerSample == 24 && d.h.NumChans == 2:
 		for i, j := 0, 0; i <= n-bytesPerFrame; i, j = i+bytesPerFrame, j+1 {
 			samples[j][0] = float64((int32(p[i+0])<<8)+(int32(p[i+1])<<16)+(int32(p[i+2])<<24)) / (1 << 8) / (1<<24 - 1)
 			samples[j][1] = float64((int32(p[i+3])<<8)+(int32(p[i+4])<<16)+(int32(p[i+5])<<24)) / (1 << 8) / (1<<24 - 1)
 		}
+	case d.h.NumChans > 2:
+		precision := int(d.h.BitsPerSample / 8)
+		for i, j := 0, 0; i <= n-bytesPerFrame; i, j = i+bytesPerFrame, j+1 {
+			for c := range d.frame {
+				d.frame[c] = d.value(p[i+c*precision:])
+			}
+			samples[j] = d.downmix.Apply(d.frame)
+		}
 	}
 	d.pos += int32(n)
 	return n / bytesPerFrame, true
 }
 
+// value decodes a single channel value from p.
+func (d *decoder) value(p []byte) float64 {
+	switch d.h.BitsPerSample {
+	case 8:
+		return float64(p[0])/(1<<8-1)*2 - 1
+	case 16:
+		return float64(int16(p[0])+int16(p[1])*(1<<8)) / (1<<16 - 1)
+	default:
+		return float64((int32(p[0])<<8)+(int32(p[1])<<16)+(int32(p[2])<<24)) / (1 << 8) / (1<<24 - 1)
+	}
+}
+
 func (d *decoder) Err() error {
 	return d.err
 }
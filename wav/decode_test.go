@@ -0,0 +1,62 @@
+package wav_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/brotholo/beep"
+	"github.com/brotholo/beep/wav"
+)
+
+// multichannel returns a 16-bit WAVE file with a single frame containing the provided channel
+// values.
+func multichannel(values []int16) []byte {
+	var buf bytes.Buffer
+	numChans := int16(len(values))
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, int32(36+2*len(values)))
+	buf.WriteString("WAVEfmt ")
+	for _, field := range []interface{}{
+		int32(16), int16(1), numChans, int32(48000), int32(48000 * 2 * int32(numChans)), 2 * numChans, int16(16),
+	} {
+		binary.Write(&buf, binary.LittleEndian, field)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, int32(2*len(values)))
+	binary.Write(&buf, binary.LittleEndian, values)
+	return buf.Bytes()
+}
+
+func TestDecodeMultichannel(t *testing.T) {
+	values := []int16{1000, 2000, 3000, 4000, 5000, -5000}
+	channels := make([]float64, len(values))
+	for i, v := range values {
+		channels[i] = float64(v) / (1<<16 - 1)
+	}
+
+	for _, downmix := range []beep.Downmix{nil, beep.SelectChannels(6, 2, 3)} {
+		s, format, err := wav.DecodeDownmix(bytes.NewReader(multichannel(values)), downmix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if downmix == nil {
+			downmix = beep.DownmixITU51()
+		}
+		want := downmix.Apply(channels)
+
+		var samples [4][2]float64
+		n, ok := s.Stream(samples[:])
+		if n != 1 || !ok {
+			t.Fatalf("Stream returned (%d, %v), want (1, true)", n, ok)
+		}
+		if math.Abs(samples[0][0]-want[0]) > 1e-9 || math.Abs(samples[0][1]-want[1]) > 1e-9 {
+			t.Errorf("decoded %v, want %v (format %+v)", samples[0], want, format)
+		}
+	}
+
+	if _, _, err := wav.DecodeDownmix(bytes.NewReader(multichannel(values)), beep.DownmixQuad()); err == nil {
+		t.Error("DecodeDownmix accepted a Downmix with the wrong number of rows")
+	}
+}
@@ -1,4 +1,4 @@
-package github.com/brotholo/beep/wav
+package wav
 
 import (
 	"bufio"
@@ -112,11 +112,24 @@ type EncodePerpetum struct {
 	min_vol_start_rec          float64
 	max_vol_stop_rec           float64
 	autobalance_start_stop_rec bool
+	leveler                    Leveler
 	headers                    *header
 	buff                       *bytes.Buffer
 	file                       *io.WriteSeeker
 }
 
+// PerpetumOption configures the recorder in StartEncodePerpertum.
+type PerpetumOption func(*EncodePerpetum)
+
+// WithLeveler makes the recorder check the start and stop thresholds on the audio leveled by l,
+// when autobalance_start_stop_rec is set, see WakeUp.CheckAutobalance. The recorded audio is left
+// unchanged. nil, the default, checks the thresholds on the audio itself.
+func WithLeveler(l Leveler) PerpetumOption {
+	return func(ep *EncodePerpetum) {
+		ep.leveler = l
+	}
+}
+
 func StartEncodePerpertum(
 	s beep.Streamer,
 	format beep.Format,
@@ -130,7 +143,8 @@ func StartEncodePerpertum(
 	max_vol_stop_rec float64,
 	autobalance_start_stop_rec bool,
 	debug_file bool,
-	debug_samples bool) bool {
+	debug_samples bool,
+	opts ...PerpetumOption) bool {
 
 	ep := EncodePerpetum{}
 	ep.s = s
@@ -144,6 +158,9 @@ func StartEncodePerpertum(
 	ep.min_vol_start_rec = min_vol_start_rec
 	ep.max_vol_stop_rec = max_vol_stop_rec
 	ep.autobalance_start_stop_rec = autobalance_start_stop_rec
+	for _, opt := range opts {
+		opt(&ep)
+	}
 	if !ep.EncodeSetup() {
 		return false
 	}
@@ -298,10 +315,18 @@ type WakeUp struct {
 	th_on            float64
 	th_off           float64
 	autobalance      bool
+	leveler          Leveler
+	leveled          [][2]float64
 	bottom_memory    [31][][2]float64
 	complete_samples [][][2]float64
 }
 
+// Leveler evens out the level of audio in place, continuing from the previous call, for example
+// effects.AGC.
+type Leveler interface {
+	Level(samples [][2]float64)
+}
+
 func InitWakeUp(tts int, th_on float64, th_off float64, autobalance bool) *WakeUp {
 	wu := WakeUp{}
 	wu.tts = tts
@@ -320,6 +345,11 @@ func InitWakeUp(tts int, th_on float64, th_off float64, autobalance bool) *WakeU
 	return &wu
 }
 
+// SetLeveler sets the Leveler used by CheckAutobalance.
+func (wu *WakeUp) SetLeveler(l Leveler) {
+	wu.leveler = l
+}
+
 func (wu *WakeUp) RefreshMem() [31][][2]float64 {
 	wb := wu.bottom_memory
 	wu.bottom_memory = [31][][2]float64{}
@@ -332,44 +362,21 @@ func (wu *WakeUp) BackupSamples(samples [][2]float64, nsamples int) {
 	}
 	wu.bottom_memory[30] = samples
 }
+
+// CheckAutobalance is like Check, but it checks the thresholds on the samples leveled by the
+// Leveler set by SetLeveler, so th_on and th_off don't depend on the level of the microphone. The
+// samples themselves are left unchanged. Without a Leveler it's the same as Check.
 func (wu *WakeUp) CheckAutobalance(samples [][2]float64, nsamples int) string {
-	current_svar := IsSilent(samples, wu.threshold, false, false)
-	switch {
-	case wu.record_mode:
-		//  fmt.Println(wu.back_to_silence, wu.tts)
-		if current_svar {
-			//  if wu.back_to_silence > wu.tts {
-			//  wu.record_mode = false
-			//  wu.back_to_silence = 0
-			//  fmt.Println("Back To Silent")
-			//  if wu.fake_break < wu.fake_break_limit {
-			//  return "drop"
-			//  }
-			//  wu.threshold = wu.th_off
-			//  return "complete"
-			//  } else {
-			//  wu.back_to_silence += 1
-			//  return "continue"
-			//  }
-		} else {
-			//  fmt.Println("RESET BACK TO SILENCE")
-			//  wu.back_to_silence = 0
-			//  wu.fake_break += 1
-			//  return "continue"
-		}
-	case !wu.record_mode:
-		//  if !current_svar {
-		//  fmt.Println("SILENCE BREAK")
-		//  wu.record_mode = true
-		//  wu.threshold = wu.th_on
-		//  return "init"
-		//  } else {
-		//  wu.BackupSamples(samples, nsamples)
-		//  return ""
-		//  }
+	if wu.leveler == nil {
+		return wu.Check(samples, nsamples)
 	}
-
-	return "complete"
+	if cap(wu.leveled) < nsamples {
+		wu.leveled = make([][2]float64, nsamples)
+	}
+	leveled := wu.leveled[:nsamples]
+	copy(leveled, samples[:nsamples])
+	wu.leveler.Level(leveled)
+	return wu.Check(leveled, nsamples)
 }
 func (wu *WakeUp) Check(samples [][2]float64, nsamples int) string {
 	current_svar := IsSilent(samples, wu.threshold, false, false)
@@ -420,6 +427,7 @@ func (ep *EncodePerpetum) StartWithDetect(debug_audio_file bool, debug_samples b
 		ep.min_vol_start_rec,
 		ep.max_vol_stop_rec,
 		ep.autobalance_start_stop_rec)
+	wakeUp.SetLeveler(ep.leveler)
 	for {
 		samples, nsamples := ep.ReadSamples()
 		if samples == nil {
@@ -427,11 +435,11 @@ func (ep *EncodePerpetum) StartWithDetect(debug_audio_file bool, debug_samples b
 			return
 		}
 		var res string
-		//  if ep.autobalance_start_stop_rec {
-		//  res = wakeUp.CheckAutobalance(samples, nsamples)
-		//  } else {
-		res = wakeUp.Check(samples, nsamples)
-		//  }
+		if ep.autobalance_start_stop_rec {
+			res = wakeUp.CheckAutobalance(samples, nsamples)
+		} else {
+			res = wakeUp.Check(samples, nsamples)
+		}
 		switch res {
 		case "complete":
 			fmt.Println("COMPLETE")
@@ -0,0 +1,38 @@
+package wav_test
+
+import (
+	"testing"
+
+	"github.com/brotholo/beep/wav"
+)
+
+// fixedGain is a Leveler applying a constant gain.
+type fixedGain float64
+
+func (g fixedGain) Level(samples [][2]float64) {
+	for i := range samples {
+		samples[i][0] *= float64(g)
+		samples[i][1] *= float64(g)
+	}
+}
+
+func TestWakeUpCheckAutobalance(t *testing.T) {
+	quiet := make([][2]float64, 512)
+	for i := range quiet {
+		quiet[i] = [2]float64{0.02, 0.02}
+	}
+
+	wu := wav.InitWakeUp(5, 0.05, 0.1, true)
+	if res := wu.CheckAutobalance(quiet, len(quiet)); res != "" {
+		t.Fatalf("quiet samples without a Leveler: got %q, want silence", res)
+	}
+
+	wu = wav.InitWakeUp(5, 0.05, 0.1, true)
+	wu.SetLeveler(fixedGain(10))
+	if res := wu.CheckAutobalance(quiet, len(quiet)); res != "init" {
+		t.Fatalf("quiet samples with a Leveler: got %q, want %q", res, "init")
+	}
+	if quiet[0] != [2]float64{0.02, 0.02} {
+		t.Errorf("CheckAutobalance changed the samples to %v", quiet[0])
+	}
+}
@@ -0,0 +1,124 @@
+package wav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/brotholo/beep"
+	"github.com/pkg/errors"
+)
+
+// Writer writes audio in WAVE format incrementally, which is useful when the audio is produced
+// over time and not available as a single Streamer, such as when recording.
+//
+// The sizes in the header are finalized when the Writer is closed. Until then, the written file is
+// not a valid WAVE file.
+type Writer struct {
+	w       io.WriteSeeker
+	bw      *bufio.Writer
+	format  beep.Format
+	h       header
+	buf     []byte
+	written int
+}
+
+// NewWriter writes a WAVE header to w and returns a Writer which appends audio data in the
+// provided format to it.
+//
+// Format precision must be 1, 2 or 3 bytes.
+func NewWriter(w io.WriteSeeker, format beep.Format) (*Writer, error) {
+	if format.NumChannels <= 0 {
+		return nil, errors.New("wav: invalid number of channels (less than 1)")
+	}
+	if format.Precision != 1 && format.Precision != 2 && format.Precision != 3 {
+		return nil, errors.New("wav: unsupported precision, 1, 2 or 3 is supported")
+	}
+
+	wr := &Writer{
+		w:      w,
+		bw:     bufio.NewWriter(w),
+		format: format,
+		h: header{
+			RiffMark:      [4]byte{'R', 'I', 'F', 'F'},
+			FileSize:      -1, // finalization
+			WaveMark:      [4]byte{'W', 'A', 'V', 'E'},
+			FmtMark:       [4]byte{'f', 'm', 't', ' '},
+			FormatSize:    16,
+			FormatType:    1,
+			NumChans:      int16(format.NumChannels),
+			SampleRate:    int32(format.SampleRate),
+			ByteRate:      int32(int(format.SampleRate) * format.NumChannels * format.Precision),
+			BytesPerFrame: int16(format.NumChannels * format.Precision),
+			BitsPerSample: int16(format.Precision) * 8,
+			DataMark:      [4]byte{'d', 'a', 't', 'a'},
+			DataSize:      -1, // finalization
+		},
+	}
+	if err := binary.Write(w, binary.LittleEndian, &wr.h); err != nil {
+		return nil, errors.Wrap(err, "wav")
+	}
+	return wr, nil
+}
+
+// Format returns the format of the written audio.
+func (w *Writer) Format() beep.Format {
+	return w.format
+}
+
+// Write appends audio data already encoded in the Writer's format to the file. For 8-bit audio,
+// the samples must be unsigned, otherwise signed, as required by the WAVE format.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	n, err = w.bw.Write(p)
+	w.written += n
+	if err != nil {
+		return n, errors.Wrap(err, "wav")
+	}
+	return n, nil
+}
+
+// WriteSamples encodes the samples in the Writer's format and appends them to the file.
+func (w *Writer) WriteSamples(samples [][2]float64) error {
+	width := w.format.Width()
+	if cap(w.buf) < len(samples)*width {
+		w.buf = make([]byte, len(samples)*width)
+	}
+	buf := w.buf[:len(samples)*width]
+	p := buf
+	switch {
+	case w.format.Precision == 1:
+		for _, sample := range samples {
+			p = p[w.format.EncodeUnsigned(p, sample):]
+		}
+	case w.format.Precision == 2 || w.format.Precision == 3:
+		for _, sample := range samples {
+			p = p[w.format.EncodeSigned(p, sample):]
+		}
+	default:
+		panic(fmt.Errorf("wav: encode: invalid precision: %d", w.format.Precision))
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// Close flushes the buffered data and finalizes the header. It doesn't close the underlying
+// io.WriteSeeker.
+func (w *Writer) Close() error {
+	if err := w.bw.Flush(); err != nil {
+		return errors.Wrap(err, "wav")
+	}
+
+	w.h.FileSize = int32(44 + w.written) // 44 is the size of the header
+	w.h.DataSize = int32(w.written)
+	if _, err := w.w.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "wav")
+	}
+	if err := binary.Write(w.w, binary.LittleEndian, &w.h); err != nil {
+		return errors.Wrap(err, "wav")
+	}
+	if _, err := w.w.Seek(0, io.SeekEnd); err != nil {
+		return errors.Wrap(err, "wav")
+	}
+	return nil
+}